@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/config"
+	"github.com/DMaryanskiy/go-idk/internal/migrations"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+)
+
+var migrationNameRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+func runMigrate(cfg *config.Config, args []string) (err error) {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	dir := flags.String("dir", "internal/migrations", "directory for new migration files (create only)")
+	timeout := flags.Duration("timeout", 5*time.Minute, "timeout for the whole migration run")
+	if err = flags.Parse(args); err != nil {
+		return err
+	}
+	args = flags.Args()
+
+	action := "up"
+	if len(args) > 0 {
+		action, args = args[0], args[1:]
+	}
+
+	// create only touches the source tree, it does not need a database
+	if action == "create" {
+		if len(args) != 1 {
+			return errors.New("usage: migrate create NAME")
+		}
+		return createMigration(*dir, args[0])
+	}
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		return fmt.Errorf("failed to load migrations: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	switch action {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		printMigrations("Applied", applied)
+		return err
+	case "down":
+		if len(args) != 1 {
+			return errors.New("usage: migrate down N")
+		}
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid number of migrations %q", args[0])
+		}
+		rolledBack, err := migrator.Down(ctx, n)
+		printMigrations("Rolled back", rolledBack)
+		return err
+	case "goto":
+		if len(args) != 1 {
+			return errors.New("usage: migrate goto V")
+		}
+		version, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		changed, err := migrator.Goto(ctx, version)
+		printMigrations("Migrated", changed)
+		return err
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		return printStatus(statuses)
+	default:
+		return fmt.Errorf("unknown migrate action %q", action)
+	}
+}
+
+func printMigrations(verb string, migrations []database.Migration) {
+	if len(migrations) == 0 {
+		fmt.Println("No migrations to run")
+		return
+	}
+	for _, m := range migrations {
+		fmt.Printf("%s %d_%s\n", verb, m.Version, m.Name)
+	}
+}
+
+func printStatus(statuses []database.MigrationStatus) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range statuses {
+		state, appliedAt := "pending", "-"
+		if s.Applied {
+			state, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Modified {
+			state = "applied (modified)"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, appliedAt)
+	}
+	return w.Flush()
+}
+
+func createMigration(dir, name string) error {
+	name = strings.Trim(migrationNameRe.ReplaceAllString(strings.ToLower(name), "_"), "_")
+	if name == "" {
+		return errors.New("migration name must contain letters or digits")
+	}
+
+	existing, err := database.LoadMigrations(os.DirFS(dir))
+	if err != nil {
+		return err
+	}
+
+	var version int64 = 1
+	if len(existing) > 0 {
+		version = existing[len(existing)-1].Version + 1
+	}
+
+	for _, direction := range []string{"up", "down"} {
+		path := filepath.Join(dir, fmt.Sprintf("%06d_%s.%s.sql", version, name, direction))
+		content := fmt.Sprintf("-- %s migration %d_%s\n", direction, version, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return fmt.Errorf("error creating migration file: %w", err)
+		}
+		fmt.Printf("Created %s\n", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/config"
+	"github.com/DMaryanskiy/go-idk/internal/repository"
+)
+
+// runRoles manages role assignments from the command line, which is the only
+// way to create the first admin
+func runRoles(cfg *config.Config, args []string) (err error) {
+	if len(args) != 3 || (args[0] != "assign" && args[0] != "remove") {
+		return errors.New("usage: roles assign|remove EMAIL ROLE")
+	}
+	action, email, role := args[0], strings.ToLower(strings.TrimSpace(args[1])), strings.ToLower(args[2])
+
+	db, err := openDatabase(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to connect to database: %w", err)
+	}
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	user, err := repository.NewUserRepository(db).GetByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return fmt.Errorf("user %s not found", email)
+	}
+
+	roles := repository.NewRoleRepository(db)
+	if action == "assign" {
+		if err = roles.AssignToUser(ctx, user.ID, role); err != nil {
+			return err
+		}
+		fmt.Printf("Assigned role %s to %s\n", role, email)
+		return nil
+	}
+
+	if err = roles.RemoveFromUser(ctx, user.ID, role); err != nil {
+		return err
+	}
+	fmt.Printf("Removed role %s from %s\n", role, email)
+	return nil
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/chat"
+	"github.com/DMaryanskiy/go-idk/internal/config"
+	"github.com/DMaryanskiy/go-idk/internal/handler"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/DMaryanskiy/go-idk/internal/migrations"
+	"github.com/DMaryanskiy/go-idk/internal/repository"
+	"github.com/DMaryanskiy/go-idk/internal/service"
+	"github.com/DMaryanskiy/go-idk/internal/token"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/DMaryanskiy/go-idk/pkg/mailer"
+	"github.com/DMaryanskiy/go-idk/pkg/password"
+	"github.com/DMaryanskiy/go-idk/pkg/pubsub"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/cors"
+	"github.com/gofiber/fiber/v3/middleware/limiter"
+	"github.com/gofiber/fiber/v3/middleware/recover"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"go.uber.org/zap"
+)
+
+func serve(cfg *config.Config, log *zap.Logger) {
+	// Init database
+	db, err := openDatabase(cfg)
+	if err != nil {
+		log.Fatal("Failed to connect to database", zap.Error(err))
+	}
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			log.Fatal("Failed to close db", zap.Error(errDB))
+		}
+	}()
+
+	// Run or check migrations
+	if err := prepareSchema(cfg, db, log); err != nil {
+		log.Fatal("Database schema is not ready", zap.String("mode", cfg.MigrationMode), zap.Error(err))
+	}
+
+	// Init layers
+	userRepo := repository.NewUserRepository(db)
+	cursors, err := token.NewCursorCodec(cfg.CursorSecret)
+	if err != nil {
+		log.Fatal("Failed to init cursor codec", zap.Error(err))
+	}
+	userService := service.NewUserService(userRepo, cursors, log)
+	val := validator.New()
+	importService := service.NewUserImportService(
+		userRepo,
+		repository.NewUserImportRepository(db),
+		val,
+		service.UserImportConfig{
+			BatchSize:  cfg.ImportBatchSize,
+			AsyncSize:  cfg.ImportAsyncSize,
+			MaxSize:    cfg.ImportBodyLimit,
+			StaleAfter: cfg.ImportStaleAfter,
+			Retention:  cfg.ImportRetention,
+		},
+		log,
+	)
+	userHandler := handler.NewUserHandler(userService, importService, val, log)
+	tokenManager, err := token.NewJWTManager(token.Config{
+		Algorithm:  cfg.JWTAlgorithm,
+		Secret:     cfg.JWTSecret,
+		PrivateKey: cfg.JWTPrivateKey,
+		Issuer:     cfg.JWTIssuer,
+		TTL:        cfg.AccessTokenTTL,
+	})
+	if err != nil {
+		log.Fatal("Failed to init token manager", zap.Error(err))
+	}
+	mail, err := newMailer(cfg)
+	if err != nil {
+		log.Fatal("Failed to init mailer", zap.Error(err))
+	}
+	verificationService := service.NewVerificationService(
+		userRepo,
+		repository.NewVerificationCodeRepository(db),
+		mail,
+		service.VerificationConfig{
+			CodeTTL:        cfg.VerificationCodeTTL,
+			ResendInterval: cfg.VerificationResendInterval,
+			MaxAttempts:    cfg.VerificationMaxAttempts,
+		},
+		log,
+	)
+	hasher := password.NewHasher(cfg.BcryptCost)
+	passwordResetService := service.NewPasswordResetService(
+		userRepo,
+		repository.NewPasswordResetRepository(db),
+		hasher,
+		mail,
+		service.PasswordResetConfig{
+			TokenTTL: cfg.PasswordResetTTL,
+			URL:      cfg.PasswordResetURL,
+		},
+		log,
+	)
+	refreshTokenRepo := repository.NewRefreshTokenRepository(db)
+	authService := service.NewAuthService(
+		userRepo,
+		refreshTokenRepo,
+		hasher,
+		tokenManager,
+		verificationService,
+		cfg.RefreshTokenTTL,
+		log,
+	)
+	idempotencyService := service.NewIdempotencyService(
+		repository.NewIdempotencyRepository(db),
+		service.IdempotencyConfig{
+			TTL:         cfg.IdempotencyTTL,
+			LockTimeout: cfg.IdempotencyLockTimeout,
+		},
+		log,
+	)
+	authorizationService := service.NewAuthorizationService(repository.NewRoleRepository(db), log)
+	roleHandler := handler.NewRoleHandler(authorizationService, val, log)
+	bus, err := newPubSub(cfg, db, log)
+	if err != nil {
+		log.Fatal("Failed to init pubsub", zap.Error(err))
+	}
+	defer func() {
+		if err := bus.Close(); err != nil {
+			log.Error("Failed to close pubsub", zap.Error(err))
+		}
+	}()
+	hub, err := chat.NewHub(bus, repository.NewEventRepository(db), log)
+	if err != nil {
+		log.Fatal("Failed to init chat hub", zap.Error(err))
+	}
+	chatRepo := repository.NewChatRepository(db)
+	chatService := service.NewChatService(chatRepo, userRepo, hub, service.ChatConfig{
+		EditWindow: cfg.MessageEditWindow,
+	}, log)
+	roomService := service.NewRoomService(repository.NewRoomRepository(db), chatRepo, hub, log)
+	roomHandler := handler.NewRoomHandler(roomService, val, log)
+	conversationHandler := handler.NewConversationHandler(chatService, val, log)
+	messageHandler := handler.NewMessageHandler(chatService, val, log)
+	presenceService := service.NewPresenceService(
+		repository.NewPresenceRepository(db),
+		chatRepo,
+		hub,
+		service.PresenceConfig{
+			Timeout:        cfg.PresenceTimeout,
+			TypingTTL:      cfg.TypingTTL,
+			TypingInterval: cfg.TypingInterval,
+		},
+		log,
+	)
+	presenceHandler := handler.NewPresenceHandler(presenceService, log)
+	chatHandler := chat.NewHandler(hub, chatService, roomService, presenceService, val, chat.Config{
+		PingInterval:   cfg.WSPingInterval,
+		PongTimeout:    cfg.WSPongTimeout,
+		WriteTimeout:   cfg.WriteTimeout,
+		MaxMessageSize: int64(cfg.WSMaxMessageSize),
+	}, log)
+	authHandler := handler.NewAuthHandler(
+		authService,
+		userService,
+		verificationService,
+		passwordResetService,
+		val,
+		log,
+	)
+
+	// Init Fiber app
+	app := fiber.New(fiber.Config{
+		ErrorHandler: handler.ErrorHandler(log),
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+		BodyLimit:    cfg.BodyLimit,
+
+		// Imports are read as they arrive, BodyLimit below bounds the rest
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+	})
+
+	// Middleware
+	app.Use(requestid.New())
+	app.Use(middleware.Logger(log))
+	app.Use(recover.New())
+	app.Use(cors.New(cors.Config{
+		AllowOrigins: []string{cfg.CORSOrigins},
+		AllowMethods: []string{"GET", "POST", "HEAD", "PUT", "PATCH", "DELETE"},
+		AllowHeaders: []string{"Origin", "Content-Type", "Accept", "Authorization", "Last-Event-ID", "If-Match", "If-None-Match", middleware.HeaderIdempotencyKey},
+		ExposeHeaders: []string{"ETag", "Location", middleware.HeaderIdempotentReplayed},
+	}))
+	app.Use(limiter.New(limiter.Config{
+		Max:        cfg.RateLimitMax,
+		Expiration: cfg.RateLimitExpiration,
+		KeyGenerator: func(c fiber.Ctx) string {
+			return c.IP()
+		},
+		LimitReached: func(c fiber.Ctx) error {
+			return fiber.NewError(fiber.StatusTooManyRequests, "Rate limit exceeded")
+		},
+	}))
+	// Imports bound their body with IMPORT_BODY_LIMIT instead
+	app.Use(middleware.BodyLimit(cfg.BodyLimit, "/api/v1/users/import"))
+
+	// Health check
+	app.Get("/health", func(c fiber.Ctx) error {
+		return c.JSON(fiber.Map{
+			"status":    "ok",
+			"timestamp": time.Now().Unix(),
+		})
+	})
+
+	// API Routes
+	api := app.Group("/api/v1")
+	authenticate := middleware.Auth(tokenManager)
+	authz := middleware.NewAuthorizer(authorizationService)
+	authHandler.RegisterRoutes(api, authenticate)
+	userHandler.RegisterRoutes(api, authenticate, authz, middleware.Idempotency(idempotencyService))
+	roleHandler.RegisterRoutes(api, authenticate, authz)
+	roomHandler.RegisterRoutes(api, authenticate)
+	conversationHandler.RegisterRoutes(api, authenticate)
+	messageHandler.RegisterRoutes(api, authenticate)
+	presenceHandler.RegisterRoutes(api, authenticate)
+	chatHandler.RegisterRoutes(api, authenticate, middleware.StreamAuth(tokenManager))
+
+	// Graceful shutdown
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+
+	// Connections of crashed instances are only cleaned up by the others
+	background, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go runPeriodically(background, cfg.PresenceTimeout/2, func(ctx context.Context) {
+		_ = presenceService.Sweep(ctx)
+	})
+	go runPeriodically(background, time.Hour, func(ctx context.Context) {
+		_ = hub.Prune(ctx, cfg.EventRetention)
+	})
+	go runPeriodically(background, time.Hour, func(ctx context.Context) {
+		_ = userService.PurgeDeleted(ctx, cfg.DeletedUserRetention)
+	})
+	go runPeriodically(background, time.Hour, func(ctx context.Context) {
+		_ = idempotencyService.Prune(ctx)
+	})
+	go runPeriodically(background, time.Minute, func(ctx context.Context) {
+		_ = importService.Sweep(ctx)
+	})
+
+	go func() {
+		if err := app.Listen(":" + cfg.Port); err != nil {
+			log.Fatal("Failed tp start server", zap.Error(err))
+		}
+	}()
+
+	log.Info("Server started", zap.String("port", cfg.Port))
+
+	<-quit
+	log.Info("Shutting down server")
+
+	// WebSocket connections are hijacked from the server, so Fiber does not close them
+	hub.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := app.ShutdownWithContext(ctx); err != nil {
+		log.Fatal("Server forced to shutdown", zap.Error(err))
+	}
+
+	// Emails are sent after the response, the user is already waiting for them
+	if err := passwordResetService.Wait(ctx); err != nil {
+		log.Warn("Password reset emails still being sent were dropped", zap.Error(err))
+	}
+	if err := verificationService.Wait(ctx); err != nil {
+		log.Warn("Verification codes still being sent were dropped", zap.Error(err))
+	}
+
+	log.Info("Server exited")
+}
+
+// prepareSchema applies pending migrations in "auto" mode and only verifies
+// the schema in "check" mode, so the server never runs against a
+// half-migrated database. Drifted migrations fail both modes.
+func prepareSchema(cfg *config.Config, db *database.DB, log *zap.Logger) error {
+	if cfg.MigrationMode == "off" {
+		return nil
+	}
+
+	migrator, err := database.NewMigrator(db, migrations.FS)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	switch cfg.MigrationMode {
+	case "auto":
+		applied, err := migrator.Up(ctx)
+		for _, m := range applied {
+			log.Info("Migration applied", zap.Int64("version", m.Version), zap.String("name", m.Name))
+		}
+		return err
+	case "check":
+		return migrator.Check(ctx)
+	default:
+		return fmt.Errorf("unknown migration mode %q", cfg.MigrationMode)
+	}
+}
+
+func newMailer(cfg *config.Config) (mailer.Mailer, error) {
+	switch cfg.Mailer {
+	case "smtp":
+		return mailer.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom), nil
+	case "file":
+		return mailer.NewFileMailer(cfg.MailDir, cfg.MailFrom)
+	case "memory":
+		return mailer.NewMemoryMailer(), nil
+	default:
+		return nil, fmt.Errorf("unknown mailer %q", cfg.Mailer)
+	}
+}
+
+// runPeriodically calls fn every interval until ctx is done
+func runPeriodically(ctx context.Context, interval time.Duration, fn func(ctx context.Context)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(ctx)
+		}
+	}
+}
+
+func newPubSub(cfg *config.Config, db *database.DB, log *zap.Logger) (pubsub.PubSub, error) {
+	switch cfg.PubSub {
+	case "postgres":
+		return pubsub.NewPostgres(db, log)
+	case "memory":
+		return pubsub.NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("unknown pubsub %q", cfg.PubSub)
+	}
+}
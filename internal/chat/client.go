@@ -0,0 +1,152 @@
+package chat
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/fasthttp/websocket"
+	"go.uber.org/zap"
+)
+
+// Config tunes WebSocket connections
+type Config struct {
+	// PingInterval is how often the server pings idle clients
+	PingInterval time.Duration
+	// PongTimeout closes connections that sent nothing, not even a pong, for that long
+	PongTimeout    time.Duration
+	WriteTimeout   time.Duration
+	MaxMessageSize int64
+}
+
+// sendBuffer is how many frames may wait for a slow client before it is dropped
+const sendBuffer = 64
+
+// Client is a single WebSocket or event stream connection of a user, conn is
+// nil for event streams
+type Client struct {
+	conn   *websocket.Conn
+	userID int
+	// presenceID identifies the connection in presence tracking, it is empty
+	// if the connection could not be tracked
+	presenceID string
+	// heartbeat is called by the read pump when the client shows it is alive,
+	// at most once per half ping interval
+	heartbeat func()
+	lastBeat  time.Time
+	cfg       Config
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+	logger    *zap.Logger
+}
+
+func newClient(conn *websocket.Conn, userID int, cfg Config, logger *zap.Logger) *Client {
+	return &Client{
+		conn:   conn,
+		userID: userID,
+		cfg:    cfg,
+		send:   make(chan []byte, sendBuffer),
+		done:   make(chan struct{}),
+		logger: logger,
+	}
+}
+
+// enqueue never blocks, a client that cannot keep up is disconnected and
+// catches up from history after reconnecting
+func (c *Client) enqueue(data []byte) {
+	select {
+	case c.send <- data:
+	case <-c.done:
+	default:
+		c.logger.Warn("Dropping slow WebSocket client", zap.Int("user_id", c.userID))
+		c.close()
+	}
+}
+
+func (c *Client) close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+func (c *Client) reply(typ, id string, payload any) {
+	data, err := encodeEnvelope(typ, id, payload)
+	if err != nil {
+		c.logger.Error("Error encoding reply", zap.String("type", typ), zap.Error(err))
+		return
+	}
+	c.enqueue(data)
+}
+
+// readPump reads client envelopes until the connection fails and passes them to handle
+func (c *Client) readPump(handle func(*Client, *Envelope)) {
+	defer c.close()
+
+	c.conn.SetReadLimit(c.cfg.MaxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongTimeout))
+	c.conn.SetPongHandler(func(string) error {
+		c.beat()
+		return c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongTimeout))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				c.logger.Debug("WebSocket read failed", zap.Int("user_id", c.userID), zap.Error(err))
+			}
+			return
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(c.cfg.PongTimeout))
+		c.beat()
+
+		env := new(Envelope)
+		if err := json.Unmarshal(data, env); err != nil {
+			c.reply(TypeError, "", invalidEnvelope)
+			continue
+		}
+		handle(c, env)
+	}
+}
+
+// beat runs on the read pump or the event stream goroutine only
+func (c *Client) beat() {
+	if c.heartbeat == nil || time.Since(c.lastBeat) < c.cfg.PingInterval/2 {
+		return
+	}
+	c.lastBeat = time.Now()
+	c.heartbeat()
+}
+
+// writePump is the only writer of the connection, it also sends pings and
+// closes the connection once the client is closed
+func (c *Client) writePump() {
+	ticker := time.NewTicker(c.cfg.PingInterval)
+	defer func() {
+		ticker.Stop()
+		_ = c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(c.cfg.WriteTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				c.close()
+				return
+			}
+		case <-ticker.C:
+			deadline := time.Now().Add(c.cfg.WriteTimeout)
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, deadline); err != nil {
+				c.close()
+				return
+			}
+		case <-c.done:
+			deadline := time.Now().Add(c.cfg.WriteTimeout)
+			closing := websocket.FormatCloseMessage(websocket.CloseGoingAway, "")
+			_ = c.conn.WriteControl(websocket.CloseMessage, closing, deadline)
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package chat
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/google/uuid"
+)
+
+// EnvelopeVersion changes whenever the envelope or an event payload changes
+// in a way old clients cannot read
+const EnvelopeVersion = 1
+
+// Envelope types sent by clients
+const (
+	TypeMessageSend      = "message.send"
+	TypeMessageEdit      = "message.edit"
+	TypeMessageDelete    = "message.delete"
+	TypeReactionAdd      = "reaction.add"
+	TypeReactionRemove   = "reaction.remove"
+	TypeConversationRead = "conversation.read"
+	TypePresenceSet      = "presence.set"
+	TypeTypingStart      = "typing.start"
+	TypeTypingStop       = "typing.stop"
+	TypeRoomJoin         = "room.join"
+	TypeRoomLeave        = "room.leave"
+	TypeRoomInvite       = "room.invite"
+	TypeRoomKick         = "room.kick"
+	TypeRoomBan          = "room.ban"
+)
+
+// Envelope types sent by the server, events use the domain.Event* types
+const (
+	TypeAck   = "ack"
+	TypeError = "error"
+)
+
+// Envelope wraps every frame sent over the WebSocket in both directions.
+// Acks and errors carry the ID of the client envelope they answer.
+type Envelope struct {
+	Version   int             `json:"v"`
+	Type      string          `json:"type"`
+	ID        string          `json:"id"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Timestamp time.Time       `json:"ts"`
+}
+
+func newEnvelope(typ, id string, payload any) (*Envelope, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	return &Envelope{
+		Version:   EnvelopeVersion,
+		Type:      typ,
+		ID:        id,
+		Payload:   data,
+		Timestamp: time.Now().UTC(),
+	}, nil
+}
+
+// eventEnvelope wraps a stored event, its ID is the sequence number clients
+// resume the event stream from
+func eventEnvelope(event *domain.StoredEvent) *Envelope {
+	return &Envelope{
+		Version:   EnvelopeVersion,
+		Type:      event.Type,
+		ID:        strconv.FormatInt(event.ID, 10),
+		Payload:   event.Payload,
+		Timestamp: event.CreatedAt.UTC(),
+	}
+}
+
+func encodeEnvelope(typ, id string, payload any) ([]byte, error) {
+	env, err := newEnvelope(typ, id, payload)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(env)
+}
@@ -0,0 +1,390 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/handler"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v3"
+	"github.com/valyala/fasthttp"
+	"go.uber.org/zap"
+)
+
+// operationTimeout bounds the work done for a single client envelope
+const operationTimeout = 10 * time.Second
+
+var invalidEnvelope = handler.NewProblem(fiber.NewError(fiber.StatusBadRequest, "Invalid envelope"))
+
+// operation handles one client envelope type and returns the ack payload
+type operation func(ctx context.Context, client *Client, payload json.RawMessage) (any, error)
+
+type Handler struct {
+	hub        *Hub
+	service    domain.ChatService
+	rooms      domain.RoomService
+	presence   domain.PresenceService
+	validator  *validator.Validator
+	cfg        Config
+	upgrader   websocket.FastHTTPUpgrader
+	operations map[string]operation
+	logger     *zap.Logger
+}
+
+func NewHandler(
+	hub *Hub,
+	service domain.ChatService,
+	rooms domain.RoomService,
+	presence domain.PresenceService,
+	validator *validator.Validator,
+	cfg Config,
+	logger *zap.Logger,
+) *Handler {
+	h := &Handler{
+		hub:       hub,
+		service:   service,
+		rooms:     rooms,
+		presence:  presence,
+		validator: validator,
+		cfg:       cfg,
+		upgrader: websocket.FastHTTPUpgrader{
+			// Connections are authenticated with a bearer token, not cookies,
+			// so cross-origin pages cannot act on behalf of a user
+			CheckOrigin: func(*fasthttp.RequestCtx) bool { return true },
+		},
+		logger: logger,
+	}
+	h.operations = map[string]operation{
+		TypeMessageSend:      h.sendMessage,
+		TypeMessageEdit:      h.editMessage,
+		TypeMessageDelete:    h.deleteMessage,
+		TypeReactionAdd:      h.addReaction,
+		TypeReactionRemove:   h.removeReaction,
+		TypeConversationRead: h.markRead,
+		TypePresenceSet:      h.setPresence,
+		TypeTypingStart:      h.startTyping,
+		TypeTypingStop:       h.stopTyping,
+		TypeRoomJoin:         h.joinRoom,
+		TypeRoomLeave:        h.leaveRoom,
+		TypeRoomInvite:       h.inviteToRoom,
+		TypeRoomKick:         h.kickFromRoom,
+		TypeRoomBan:          h.banFromRoom,
+	}
+	return h
+}
+
+// RegisterRoutes registers the streaming endpoints with streamAuthenticate,
+// which also accepts tokens from the query string, and the rest with authenticate
+func (h *Handler) RegisterRoutes(router fiber.Router, authenticate, streamAuthenticate fiber.Handler) {
+	router.Get("/ws", streamAuthenticate, h.Connect)
+	router.Get("/events", streamAuthenticate, h.Stream)
+	router.Post("/events", authenticate, h.Send)
+}
+
+func (h *Handler) Connect(c fiber.Ctx) error {
+	if !websocket.FastHTTPIsWebSocketUpgrade(c.RequestCtx()) {
+		return fiber.NewError(fiber.StatusUpgradeRequired, "WebSocket upgrade required")
+	}
+
+	claims := middleware.Claims(c)
+	if claims == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Missing bearer token")
+	}
+	userID := claims.UserID
+
+	// The upgrader writes its own response when the handshake fails
+	if err := h.upgrader.Upgrade(c.RequestCtx(), func(conn *websocket.Conn) {
+		h.serve(conn, userID)
+	}); err != nil {
+		h.logger.Debug("WebSocket handshake failed", zap.Error(err))
+	}
+	return nil
+}
+
+func (h *Handler) serve(conn *websocket.Conn, userID int) {
+	client := newClient(conn, userID, h.cfg, h.logger)
+	h.hub.register(client)
+	defer h.hub.unregister(client)
+
+	h.trackPresence(client)
+	defer h.untrackPresence(client)
+
+	h.logger.Info("WebSocket connected", zap.Int("user_id", userID))
+
+	written := make(chan struct{})
+	go func() {
+		defer close(written)
+		client.writePump()
+	}()
+
+	client.readPump(h.dispatch)
+	<-written
+
+	h.logger.Info("WebSocket disconnected", zap.Int("user_id", userID))
+}
+
+// trackPresence registers the connection for presence, chat keeps working
+// without it if the presence store is unavailable
+func (h *Handler) trackPresence(client *Client) {
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	id, err := h.presence.Connect(ctx, client.userID)
+	if err != nil {
+		h.logger.Warn("Failed to track WebSocket presence", zap.Int("user_id", client.userID), zap.Error(err))
+		return
+	}
+
+	client.presenceID = id
+	client.lastBeat = time.Now()
+	client.heartbeat = func() {
+		ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+		defer cancel()
+		if err := h.presence.Heartbeat(ctx, client.userID, id); err != nil {
+			h.logger.Warn("Failed to record WebSocket heartbeat", zap.Int("user_id", client.userID), zap.Error(err))
+		}
+	}
+}
+
+func (h *Handler) untrackPresence(client *Client) {
+	if client.presenceID == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	if err := h.presence.Disconnect(ctx, client.userID, client.presenceID); err != nil {
+		h.logger.Warn("Failed to untrack WebSocket presence", zap.Int("user_id", client.userID), zap.Error(err))
+	}
+}
+
+func (h *Handler) dispatch(client *Client, env *Envelope) {
+	typ, payload := h.handle(client, env)
+	client.reply(typ, env.ID, payload)
+}
+
+// handle runs the operation of a client envelope and returns the type and
+// payload of the reply, an ack with the result or an error with a problem
+func (h *Handler) handle(client *Client, env *Envelope) (typ string, payload any) {
+	// A panic here would take the whole process down, the connection is
+	// outside of Fiber's recover middleware
+	defer func() {
+		if r := recover(); r != nil {
+			h.logger.Error("Panic while handling envelope", zap.String("type", env.Type), zap.Any("panic", r))
+			typ, payload = TypeError, handler.NewProblem(fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	if env.Version != EnvelopeVersion {
+		return TypeError, handler.NewProblem(
+			fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Unsupported envelope version %d", env.Version)),
+		)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), operationTimeout)
+	defer cancel()
+
+	op, ok := h.operations[env.Type]
+	if !ok {
+		return TypeError, handler.NewProblem(
+			fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("Unknown envelope type %q", env.Type)),
+		)
+	}
+
+	result, err := op(ctx, client, env.Payload)
+	if err != nil {
+		problem := handler.NewProblem(err)
+		if problem.Status >= fiber.StatusInternalServerError {
+			h.logger.Error("Error handling envelope", zap.String("type", env.Type), zap.Error(err))
+		}
+		return TypeError, problem
+	}
+	return TypeAck, result
+}
+
+func (h *Handler) sendMessage(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(domain.SendMessageRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	return h.service.SendMessage(ctx, client.userID, req)
+}
+
+type editRequest struct {
+	MessageID int64  `json:"message_id" validate:"required,gt=0"`
+	Body      string `json:"body" validate:"required,max=4000"`
+}
+
+func (h *Handler) editMessage(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(editRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	return h.service.EditMessage(ctx, client.userID, req.MessageID, &domain.EditMessageRequest{Body: req.Body})
+}
+
+type messageRequest struct {
+	MessageID int64 `json:"message_id" validate:"required,gt=0"`
+}
+
+func (h *Handler) deleteMessage(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(messageRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	return req, h.service.DeleteMessage(ctx, client.userID, req.MessageID)
+}
+
+type reactionRequest struct {
+	MessageID int64  `json:"message_id" validate:"required,gt=0"`
+	Emoji     string `json:"emoji" validate:"required,max=32"`
+}
+
+func (h *Handler) addReaction(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(reactionRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	return req, h.service.AddReaction(ctx, client.userID, req.MessageID, &domain.ReactionRequest{Emoji: req.Emoji})
+}
+
+func (h *Handler) removeReaction(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(reactionRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	return req, h.service.RemoveReaction(ctx, client.userID, req.MessageID, req.Emoji)
+}
+
+type readRequest struct {
+	ConversationID int64 `json:"conversation_id" validate:"required,gt=0"`
+	MessageID      int64 `json:"message_id" validate:"required,gt=0"`
+}
+
+func (h *Handler) markRead(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(readRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	err := h.service.MarkRead(ctx, client.userID, req.ConversationID, &domain.MarkReadRequest{MessageID: req.MessageID})
+	return req, err
+}
+
+func (h *Handler) setPresence(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(domain.SetPresenceRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+	if client.presenceID == "" {
+		return nil, fiber.NewError(fiber.StatusServiceUnavailable, "Presence is unavailable")
+	}
+
+	return req, h.presence.SetStatus(ctx, client.userID, client.presenceID, req.Status)
+}
+
+type typingRequest struct {
+	ConversationID int64 `json:"conversation_id" validate:"required,gt=0"`
+}
+
+func (h *Handler) startTyping(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(typingRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	return req, h.presence.StartTyping(ctx, client.userID, req.ConversationID)
+}
+
+func (h *Handler) stopTyping(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(typingRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	return req, h.presence.StopTyping(ctx, client.userID, req.ConversationID)
+}
+
+// roomRequest is the payload of room envelopes, user_id is only used by
+// operations on other members
+type roomRequest struct {
+	RoomID int64 `json:"room_id" validate:"required,gt=0"`
+	UserID int   `json:"user_id" validate:"omitempty,gt=0"`
+}
+
+func (h *Handler) joinRoom(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(roomRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	return req, h.rooms.Join(ctx, client.userID, req.RoomID)
+}
+
+func (h *Handler) leaveRoom(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req := new(roomRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+
+	return req, h.rooms.Leave(ctx, client.userID, req.RoomID)
+}
+
+func (h *Handler) inviteToRoom(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req, err := h.decodeMember(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, h.rooms.Invite(ctx, client.userID, req.RoomID, req.UserID)
+}
+
+func (h *Handler) kickFromRoom(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req, err := h.decodeMember(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, h.rooms.Kick(ctx, client.userID, req.RoomID, req.UserID)
+}
+
+func (h *Handler) banFromRoom(ctx context.Context, client *Client, payload json.RawMessage) (any, error) {
+	req, err := h.decodeMember(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return req, h.rooms.Ban(ctx, client.userID, req.RoomID, req.UserID)
+}
+
+func (h *Handler) decodeMember(payload json.RawMessage) (*roomRequest, error) {
+	req := new(roomRequest)
+	if err := h.decode(payload, req); err != nil {
+		return nil, err
+	}
+	if req.UserID == 0 {
+		return nil, &domain.ValidationError{Fields: []domain.FieldError{
+			{Field: "user_id", Rule: "required", Message: "is required"},
+		}}
+	}
+	return req, nil
+}
+
+// decode unmarshals and validates a payload
+func (h *Handler) decode(payload json.RawMessage, v any) error {
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid payload")
+	}
+	return h.validator.Validate(v)
+}
@@ -0,0 +1,263 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/DMaryanskiy/go-idk/pkg/pubsub"
+	"github.com/fasthttp/websocket"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubTokens treats the token as the user id
+type stubTokens struct{}
+
+func (stubTokens) Generate(*domain.User) (string, time.Time, error) {
+	return "", time.Time{}, errors.New("not implemented")
+}
+
+func (stubTokens) Parse(token string) (*domain.AccessClaims, error) {
+	switch token {
+	case "1":
+		return &domain.AccessClaims{UserID: 1}, nil
+	case "2":
+		return &domain.AccessClaims{UserID: 2}, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+// stubChatService delivers messages through the hub without storing them
+type stubChatService struct {
+	domain.ChatService
+	hub *Hub
+}
+
+func (s *stubChatService) SendMessage(ctx context.Context, senderID int, req *domain.SendMessageRequest) (*domain.Message, error) {
+	if req.RecipientID == senderID {
+		return nil, domain.ErrMessageToSelf
+	}
+	msg := &domain.Message{ID: 1, ConversationID: 1, SenderID: senderID, Body: req.Body, CreatedAt: time.Now()}
+	err := s.hub.Publish(ctx, []int{senderID, req.RecipientID}, domain.Event{Type: domain.EventMessageNew, Payload: msg})
+	return msg, err
+}
+
+// stubPresenceService records the connections it is told about
+type stubPresenceService struct {
+	domain.PresenceService
+	mu          sync.Mutex
+	connected   map[string]int
+	nextID      int
+	typingUsers []int
+}
+
+func (s *stubPresenceService) Connect(_ context.Context, userID int) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	id := fmt.Sprintf("conn-%d", s.nextID)
+	s.connected[id] = userID
+	return id, nil
+}
+
+func (s *stubPresenceService) Heartbeat(context.Context, int, string) error {
+	return nil
+}
+
+func (s *stubPresenceService) Disconnect(_ context.Context, _ int, connectionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.connected, connectionID)
+	return nil
+}
+
+func (s *stubPresenceService) StartTyping(_ context.Context, userID int, _ int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.typingUsers = append(s.typingUsers, userID)
+	return nil
+}
+
+func (s *stubPresenceService) connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.connected)
+}
+
+type testServer struct {
+	hub      *Hub
+	presence *stubPresenceService
+	url      string
+	httpURL  string
+}
+
+func startServer(t *testing.T) *testServer {
+	t.Helper()
+
+	logger := zap.NewNop()
+	hub, err := NewHub(pubsub.NewMemory(), &memoryEvents{}, logger)
+	require.NoError(t, err)
+	presence := &stubPresenceService{connected: make(map[string]int)}
+	h := NewHandler(hub, &stubChatService{hub: hub}, nil, presence, validator.New(), Config{
+		PingInterval:   time.Second,
+		PongTimeout:    2 * time.Second,
+		WriteTimeout:   time.Second,
+		MaxMessageSize: 4096,
+	}, logger)
+
+	app := fiber.New()
+	h.RegisterRoutes(app, middleware.Auth(stubTokens{}), middleware.StreamAuth(stubTokens{}))
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() {
+		_ = app.Listener(ln, fiber.ListenConfig{DisableStartupMessage: true})
+	}()
+	t.Cleanup(func() {
+		hub.Close()
+		_ = app.Shutdown()
+	})
+
+	return &testServer{
+		hub:      hub,
+		presence: presence,
+		url:      "ws://" + ln.Addr().String() + "/ws",
+		httpURL:  "http://" + ln.Addr().String(),
+	}
+}
+
+func dial(t *testing.T, url, token string) *websocket.Conn {
+	t.Helper()
+
+	conn, resp, err := websocket.DefaultDialer.Dial(url+"?access_token="+token, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	t.Cleanup(func() { _ = conn.Close() })
+	return conn
+}
+
+// waitConnected waits for the hub to register a connection of userID, which
+// happens right after the handshake response is sent
+func waitConnected(t *testing.T, hub *Hub, userID int) {
+	t.Helper()
+
+	require.Eventually(t, func() bool {
+		hub.mu.RLock()
+		defer hub.mu.RUnlock()
+		return len(hub.clients[userID]) > 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func readEnvelope(t *testing.T, conn *websocket.Conn) *Envelope {
+	t.Helper()
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(2*time.Second)))
+	env := new(Envelope)
+	require.NoError(t, conn.ReadJSON(env))
+	return env
+}
+
+func TestDirectMessageDelivery(t *testing.T) {
+	srv := startServer(t)
+	alice := dial(t, srv.url, "1")
+	bob := dial(t, srv.url, "2")
+	waitConnected(t, srv.hub, 1)
+	waitConnected(t, srv.hub, 2)
+
+	require.NoError(t, alice.WriteJSON(Envelope{
+		Version: EnvelopeVersion,
+		Type:    TypeMessageSend,
+		ID:      "req-1",
+		Payload: json.RawMessage(`{"recipient_id": 2, "body": "hello"}`),
+	}))
+
+	received := readEnvelope(t, bob)
+	assert.Equal(t, domain.EventMessageNew, received.Type)
+	assert.Equal(t, EnvelopeVersion, received.Version)
+	assert.NotEmpty(t, received.ID)
+
+	var msg domain.Message
+	require.NoError(t, json.Unmarshal(received.Payload, &msg))
+	assert.Equal(t, 1, msg.SenderID)
+	assert.Equal(t, "hello", msg.Body)
+
+	// The sender gets the event for its other devices and the ack, in any order
+	types := map[string]string{}
+	for range 2 {
+		env := readEnvelope(t, alice)
+		types[env.Type] = env.ID
+	}
+	assert.Equal(t, "req-1", types[TypeAck])
+	assert.Contains(t, types, domain.EventMessageNew)
+}
+
+func TestInvalidEnvelopeReturnsError(t *testing.T) {
+	srv := startServer(t)
+	alice := dial(t, srv.url, "1")
+
+	require.NoError(t, alice.WriteJSON(Envelope{
+		Version: EnvelopeVersion,
+		Type:    TypeMessageSend,
+		ID:      "req-1",
+		Payload: json.RawMessage(`{"recipient_id": 2}`),
+	}))
+
+	env := readEnvelope(t, alice)
+	assert.Equal(t, TypeError, env.Type)
+	assert.Equal(t, "req-1", env.ID)
+
+	var problem struct {
+		Status int `json:"status"`
+		Errors []struct {
+			Field string `json:"field"`
+		} `json:"errors"`
+	}
+	require.NoError(t, json.Unmarshal(env.Payload, &problem))
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	require.Len(t, problem.Errors, 1)
+	assert.Equal(t, "body", problem.Errors[0].Field)
+}
+
+func TestConnectRequiresToken(t *testing.T) {
+	srv := startServer(t)
+
+	_, resp, err := websocket.DefaultDialer.Dial(srv.url, nil)
+
+	assert.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
+
+func TestPresenceFollowsConnection(t *testing.T) {
+	srv := startServer(t)
+	alice := dial(t, srv.url, "1")
+	waitConnected(t, srv.hub, 1)
+	assert.Equal(t, 1, srv.presence.connections())
+
+	require.NoError(t, alice.WriteJSON(Envelope{
+		Version: EnvelopeVersion,
+		Type:    TypeTypingStart,
+		ID:      "req-1",
+		Payload: json.RawMessage(`{"conversation_id": 5}`),
+	}))
+	env := readEnvelope(t, alice)
+	assert.Equal(t, TypeAck, env.Type)
+	assert.Equal(t, []int{1}, srv.presence.typingUsers)
+
+	require.NoError(t, alice.Close())
+	require.Eventually(t, func() bool {
+		return srv.presence.connections() == 0
+	}, 2*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,313 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/pubsub"
+	"go.uber.org/zap"
+)
+
+// eventsChannel is the pub/sub channel every instance listens on for chat
+// events. Notifications only carry the ID of the stored event, messages and
+// recipient lists easily outgrow what a notification may hold.
+const eventsChannel = "chat_events"
+
+// transientChannel carries the events that are not stored, they go out whole
+const transientChannel = "chat_transient"
+
+// transientBatchSize is how many recipients share one transient notification,
+// it keeps notifications below the NOTIFY payload limit
+const transientBatchSize = 500
+
+// transientEvents are only worth something live. They are not stored, a
+// resumed client would otherwise be shown typing indicators and presence
+// that are no longer true.
+var transientEvents = map[string]bool{
+	domain.EventTypingStarted:   true,
+	domain.EventTypingStopped:   true,
+	domain.EventPresenceChanged: true,
+}
+
+// broadcast is a transient event on its way to the instances holding the
+// recipients' connections
+type broadcast struct {
+	UserIDs  []int           `json:"user_ids"`
+	Envelope json.RawMessage `json:"envelope"`
+}
+
+// replayPageSize is how many stored events are loaded at once when a client
+// resumes or notified events are delivered
+const replayPageSize = 500
+
+// pendingSize is how many notified events may wait to be loaded, events
+// notified beyond that are dropped
+const pendingSize = 1024
+
+// Hub keeps track of the WebSocket and event stream connections of this
+// instance and delivers events to them. A user may be connected from several
+// devices at once, to several instances, so events go through pub/sub and
+// every instance loads them and delivers them to its own clients. Events that
+// change state are stored before they are published, so event stream clients
+// can resume after reconnecting.
+type Hub struct {
+	mu      sync.RWMutex
+	clients map[int]map[*Client]struct{}
+	bus     pubsub.PubSub
+	events  domain.EventRepository
+	pending chan int64
+	// delivered is the ID of the last stored event delivered, only run uses it
+	delivered int64
+	done      chan struct{}
+	closeOnce sync.Once
+	logger    *zap.Logger
+}
+
+func NewHub(bus pubsub.PubSub, events domain.EventRepository, logger *zap.Logger) (*Hub, error) {
+	h := &Hub{
+		clients: make(map[int]map[*Client]struct{}),
+		bus:     bus,
+		events:  events,
+		pending: make(chan int64, pendingSize),
+		done:    make(chan struct{}),
+		logger:  logger,
+	}
+
+	// Events stored before the hub started are not delivered live
+	latest, err := events.GetLatestID(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("error getting latest event: %w", err)
+	}
+	h.delivered = latest
+
+	if err := bus.Subscribe(eventsChannel, h.receive); err != nil {
+		return nil, fmt.Errorf("error subscribing to chat events: %w", err)
+	}
+	if err := bus.Subscribe(transientChannel, h.receiveTransient); err != nil {
+		return nil, fmt.Errorf("error subscribing to transient chat events: %w", err)
+	}
+	go h.run()
+	return h, nil
+}
+
+// Publish implements domain.EventPublisher
+func (h *Hub) Publish(ctx context.Context, userIDs []int, event domain.Event) error {
+	if transientEvents[event.Type] {
+		return h.publishTransient(ctx, userIDs, event)
+	}
+
+	payload, err := json.Marshal(event.Payload)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %w", err)
+	}
+
+	stored := &domain.StoredEvent{UserIDs: userIDs, Type: event.Type, Payload: payload}
+	if err := h.events.Create(ctx, stored); err != nil {
+		return fmt.Errorf("error storing event: %w", err)
+	}
+
+	if err := h.bus.Publish(ctx, eventsChannel, []byte(strconv.FormatInt(stored.ID, 10))); err != nil {
+		return fmt.Errorf("error publishing event: %w", err)
+	}
+	return nil
+}
+
+func (h *Hub) publishTransient(ctx context.Context, userIDs []int, event domain.Event) error {
+	data, err := encodeEnvelope(event.Type, "", event.Payload)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %w", err)
+	}
+
+	for batch := range slices.Chunk(userIDs, transientBatchSize) {
+		payload, err := json.Marshal(broadcast{UserIDs: batch, Envelope: data})
+		if err != nil {
+			return fmt.Errorf("error encoding broadcast: %w", err)
+		}
+		if err := h.bus.Publish(ctx, transientChannel, payload); err != nil {
+			return fmt.Errorf("error publishing event: %w", err)
+		}
+	}
+	return nil
+}
+
+// replay returns the envelopes of the events of the user after afterID, oldest first
+func (h *Hub) replay(ctx context.Context, userID int, afterID int64) ([]*Envelope, error) {
+	envelopes := []*Envelope{}
+	for {
+		events, err := h.events.GetSince(ctx, userID, afterID, replayPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("error replaying events: %w", err)
+		}
+		for i := range events {
+			envelopes = append(envelopes, eventEnvelope(&events[i]))
+		}
+		if len(events) < replayPageSize {
+			return envelopes, nil
+		}
+		afterID = events[len(events)-1].ID
+	}
+}
+
+// Prune removes stored events older than retention, clients resuming from
+// them miss the events in between and reload from the REST API
+func (h *Hub) Prune(ctx context.Context, retention time.Duration) error {
+	removed, err := h.events.DeleteBefore(ctx, time.Now().Add(-retention))
+	if err != nil {
+		h.logger.Error("Error pruning events", zap.Error(err))
+		return fmt.Errorf("failed to prune events: %w", err)
+	}
+
+	if removed > 0 {
+		h.logger.Info("Events pruned", zap.Int64("count", removed))
+	}
+	return nil
+}
+
+// receive queues a notified event for run, pub/sub handlers must not block
+// on the database
+func (h *Hub) receive(payload []byte) {
+	id, err := strconv.ParseInt(string(payload), 10, 64)
+	if err != nil {
+		h.logger.Warn("Dropping malformed chat notification", zap.Error(err))
+		return
+	}
+
+	select {
+	case h.pending <- id:
+	default:
+		h.logger.Warn("Dropping chat event, too many are waiting to be loaded", zap.Int64("event_id", id))
+	}
+}
+
+func (h *Hub) receiveTransient(payload []byte) {
+	var msg broadcast
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		h.logger.Warn("Dropping malformed chat broadcast", zap.Error(err))
+		return
+	}
+
+	h.deliver(msg.UserIDs, msg.Envelope)
+}
+
+// run delivers notified events until the hub is closed. Events notified
+// while the previous ones loaded are loaded together.
+func (h *Hub) run() {
+	for {
+		select {
+		case <-h.done:
+			return
+		case id := <-h.pending:
+			target := id
+		drain:
+			for {
+				select {
+				case id := <-h.pending:
+					target = max(target, id)
+				default:
+					break drain
+				}
+			}
+			h.load(target)
+		}
+	}
+}
+
+// load delivers the stored events after the last delivered one, in ID order,
+// up to target at least. IDs follow commit order, so every event up to a
+// notified one is stored already. Notifications that arrive out of order or
+// were dropped still deliver every event once and in order. Only events of
+// the users connected to this instance are loaded.
+func (h *Hub) load(target int64) {
+	if target <= h.delivered {
+		return
+	}
+
+	h.mu.RLock()
+	connected := make([]int, 0, len(h.clients))
+	for userID := range h.clients {
+		connected = append(connected, userID)
+	}
+	h.mu.RUnlock()
+
+	// An instance without connections has nobody to deliver to
+	if len(connected) == 0 {
+		h.delivered = target
+		return
+	}
+
+	for {
+		events, err := h.events.GetAfter(context.Background(), h.delivered, connected, replayPageSize)
+		if err != nil {
+			// The events are loaded with the next notification
+			h.logger.Error("Error loading chat events", zap.Int64("after_id", h.delivered), zap.Error(err))
+			return
+		}
+
+		for i := range events {
+			data, err := json.Marshal(eventEnvelope(&events[i]))
+			if err != nil {
+				h.logger.Error("Error encoding event", zap.Int64("event_id", events[i].ID), zap.Error(err))
+			} else {
+				h.deliver(events[i].UserIDs, data)
+			}
+			h.delivered = events[i].ID
+		}
+		if len(events) < replayPageSize {
+			break
+		}
+	}
+	// Pruned events are not waited for
+	h.delivered = max(h.delivered, target)
+}
+
+func (h *Hub) deliver(userIDs []int, data []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, userID := range userIDs {
+		for client := range h.clients[userID] {
+			client.enqueue(data)
+		}
+	}
+}
+
+func (h *Hub) register(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.clients[client.userID] == nil {
+		h.clients[client.userID] = make(map[*Client]struct{})
+	}
+	h.clients[client.userID][client] = struct{}{}
+}
+
+func (h *Hub) unregister(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.clients[client.userID], client)
+	if len(h.clients[client.userID]) == 0 {
+		delete(h.clients, client.userID)
+	}
+}
+
+// Close stops delivering events and disconnects every client, it is called
+// on shutdown
+func (h *Hub) Close() {
+	h.closeOnce.Do(func() { close(h.done) })
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for _, clients := range h.clients {
+		for client := range clients {
+			client.close()
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package chat
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/pubsub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// memoryEvents is an EventRepository shared by the hubs of a test
+type memoryEvents struct {
+	mu     sync.Mutex
+	events []domain.StoredEvent
+	// loadedFor is the users the last GetAfter was asked for
+	loadedFor []int
+}
+
+func (m *memoryEvents) Create(_ context.Context, event *domain.StoredEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	event.ID = int64(len(m.events) + 1)
+	event.CreatedAt = time.Now()
+	m.events = append(m.events, *event)
+	return nil
+}
+
+func (m *memoryEvents) GetLatestID(context.Context) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.events) == 0 {
+		return 0, nil
+	}
+	return m.events[len(m.events)-1].ID, nil
+}
+
+func (m *memoryEvents) GetAfter(_ context.Context, afterID int64, userIDs []int, limit int) ([]domain.StoredEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loadedFor = userIDs
+	events := []domain.StoredEvent{}
+	for _, event := range m.events {
+		reaches := slices.ContainsFunc(event.UserIDs, func(id int) bool { return slices.Contains(userIDs, id) })
+		if event.ID > afterID && reaches && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *memoryEvents) GetSince(_ context.Context, userID int, afterID int64, limit int) ([]domain.StoredEvent, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := []domain.StoredEvent{}
+	for _, event := range m.events {
+		if event.ID > afterID && slices.Contains(event.UserIDs, userID) && len(events) < limit {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+func (m *memoryEvents) DeleteBefore(_ context.Context, before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	kept := m.events[:0]
+	for _, event := range m.events {
+		if !event.CreatedAt.Before(before) {
+			kept = append(kept, event)
+		}
+	}
+	removed := int64(len(m.events) - len(kept))
+	m.events = kept
+	return removed, nil
+}
+
+// postgresBus is a memory pub/sub that refuses payloads NOTIFY would refuse
+type postgresBus struct {
+	*pubsub.Memory
+}
+
+func (b postgresBus) Publish(ctx context.Context, channel string, payload []byte) error {
+	if len(payload) > pubsub.MaxPayloadSize {
+		return pubsub.ErrPayloadTooLarge
+	}
+	return b.Memory.Publish(ctx, channel, payload)
+}
+
+// receiveEnvelope waits for the next envelope sent to the client
+func receiveEnvelope(t *testing.T, client *Client) *Envelope {
+	t.Helper()
+
+	select {
+	case data := <-client.send:
+		env := new(Envelope)
+		require.NoError(t, json.Unmarshal(data, env))
+		return env
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an envelope")
+		return nil
+	}
+}
+
+func TestHubDeliversAcrossInstances(t *testing.T) {
+	bus := pubsub.NewMemory()
+	events := &memoryEvents{}
+	logger := zap.NewNop()
+	first, err := NewHub(bus, events, logger)
+	require.NoError(t, err)
+	second, err := NewHub(bus, events, logger)
+	require.NoError(t, err)
+
+	alice := newClient(nil, 1, Config{}, logger)
+	bob := newClient(nil, 2, Config{}, logger)
+	first.register(alice)
+	second.register(bob)
+
+	err = first.Publish(context.Background(), []int{2}, domain.Event{Type: domain.EventMessageNew, Payload: "hi"})
+	require.NoError(t, err)
+
+	env := receiveEnvelope(t, bob)
+	assert.Equal(t, domain.EventMessageNew, env.Type)
+	assert.Empty(t, alice.send)
+}
+
+func TestHubDeliversEventsLargerThanNotifications(t *testing.T) {
+	logger := zap.NewNop()
+	hub, err := NewHub(postgresBus{pubsub.NewMemory()}, &memoryEvents{}, logger)
+	require.NoError(t, err)
+
+	bob := newClient(nil, 2, Config{}, logger)
+	hub.register(bob)
+
+	// Multibyte and escaped characters make the body far larger than its
+	// length in runes, the recipients alone would not fit either
+	body := strings.Repeat("ж<", 2000)
+	recipients := make([]int, 2000)
+	for i := range recipients {
+		recipients[i] = i + 2
+	}
+
+	err = hub.Publish(context.Background(), recipients, domain.Event{Type: domain.EventMessageNew, Payload: body})
+	require.NoError(t, err)
+
+	env := receiveEnvelope(t, bob)
+	var received string
+	require.NoError(t, json.Unmarshal(env.Payload, &received))
+	assert.Equal(t, body, received)
+}
+
+func TestHubDeliversInIDOrder(t *testing.T) {
+	logger := zap.NewNop()
+	events := &memoryEvents{}
+	hub, err := NewHub(pubsub.NewMemory(), events, logger)
+	require.NoError(t, err)
+
+	bob := newClient(nil, 2, Config{}, logger)
+	hub.register(bob)
+
+	ctx := context.Background()
+	for _, body := range []string{"first", "second"} {
+		require.NoError(t, events.Create(ctx, &domain.StoredEvent{UserIDs: []int{2}, Type: domain.EventMessageNew, Payload: json.RawMessage(`"` + body + `"`)}))
+	}
+
+	// The notification of the second event arrives first
+	hub.receive([]byte("2"))
+	hub.receive([]byte("1"))
+
+	assert.Equal(t, "1", receiveEnvelope(t, bob).ID)
+	assert.Equal(t, "2", receiveEnvelope(t, bob).ID)
+	assert.Never(t, func() bool { return len(bob.send) > 0 }, 100*time.Millisecond, 10*time.Millisecond)
+}
+
+func TestHubLoadsEventsOfConnectedUsers(t *testing.T) {
+	logger := zap.NewNop()
+	events := &memoryEvents{}
+	hub, err := NewHub(pubsub.NewMemory(), events, logger)
+	require.NoError(t, err)
+
+	bob := newClient(nil, 2, Config{}, logger)
+	hub.register(bob)
+
+	ctx := context.Background()
+	require.NoError(t, hub.Publish(ctx, []int{1}, domain.Event{Type: domain.EventMessageNew, Payload: "other"}))
+	require.NoError(t, hub.Publish(ctx, []int{1, 2}, domain.Event{Type: domain.EventMessageNew, Payload: "shared"}))
+
+	assert.Equal(t, "2", receiveEnvelope(t, bob).ID)
+	events.mu.Lock()
+	defer events.mu.Unlock()
+	assert.Equal(t, []int{2}, events.loadedFor)
+}
+
+func TestHubReplaysStoredEvents(t *testing.T) {
+	logger := zap.NewNop()
+	hub, err := NewHub(pubsub.NewMemory(), &memoryEvents{}, logger)
+	require.NoError(t, err)
+
+	bob := newClient(nil, 2, Config{}, logger)
+	hub.register(bob)
+
+	ctx := context.Background()
+	require.NoError(t, hub.Publish(ctx, []int{2}, domain.Event{Type: domain.EventMessageNew, Payload: "first"}))
+	require.NoError(t, hub.Publish(ctx, []int{1}, domain.Event{Type: domain.EventMessageNew, Payload: "other"}))
+	require.NoError(t, hub.Publish(ctx, []int{2}, domain.Event{Type: domain.EventMessageNew, Payload: "second"}))
+
+	first := receiveEnvelope(t, bob)
+	assert.Equal(t, "1", first.ID)
+
+	envelopes, err := hub.replay(ctx, 2, 1)
+
+	require.NoError(t, err)
+	require.Len(t, envelopes, 1)
+	assert.Equal(t, "3", envelopes[0].ID)
+	assert.JSONEq(t, `"second"`, string(envelopes[0].Payload))
+}
+
+func TestHubDoesNotStoreTransientEvents(t *testing.T) {
+	logger := zap.NewNop()
+	events := &memoryEvents{}
+	hub, err := NewHub(postgresBus{pubsub.NewMemory()}, events, logger)
+	require.NoError(t, err)
+
+	bob := newClient(nil, 2, Config{}, logger)
+	hub.register(bob)
+
+	// Enough recipients to need several notifications
+	recipients := make([]int, 2000)
+	for i := range recipients {
+		recipients[i] = i + 1
+	}
+
+	ctx := context.Background()
+	err = hub.Publish(ctx, recipients, domain.Event{Type: domain.EventTypingStarted, Payload: "typing"})
+	require.NoError(t, err)
+
+	env := receiveEnvelope(t, bob)
+	assert.Equal(t, domain.EventTypingStarted, env.Type)
+
+	envelopes, err := hub.replay(ctx, 2, 0)
+	require.NoError(t, err)
+	assert.Empty(t, envelopes)
+}
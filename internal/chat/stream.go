@@ -0,0 +1,192 @@
+package chat
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/handler"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+// Stream sends events over Server-Sent Events to clients behind proxies that
+// do not pass WebSockets. Every event is the envelope the WebSocket would
+// send, its ID is the SSE event id. Browsers send the last one back as
+// Last-Event-ID when they reconnect and the events missed in between are
+// replayed from the stored ones.
+func (h *Handler) Stream(c fiber.Ctx) error {
+	claims := middleware.Claims(c)
+	if claims == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Missing bearer token")
+	}
+	lastID, err := lastEventID(c)
+	if err != nil {
+		return err
+	}
+
+	// Registering before loading the backlog leaves no gap between the two,
+	// events that arrive both ways are skipped by their ID
+	client := newClient(nil, claims.UserID, h.cfg, h.logger)
+	h.hub.register(client)
+
+	var backlog []*Envelope
+	if lastID > 0 {
+		backlog, err = h.hub.replay(c.Context(), claims.UserID, lastID)
+		if err != nil {
+			h.hub.unregister(client)
+			return err
+		}
+	}
+
+	c.Set(fiber.HeaderContentType, "text/event-stream")
+	c.Set(fiber.HeaderCacheControl, "no-cache")
+	// Keeps reverse proxies such as nginx from buffering the stream
+	c.Set("X-Accel-Buffering", "no")
+
+	conn := c.RequestCtx().Conn()
+	return c.SendStreamWriter(func(w *bufio.Writer) {
+		h.stream(client, conn, w, backlog, lastID)
+	})
+}
+
+// Send runs a client envelope posted over HTTP and answers with the ack or
+// error envelope the WebSocket would send. Presence is tracked per
+// connection, so presence.set is only available over the WebSocket.
+func (h *Handler) Send(c fiber.Ctx) error {
+	env := new(Envelope)
+	if err := json.Unmarshal(c.Body(), env); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid envelope")
+	}
+
+	client := newClient(nil, middleware.Claims(c).UserID, h.cfg, h.logger)
+	typ, payload := h.handle(client, env)
+
+	reply, err := newEnvelope(typ, env.ID, payload)
+	if err != nil {
+		return fmt.Errorf("error encoding reply: %w", err)
+	}
+
+	status := fiber.StatusOK
+	if problem, ok := payload.(*handler.Problem); ok {
+		status = problem.Status
+	}
+	return c.Status(status).JSON(reply)
+}
+
+// stream writes events to an event stream client until it disconnects, it
+// runs after the handler returned
+func (h *Handler) stream(client *Client, conn net.Conn, w *bufio.Writer, backlog []*Envelope, lastID int64) {
+	defer h.hub.unregister(client)
+	defer client.close()
+
+	h.trackPresence(client)
+	defer h.untrackPresence(client)
+
+	h.logger.Info("Event stream connected", zap.Int("user_id", client.userID))
+	defer h.logger.Info("Event stream disconnected", zap.Int("user_id", client.userID))
+
+	// The server write timeout covers the whole response, it is pushed back
+	// before every write so only a stuck client is cut off
+	write := func(frame string) bool {
+		if conn != nil && h.cfg.WriteTimeout > 0 {
+			_ = conn.SetWriteDeadline(time.Now().Add(h.cfg.WriteTimeout))
+		}
+		if _, err := w.WriteString(frame); err != nil {
+			return false
+		}
+		return w.Flush() == nil
+	}
+
+	// Sends the headers right away so the client knows the stream is open
+	if !write(": connected\n\n") {
+		return
+	}
+
+	for _, env := range backlog {
+		data, err := json.Marshal(env)
+		if err != nil {
+			h.logger.Error("Error encoding event", zap.String("type", env.Type), zap.Error(err))
+			continue
+		}
+		if !write(eventFrame(env.ID, data)) {
+			return
+		}
+		if id, err := strconv.ParseInt(env.ID, 10, 64); err == nil {
+			lastID = id
+		}
+	}
+
+	ticker := time.NewTicker(h.cfg.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case data := <-client.send:
+			var env struct {
+				ID string `json:"id"`
+			}
+			if err := json.Unmarshal(data, &env); err != nil {
+				continue
+			}
+			id, err := strconv.ParseInt(env.ID, 10, 64)
+			if err != nil {
+				// Transient events are not stored, so there is nothing to
+				// resume from them and they go out without an id
+				if !write(eventFrame("", data)) {
+					return
+				}
+				continue
+			}
+			if id <= lastID {
+				continue
+			}
+			if !write(eventFrame(env.ID, data)) {
+				return
+			}
+			lastID = id
+		case <-ticker.C:
+			// Comments keep proxies from closing idle streams, a successful
+			// write also shows the client is still there
+			if !write(": ping\n\n") {
+				return
+			}
+			client.beat()
+		case <-client.done:
+			return
+		}
+	}
+}
+
+// eventFrame formats an envelope as an SSE event, encoded envelopes never
+// contain newlines. Events without an id leave the client's last event id as
+// it was.
+func eventFrame(id string, data []byte) string {
+	if id == "" {
+		return "data: " + string(data) + "\n\n"
+	}
+	return "id: " + id + "\ndata: " + string(data) + "\n\n"
+}
+
+// lastEventID reads the ID of the last event the client received. Browsers
+// send the Last-Event-ID header on reconnects, the last_event_id query
+// parameter lets clients resume on their first request too.
+func lastEventID(c fiber.Ctx) (int64, error) {
+	raw := c.Get("Last-Event-ID")
+	if raw == "" {
+		raw = c.Query("last_event_id")
+	}
+	if raw == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id < 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid Last-Event-ID")
+	}
+	return id, nil
+}
@@ -0,0 +1,162 @@
+package chat
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sseEvent is a single event read from an event stream
+type sseEvent struct {
+	id       string
+	envelope Envelope
+}
+
+func openStream(t *testing.T, srv *testServer, token, lastEventID string) *bufio.Reader {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodGet, srv.httpURL+"/events?access_token="+token, nil)
+	require.NoError(t, err)
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = resp.Body.Close() })
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	return bufio.NewReader(resp.Body)
+}
+
+// readEvent returns the next event of the stream, skipping comments
+func readEvent(t *testing.T, r *bufio.Reader) sseEvent {
+	t.Helper()
+
+	result := make(chan sseEvent, 1)
+	go func() {
+		var event sseEvent
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				close(result)
+				return
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "id: "):
+				event.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "data: "):
+				_ = json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event.envelope)
+			case line == "" && event.id != "":
+				result <- event
+				return
+			}
+		}
+	}()
+
+	select {
+	case event, ok := <-result:
+		require.True(t, ok, "event stream closed")
+		return event
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for an event")
+		return sseEvent{}
+	}
+}
+
+func TestEventStreamResumesFromLastEventID(t *testing.T) {
+	srv := startServer(t)
+	ctx := context.Background()
+	for _, body := range []string{"first", "second"} {
+		require.NoError(t, srv.hub.Publish(ctx, []int{2}, domain.Event{Type: domain.EventMessageNew, Payload: body}))
+	}
+
+	stream := openStream(t, srv, "2", "1")
+
+	replayed := readEvent(t, stream)
+	assert.Equal(t, "2", replayed.id)
+	assert.Equal(t, replayed.id, replayed.envelope.ID)
+	assert.Equal(t, domain.EventMessageNew, replayed.envelope.Type)
+	assert.JSONEq(t, `"second"`, string(replayed.envelope.Payload))
+
+	waitConnected(t, srv.hub, 2)
+	require.NoError(t, srv.hub.Publish(ctx, []int{2}, domain.Event{Type: domain.EventMessageNew, Payload: "third"}))
+
+	live := readEvent(t, stream)
+	assert.Equal(t, "3", live.id)
+	assert.JSONEq(t, `"third"`, string(live.envelope.Payload))
+}
+
+func TestEventStreamRejectsInvalidLastEventID(t *testing.T) {
+	srv := startServer(t)
+
+	req, err := http.NewRequest(http.MethodGet, srv.httpURL+"/events?access_token=1", nil)
+	require.NoError(t, err)
+	req.Header.Set("Last-Event-ID", "abc")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestSendOverHTTP(t *testing.T) {
+	srv := startServer(t)
+	stream := openStream(t, srv, "2", "")
+	waitConnected(t, srv.hub, 2)
+
+	body, err := json.Marshal(Envelope{
+		Version: EnvelopeVersion,
+		Type:    TypeMessageSend,
+		ID:      "req-1",
+		Payload: json.RawMessage(`{"recipient_id": 2, "body": "hello"}`),
+	})
+	require.NoError(t, err)
+	req, err := http.NewRequest(http.MethodPost, srv.httpURL+"/events", bytes.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer 1")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	reply := new(Envelope)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(reply))
+	assert.Equal(t, TypeAck, reply.Type)
+	assert.Equal(t, "req-1", reply.ID)
+
+	received := readEvent(t, stream)
+	assert.Equal(t, domain.EventMessageNew, received.envelope.Type)
+}
+
+func TestSendOverHTTPReturnsProblem(t *testing.T) {
+	srv := startServer(t)
+
+	body := `{"v": 1, "type": "message.send", "id": "req-1", "payload": {"recipient_id": 1, "body": "hi"}}`
+	req, err := http.NewRequest(http.MethodPost, srv.httpURL+"/events", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer 1")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	reply := new(Envelope)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(reply))
+	assert.Equal(t, TypeError, reply.Type)
+	assert.Equal(t, "req-1", reply.ID)
+}
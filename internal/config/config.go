@@ -1,41 +1,133 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"strconv"
 	"time"
 )
 
 type Config struct {
-	Port                string
-	DatabaseURL         string
-	DBMaxOpenConns      int
-	DBMaxIdleConns      int
-	DBConnMaxLifetime   time.Duration
-	ReadTimeout         time.Duration
-	WriteTimeout        time.Duration
-	IdleTimeout         time.Duration
-	RateLimitMax        int
-	RateLimitExpiration time.Duration
-	CORSOrigins         string
+	Port                       string
+	DatabaseURL                string
+	DBMaxOpenConns             int
+	DBMaxIdleConns             int
+	DBConnMaxLifetime          time.Duration
+	ReadTimeout                time.Duration
+	WriteTimeout               time.Duration
+	IdleTimeout                time.Duration
+	BodyLimit                  int
+	RateLimitMax               int
+	RateLimitExpiration        time.Duration
+	CORSOrigins                string
+	MigrationMode              string
+	BcryptCost                 int
+	JWTAlgorithm               string
+	JWTSecret                  string
+	JWTPrivateKey              string
+	JWTIssuer                  string
+	CursorSecret               string
+	AccessTokenTTL             time.Duration
+	RefreshTokenTTL            time.Duration
+	Mailer                     string
+	MailFrom                   string
+	MailDir                    string
+	SMTPHost                   string
+	SMTPPort                   int
+	SMTPUsername               string
+	SMTPPassword               string
+	VerificationCodeTTL        time.Duration
+	VerificationResendInterval time.Duration
+	VerificationMaxAttempts    int
+	PasswordResetTTL           time.Duration
+	PasswordResetURL           string
+	WSPingInterval             time.Duration
+	WSPongTimeout              time.Duration
+	WSMaxMessageSize           int
+	PubSub                     string
+	PresenceTimeout            time.Duration
+	TypingTTL                  time.Duration
+	TypingInterval             time.Duration
+	MessageEditWindow          time.Duration
+	EventRetention             time.Duration
+	DeletedUserRetention       time.Duration
+	IdempotencyTTL             time.Duration
+	IdempotencyLockTimeout     time.Duration
+	ImportBatchSize            int
+	ImportAsyncSize            int
+	ImportBodyLimit            int
+	ImportStaleAfter           time.Duration
+	ImportRetention            time.Duration
 }
 
 func Load() *Config {
 	return &Config{
-		Port:                getEnv("PORT", "3000"),
-		DatabaseURL:         getEnv("DATABASE_URL", ""),
-		DBMaxOpenConns:      getEnvInt("DB_MAX_OPEN_CONNS", 25),
-		DBMaxIdleConns:      getEnvInt("DB_MAX_IDLE_CONNS", 5),
-		DBConnMaxLifetime:   getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
-		ReadTimeout:         getEnvDuration("READ_TIMEOUT", 10*time.Second),
-		WriteTimeout:        getEnvDuration("WRITE_DURATION", 10*time.Second),
-		IdleTimeout:         getEnvDuration("IDLE_TIMEOUT", 120*time.Second),
-		RateLimitMax:        getEnvInt("RATE_LIMIT_MAX", 100),
-		RateLimitExpiration: getEnvDuration("RATE_LIMIT_EXPIRATION", 1*time.Minute),
-		CORSOrigins:         getEnv("CORS_ORIGINS", "*"),
+		Port:                       getEnv("PORT", "3000"),
+		DatabaseURL:                getEnv("DATABASE_URL", ""),
+		DBMaxOpenConns:             getEnvInt("DB_MAX_OPEN_CONNS", 25),
+		DBMaxIdleConns:             getEnvInt("DB_MAX_IDLE_CONNS", 5),
+		DBConnMaxLifetime:          getEnvDuration("DB_CONN_MAX_LIFETIME", 5*time.Minute),
+		ReadTimeout:                getEnvDuration("READ_TIMEOUT", 10*time.Second),
+		WriteTimeout:               getEnvDuration("WRITE_DURATION", 10*time.Second),
+		IdleTimeout:                getEnvDuration("IDLE_TIMEOUT", 120*time.Second),
+		BodyLimit:                  getEnvInt("BODY_LIMIT", 4*1024*1024),
+		RateLimitMax:               getEnvInt("RATE_LIMIT_MAX", 100),
+		RateLimitExpiration:        getEnvDuration("RATE_LIMIT_EXPIRATION", 1*time.Minute),
+		CORSOrigins:                getEnv("CORS_ORIGINS", "*"),
+		MigrationMode:              getEnv("MIGRATION_MODE", "auto"),
+		BcryptCost:                 getEnvInt("BCRYPT_COST", 12),
+		JWTAlgorithm:               getEnv("JWT_ALGORITHM", "HS256"),
+		JWTSecret:                  getEnv("JWT_SECRET", ""),
+		JWTPrivateKey:              getEnv("JWT_PRIVATE_KEY", ""),
+		JWTIssuer:                  getEnv("JWT_ISSUER", "go-idk"),
+		CursorSecret:               getEnv("CURSOR_SECRET", ""),
+		AccessTokenTTL:             getEnvDuration("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:            getEnvDuration("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		Mailer:                     getEnv("MAILER", "file"),
+		MailFrom:                   getEnv("MAIL_FROM", "no-reply@go-idk.local"),
+		MailDir:                    getEnv("MAIL_DIR", "tmp/mail"),
+		SMTPHost:                   getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:                   getEnvInt("SMTP_PORT", 1025),
+		SMTPUsername:               getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:               getEnv("SMTP_PASSWORD", ""),
+		VerificationCodeTTL:        getEnvDuration("VERIFICATION_CODE_TTL", 15*time.Minute),
+		VerificationResendInterval: getEnvDuration("VERIFICATION_RESEND_INTERVAL", time.Minute),
+		VerificationMaxAttempts:    getEnvInt("VERIFICATION_MAX_ATTEMPTS", 5),
+		PasswordResetTTL:           getEnvDuration("PASSWORD_RESET_TTL", 30*time.Minute),
+		PasswordResetURL:           getEnv("PASSWORD_RESET_URL", "http://localhost:3000/reset-password"),
+		WSPingInterval:             getEnvDuration("WS_PING_INTERVAL", 25*time.Second),
+		WSPongTimeout:              getEnvDuration("WS_PONG_TIMEOUT", 60*time.Second),
+		WSMaxMessageSize:           getEnvInt("WS_MAX_MESSAGE_SIZE", 16*1024),
+		PubSub:                     getEnv("PUBSUB", "postgres"),
+		PresenceTimeout:            getEnvDuration("PRESENCE_TIMEOUT", 90*time.Second),
+		TypingTTL:                  getEnvDuration("TYPING_TTL", 6*time.Second),
+		TypingInterval:             getEnvDuration("TYPING_INTERVAL", 2*time.Second),
+		MessageEditWindow:          getEnvDuration("MESSAGE_EDIT_WINDOW", 15*time.Minute),
+		EventRetention:             getEnvDuration("EVENT_RETENTION", 24*time.Hour),
+		DeletedUserRetention:       getEnvDuration("DELETED_USER_RETENTION", 30*24*time.Hour),
+		IdempotencyTTL:             getEnvDuration("IDEMPOTENCY_TTL", 24*time.Hour),
+		IdempotencyLockTimeout:     getEnvDuration("IDEMPOTENCY_LOCK_TIMEOUT", time.Minute),
+		ImportBatchSize:            getEnvInt("IMPORT_BATCH_SIZE", 500),
+		ImportAsyncSize:            getEnvInt("IMPORT_ASYNC_SIZE", 256*1024),
+		ImportBodyLimit:            getEnvInt("IMPORT_BODY_LIMIT", 64*1024*1024),
+		ImportStaleAfter:           getEnvDuration("IMPORT_STALE_AFTER", 5*time.Minute),
+		ImportRetention:            getEnvDuration("IMPORT_RETENTION", 24*time.Hour),
 	}
 }
 
+// Validate rejects settings the server cannot run with
+func (c *Config) Validate() error {
+	if c.WSPingInterval <= 0 {
+		return errors.New("WS_PING_INTERVAL must be positive")
+	}
+	// Presence is swept every half timeout and clients only refresh it when
+	// pinged, a shorter timeout would mark connected users offline
+	if c.PresenceTimeout <= c.WSPingInterval {
+		return errors.New("PRESENCE_TIMEOUT must be longer than WS_PING_INTERVAL")
+	}
+	return nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
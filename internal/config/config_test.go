@@ -0,0 +1,37 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		ping     time.Duration
+		presence time.Duration
+		valid    bool
+	}{
+		{"defaults", 25 * time.Second, 90 * time.Second, true},
+		{"zero presence timeout", 25 * time.Second, 0, false},
+		{"presence timeout of 1ns", 25 * time.Second, time.Nanosecond, false},
+		{"presence timeout equal to ping", 25 * time.Second, 25 * time.Second, false},
+		{"zero ping interval", 0, 90 * time.Second, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &Config{WSPingInterval: tt.ping, PresenceTimeout: tt.presence}
+
+			err := cfg.Validate()
+
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
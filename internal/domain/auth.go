@@ -0,0 +1,29 @@
+package domain
+
+import "context"
+
+// DTOs (Data Transfer Object)
+type RegisterRequest struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Name     string `json:"name" validate:"required,min=2,max=255"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+type LoginRequest struct {
+	Email    string `json:"email" validate:"required,email,max=255"`
+	Password string `json:"password" validate:"required,max=72"`
+}
+
+// PasswordHasher hashes and verifies user passwords
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	Compare(hash, password string) (bool, error)
+}
+
+// Service interface (contract)
+type AuthService interface {
+	Register(ctx context.Context, req *RegisterRequest) (*User, error)
+	Login(ctx context.Context, req *LoginRequest) (*AuthResponse, error)
+	Refresh(ctx context.Context, refreshToken string) (*TokenPair, error)
+	Logout(ctx context.Context, refreshToken string) error
+}
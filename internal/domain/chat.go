@@ -0,0 +1,194 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	ConversationDirect = "direct"
+	ConversationRoom   = "room"
+)
+
+// Chat event types sent to clients
+const (
+	EventMessageNew      = "message.new"
+	EventMessageEdited   = "message.edited"
+	EventMessageDeleted  = "message.deleted"
+	EventReactionAdded   = "reaction.added"
+	EventReactionRemoved = "reaction.removed"
+	EventReadReceipt     = "read.receipt"
+)
+
+// Message history pages
+const (
+	DefaultMessagePageSize = 50
+	MaxMessagePageSize     = 100
+)
+
+// Entity
+type Conversation struct {
+	ID        int64     `json:"id"`
+	Kind      string    `json:"kind"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ConversationMember struct {
+	ConversationID    int64      `json:"conversation_id"`
+	UserID            int        `json:"user_id"`
+	Role              string     `json:"role"`
+	LastReadMessageID int64      `json:"last_read_message_id"`
+	LastReadAt        *time.Time `json:"last_read_at,omitempty"`
+	JoinedAt          time.Time  `json:"joined_at"`
+}
+
+// Message is never removed, a deleted message is a tombstone with DeletedAt
+// set and an empty body. SenderID is 0 once the sender was purged.
+type Message struct {
+	ID             int64             `json:"id"`
+	ConversationID int64             `json:"conversation_id"`
+	SenderID       int               `json:"sender_id"`
+	Body           string            `json:"body"`
+	Reactions      []ReactionSummary `json:"reactions,omitempty"`
+	CreatedAt      time.Time         `json:"created_at"`
+	EditedAt       *time.Time        `json:"edited_at,omitempty"`
+	DeletedAt      *time.Time        `json:"deleted_at,omitempty"`
+}
+
+// MessageEdit keeps the body a message had before an edit
+type MessageEdit struct {
+	MessageID int64     `json:"message_id"`
+	Body      string    `json:"body"`
+	EditedAt  time.Time `json:"edited_at"`
+}
+
+// Reaction is a single emoji of a user on a message, it is also the payload
+// of reaction events
+type Reaction struct {
+	MessageID      int64  `json:"message_id"`
+	ConversationID int64  `json:"conversation_id"`
+	UserID         int    `json:"user_id"`
+	Emoji          string `json:"emoji"`
+}
+
+// ReactionSummary groups the users who reacted to a message with an emoji
+type ReactionSummary struct {
+	Emoji   string `json:"emoji"`
+	UserIDs []int  `json:"user_ids"`
+}
+
+// MessageDeletedEvent is the payload of message.deleted events
+type MessageDeletedEvent struct {
+	ConversationID int64     `json:"conversation_id"`
+	MessageID      int64     `json:"message_id"`
+	DeletedAt      time.Time `json:"deleted_at"`
+}
+
+// ConversationSummary is a conversation as listed to one of its members
+type ConversationSummary struct {
+	ID   int64  `json:"id"`
+	Kind string `json:"kind"`
+	// Name is set for rooms, PeerID for direct conversations
+	Name              string   `json:"name,omitempty"`
+	PeerID            int      `json:"peer_id,omitempty"`
+	LastMessage       *Message `json:"last_message"`
+	LastReadMessageID int64    `json:"last_read_message_id"`
+	// UnreadCount counts messages of other members after the last read one
+	UnreadCount int `json:"unread_count"`
+}
+
+// ReadReceipt is the payload of read receipt events
+type ReadReceipt struct {
+	ConversationID int64     `json:"conversation_id"`
+	UserID         int       `json:"user_id"`
+	MessageID      int64     `json:"message_id"`
+	ReadAt         time.Time `json:"read_at"`
+}
+
+// Event is a typed notification delivered to the connected clients of a set of users
+type Event struct {
+	Type    string
+	Payload any
+}
+
+// DTOs (Data Transfer Object)
+
+// SendMessageRequest targets either an existing conversation or a user,
+// whose direct conversation is created on first use
+type SendMessageRequest struct {
+	ConversationID int64  `json:"conversation_id" validate:"omitempty,gt=0"`
+	RecipientID    int    `json:"recipient_id" validate:"omitempty,gt=0"`
+	Body           string `json:"body" validate:"required,max=4000"`
+}
+
+type EditMessageRequest struct {
+	Body string `json:"body" validate:"required,max=4000"`
+}
+
+type ReactionRequest struct {
+	Emoji string `json:"emoji" validate:"required,max=32"`
+}
+
+type MarkReadRequest struct {
+	MessageID int64 `json:"message_id" validate:"required,gt=0"`
+}
+
+// MessagePage holds messages newest first, NextCursor is passed as before to
+// get older ones and is empty on the last page
+type MessagePage struct {
+	Messages   []Message `json:"messages"`
+	NextCursor string    `json:"next_cursor,omitempty"`
+}
+
+// EventPublisher delivers events to users, it does not guarantee delivery
+// to users who are offline
+type EventPublisher interface {
+	Publish(ctx context.Context, userIDs []int, event Event) error
+}
+
+// Repository interface (contract)
+type ChatRepository interface {
+	// CreateDirectMessage stores msg in the direct conversation of the sender
+	// and the recipient, creating the conversation on first use
+	CreateDirectMessage(ctx context.Context, recipientID int, msg *Message) error
+	CreateMessage(ctx context.Context, msg *Message) error
+	// GetMessage returns nil if the message does not exist
+	GetMessage(ctx context.Context, id int64) (*Message, error)
+	// UpdateMessage stores the new body of msg and keeps the previous one in
+	// the edit history. It returns ErrMessageDeleted for tombstones.
+	UpdateMessage(ctx context.Context, msg *Message) error
+	DeleteMessage(ctx context.Context, id int64, deletedAt time.Time) error
+	GetMessageEdits(ctx context.Context, messageID int64) ([]MessageEdit, error)
+	// AddReaction returns false if the user already reacted with the emoji
+	AddReaction(ctx context.Context, reaction *Reaction) (bool, error)
+	RemoveReaction(ctx context.Context, reaction *Reaction) error
+	// GetMember returns nil if the user is not a member of the conversation
+	GetMember(ctx context.Context, conversationID int64, userID int) (*ConversationMember, error)
+	GetMembers(ctx context.Context, conversationID int64) ([]ConversationMember, error)
+	// GetContactIDs returns the users sharing at least one conversation with the user
+	GetContactIDs(ctx context.Context, userID int) ([]int, error)
+	// GetConversations returns the conversations of the user, most recently active first
+	GetConversations(ctx context.Context, userID int) ([]ConversationSummary, error)
+	// GetMessages returns up to limit messages with an id below before, newest
+	// first. A before of 0 starts at the latest message.
+	GetMessages(ctx context.Context, conversationID, before int64, limit int) ([]Message, error)
+	// MarkRead moves the read marker of the member forward to messageID, it
+	// returns ErrMessageNotFound if the message is not in the conversation
+	MarkRead(ctx context.Context, conversationID int64, userID int, messageID int64, readAt time.Time) error
+}
+
+// Service interface (contract)
+type ChatService interface {
+	SendMessage(ctx context.Context, senderID int, req *SendMessageRequest) (*Message, error)
+	GetConversations(ctx context.Context, userID int) ([]ConversationSummary, error)
+	// GetMessages pages through the history of a conversation, cursor is empty for the first page
+	GetMessages(ctx context.Context, userID int, conversationID int64, cursor string, limit int) (*MessagePage, error)
+	MarkRead(ctx context.Context, userID int, conversationID int64, req *MarkReadRequest) error
+	// EditMessage lets the sender change a message within the edit window
+	EditMessage(ctx context.Context, userID int, messageID int64, req *EditMessageRequest) (*Message, error)
+	// DeleteMessage turns a message into a tombstone, room moderators may delete any message
+	DeleteMessage(ctx context.Context, userID int, messageID int64) error
+	GetMessageEdits(ctx context.Context, userID int, messageID int64) ([]MessageEdit, error)
+	AddReaction(ctx context.Context, userID int, messageID int64, req *ReactionRequest) error
+	RemoveReaction(ctx context.Context, userID int, messageID int64, emoji string) error
+}
@@ -0,0 +1,143 @@
+package domain
+
+import (
+	"errors"
+	"strings"
+)
+
+// Error kinds. Every error a client should see wraps one of them, the HTTP
+// layer maps kinds to status codes and treats anything else as internal
+var (
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrValidation      = errors.New("validation failed")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrTooManyRequests = errors.New("too many requests")
+	// ErrPreconditionFailed is returned when a conditional request, such as
+	// one with If-Match, no longer matches the resource
+	ErrPreconditionFailed = errors.New("precondition failed")
+	// ErrUnprocessable is returned for well-formed requests that cannot be
+	// served as they are
+	ErrUnprocessable = errors.New("unprocessable")
+	// ErrTooLarge is returned for request bodies above their limit
+	ErrTooLarge = errors.New("too large")
+)
+
+// Error is a domain error of a given kind whose message is safe to return to clients
+type Error struct {
+	Kind    error
+	Message string
+}
+
+func NewError(kind error, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// FieldError describes why a single request field failed validation
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// ValidationError is returned when a request fails validation, it has one
+// entry per invalid field
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, f := range e.Fields {
+		messages = append(messages, f.Field+": "+f.Message)
+	}
+	return strings.Join(messages, ", ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+// Pagination
+var ErrInvalidCursor = NewError(ErrValidation, "invalid cursor")
+
+// Users
+var (
+	ErrUserNotFound        = NewError(ErrNotFound, "user not found")
+	ErrUserExists          = NewError(ErrConflict, "user with email already exists")
+	ErrEmailInUse          = NewError(ErrConflict, "email already in use")
+	ErrUserChangeForbidden = NewError(ErrForbidden, "not allowed to modify other users")
+	ErrDeletedUsersHidden  = NewError(ErrForbidden, "not allowed to list deleted users")
+	ErrUserVersionMismatch = NewError(ErrPreconditionFailed, "user has been modified since it was read")
+	ErrUserModified        = NewError(ErrConflict, "user was modified concurrently, try again")
+)
+
+// Imports
+var (
+	ErrImportJobNotFound = NewError(ErrNotFound, "import job not found")
+	ErrImportHeader      = NewError(ErrValidation, "csv header must name the email and name columns once")
+	ErrImportLineTooLong = NewError(ErrValidation, "import line is too long")
+	ErrImportTooLarge    = NewError(ErrTooLarge, "import is too large")
+)
+
+// Idempotency
+var (
+	ErrIdempotencyKeyReused   = NewError(ErrUnprocessable, "idempotency key was used for a different request")
+	ErrIdempotencyKeyInFlight = NewError(ErrConflict, "a request with this idempotency key is in progress")
+)
+
+// Authentication
+var (
+	ErrInvalidCredentials      = NewError(ErrUnauthorized, "invalid email or password")
+	ErrInvalidRefreshToken     = NewError(ErrUnauthorized, "invalid refresh token")
+	ErrRefreshTokenUsed        = NewError(ErrConflict, "refresh token already used")
+	ErrInvalidVerificationCode = NewError(ErrValidation, "invalid or expired verification code")
+	ErrVerificationCodeUsed    = NewError(ErrConflict, "verification code already used")
+	ErrInvalidResetToken       = NewError(ErrValidation, "invalid or expired reset token")
+	ErrResetTokenUsed          = NewError(ErrConflict, "password reset token already used")
+)
+
+// Roles
+var (
+	ErrRoleNotFound           = NewError(ErrNotFound, "role not found")
+	ErrRoleExists             = NewError(ErrConflict, "role already exists")
+	ErrUnknownPermission      = NewError(ErrValidation, "unknown permission")
+	ErrRoleAssignmentNotFound = NewError(ErrNotFound, "role assignment not found")
+)
+
+// Chat
+var (
+	ErrRecipientNotFound    = NewError(ErrNotFound, "recipient not found")
+	ErrMessageToSelf        = NewError(ErrValidation, "cannot send a direct message to yourself")
+	ErrMessageTarget        = NewError(ErrValidation, "exactly one of conversation_id and recipient_id is required")
+	ErrConversationNotFound = NewError(ErrNotFound, "conversation not found")
+	ErrMessageNotFound      = NewError(ErrNotFound, "message not found")
+	ErrMessageDeleted       = NewError(ErrConflict, "message was deleted")
+	ErrNotMessageSender     = NewError(ErrForbidden, "only the sender can edit a message")
+	ErrEditWindowClosed     = NewError(ErrForbidden, "message can no longer be edited")
+	ErrMessageDeleteDenied  = NewError(ErrForbidden, "not allowed to delete this message")
+	ErrReactionNotFound     = NewError(ErrNotFound, "reaction not found")
+)
+
+// Rooms
+var (
+	ErrRoomNotFound       = NewError(ErrNotFound, "room not found")
+	ErrRoomMemberNotFound = NewError(ErrNotFound, "room member not found")
+	ErrRoomBanNotFound    = NewError(ErrNotFound, "room ban not found")
+	ErrNotRoomMember      = NewError(ErrForbidden, "not a member of the room")
+	ErrRoomRoleTooLow     = NewError(ErrForbidden, "room role does not allow this action")
+	ErrBannedFromRoom     = NewError(ErrForbidden, "banned from the room")
+	ErrAlreadyRoomMember  = NewError(ErrConflict, "already a member of the room")
+	ErrLastRoomOwner      = NewError(ErrConflict, "the last owner cannot leave the room")
+	ErrOwnRoomRole        = NewError(ErrValidation, "cannot change your own room role")
+)
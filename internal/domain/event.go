@@ -0,0 +1,35 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Entity
+
+// StoredEvent is an event as it was published to users, kept so clients of
+// the event stream can resume after reconnecting. IDs only grow and follow
+// the order events were committed in.
+type StoredEvent struct {
+	ID        int64
+	UserIDs   []int
+	Type      string
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+// Repository interface (contract)
+type EventRepository interface {
+	// Create stores event and sets its ID and CreatedAt
+	Create(ctx context.Context, event *StoredEvent) error
+	// GetLatestID returns the ID of the newest event, 0 without events
+	GetLatestID(ctx context.Context) (int64, error)
+	// GetAfter returns up to limit events with an id above afterID sent to
+	// any of userIDs, with all their recipients, oldest first
+	GetAfter(ctx context.Context, afterID int64, userIDs []int, limit int) ([]StoredEvent, error)
+	// GetSince returns up to limit events of the user with an id above afterID, oldest first
+	GetSince(ctx context.Context, userID int, afterID int64, limit int) ([]StoredEvent, error)
+	// DeleteBefore removes events created before the given time and returns how many were removed
+	DeleteBefore(ctx context.Context, before time.Time) (int64, error)
+}
@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Entity
+
+// IdempotencyRecord is a request made with an Idempotency-Key. Requests are
+// told apart by a fingerprint of their method, URL and body. Response stays
+// nil while the first request with the key is in flight.
+type IdempotencyRecord struct {
+	UserID      int
+	Key         string
+	Fingerprint string
+	Response    *IdempotentResponse
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// IdempotentResponse is the response replayed to retries of a request.
+// BodyDigest is set for requests whose body was streamed, their fingerprint
+// leaves the body out since it is only read after the key is claimed.
+type IdempotentResponse struct {
+	Status     int
+	Headers    map[string]string
+	Body       []byte
+	BodyDigest string
+}
+
+// Repository interface (contract)
+type IdempotencyRepository interface {
+	// Claim stores record as in flight for ttl unless its key is held by a
+	// record that has not expired and, while in flight, started less than
+	// lockTimeout ago. It returns whether the key was claimed and the record
+	// holding it otherwise, which is nil if it vanished in between.
+	Claim(ctx context.Context, record *IdempotencyRecord, ttl, lockTimeout time.Duration) (*IdempotencyRecord, bool, error)
+	// Complete and Release only change the record while it is still the
+	// claim made by Claim, not one that took the key over
+	Complete(ctx context.Context, record *IdempotencyRecord, response *IdempotentResponse) error
+	// Release drops an in-flight record, so the request can be retried
+	Release(ctx context.Context, record *IdempotencyRecord) error
+	DeleteExpired(ctx context.Context) (int64, error)
+}
+
+// Service interface (contract)
+type IdempotencyService interface {
+	// Begin claims the key of record and returns nil when the request should
+	// run. A request that was already served gets its stored response, a
+	// different request with the same key gets ErrIdempotencyKeyReused and
+	// one made while the first is still running gets
+	// ErrIdempotencyKeyInFlight.
+	Begin(ctx context.Context, record *IdempotencyRecord) (*IdempotentResponse, error)
+	Complete(ctx context.Context, record *IdempotencyRecord, response *IdempotentResponse) error
+	Release(ctx context.Context, record *IdempotencyRecord) error
+	// Prune removes expired records
+	Prune(ctx context.Context) error
+}
@@ -0,0 +1,48 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Entity
+type PasswordResetToken struct {
+	ID        int
+	UserID    int
+	TokenHash string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// DTOs (Data Transfer Object)
+type ForgotPasswordRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" validate:"required,max=255"`
+	Password string `json:"password" validate:"required,password"`
+}
+
+// Repository interface (contract)
+type PasswordResetRepository interface {
+	Create(ctx context.Context, token *PasswordResetToken) error
+	GetByHash(ctx context.Context, hash string) (*PasswordResetToken, error)
+	// ResetPassword uses the token, stores the new password hash, bumps the
+	// version of the user and revokes every refresh token of the user in one
+	// transaction
+	ResetPassword(ctx context.Context, tokenID, userID int, passwordHash string) error
+}
+
+// Service interface (contract)
+type PasswordResetService interface {
+	// ForgotPassword never reports whether the email exists
+	ForgotPassword(ctx context.Context, req *ForgotPasswordRequest)
+	// ResetPassword revokes the refresh tokens of the user. Access tokens are
+	// not stored, sessions already open keep working until theirs expires.
+	ResetPassword(ctx context.Context, req *ResetPasswordRequest) error
+	// Wait blocks until the reset emails being sent in the background are
+	// out or ctx ends, in which case it returns the error of ctx
+	Wait(ctx context.Context) error
+}
@@ -0,0 +1,79 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	PresenceOnline  = "online"
+	PresenceAway    = "away"
+	PresenceOffline = "offline"
+)
+
+// Presence and typing event types sent to clients
+const (
+	EventPresenceChanged = "presence.changed"
+	EventTypingStarted   = "typing.started"
+	EventTypingStopped   = "typing.stopped"
+)
+
+// Entity
+
+// PresenceConnection is an open WebSocket connection of a user, a user is
+// online while any of their connections is online and its heartbeat is fresh
+type PresenceConnection struct {
+	ID          string
+	UserID      int
+	Status      string
+	LastSeenAt  time.Time
+	ConnectedAt time.Time
+}
+
+type Presence struct {
+	UserID int    `json:"user_id"`
+	Status string `json:"status"`
+}
+
+// TypingEvent is the payload of typing events, clients drop the indicator at
+// ExpiresAt even if the stop event is lost
+type TypingEvent struct {
+	ConversationID int64      `json:"conversation_id"`
+	UserID         int        `json:"user_id"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+}
+
+// DTOs (Data Transfer Object)
+type SetPresenceRequest struct {
+	Status string `json:"status" validate:"required,oneof=online away"`
+}
+
+// Repository interface (contract)
+type PresenceRepository interface {
+	Create(ctx context.Context, conn *PresenceConnection) error
+	// Heartbeat refreshes the connection, recreating it if it was swept, and
+	// returns the previous heartbeat time, nil if the connection was recreated
+	Heartbeat(ctx context.Context, conn *PresenceConnection) (*time.Time, error)
+	SetStatus(ctx context.Context, id, status string, seenAt time.Time) error
+	Delete(ctx context.Context, id string) error
+	// GetStatuses returns the aggregated status of the users that have a
+	// connection seen after since, other users are offline and left out
+	GetStatuses(ctx context.Context, userIDs []int, since time.Time) (map[int]string, error)
+	// DeleteStale removes connections not seen after before and returns their users
+	DeleteStale(ctx context.Context, before time.Time) ([]int, error)
+}
+
+// Service interface (contract)
+type PresenceService interface {
+	// Connect registers a new connection of the user and returns its id
+	Connect(ctx context.Context, userID int) (string, error)
+	Heartbeat(ctx context.Context, userID int, connectionID string) error
+	SetStatus(ctx context.Context, userID int, connectionID, status string) error
+	Disconnect(ctx context.Context, userID int, connectionID string) error
+	// GetContacts returns the presence of every user sharing a conversation with the user
+	GetContacts(ctx context.Context, userID int) ([]Presence, error)
+	// Sweep removes connections with stale heartbeats, left by crashed instances
+	Sweep(ctx context.Context) error
+	StartTyping(ctx context.Context, userID int, conversationID int64) error
+	StopTyping(ctx context.Context, userID int, conversationID int64) error
+}
@@ -0,0 +1,90 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	RoleAdmin = "admin"
+	// RoleUser is assigned to every new user
+	RoleUser = "user"
+)
+
+const (
+	PermissionUsersList   = "users:list"
+	PermissionUsersRead   = "users:read"
+	PermissionUsersCreate = "users:create"
+	PermissionUsersUpdate = "users:update"
+	PermissionUsersDelete = "users:delete"
+	// PermissionUsersManage allows changing users other than yourself
+	PermissionUsersManage = "users:manage"
+	PermissionRolesManage = "roles:manage"
+)
+
+// Entity
+type Role struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Actor is the authenticated user on whose behalf a request is served
+type Actor struct {
+	UserID      int
+	Permissions map[string]bool
+	// System is set on SystemActor only
+	System bool
+}
+
+// SystemActor is the service itself, internal callers that act outside of an
+// authenticated request put it in their context explicitly. It may do
+// anything.
+var SystemActor = &Actor{System: true}
+
+func (a *Actor) Can(permission string) bool {
+	return a.System || a.Permissions[permission]
+}
+
+type actorKey struct{}
+
+func WithActor(ctx context.Context, actor *Actor) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns nil for calls made outside of an authenticated request
+func ActorFromContext(ctx context.Context) *Actor {
+	actor, _ := ctx.Value(actorKey{}).(*Actor)
+	return actor
+}
+
+// DTOs (Data Transfer Object)
+type CreateRoleRequest struct {
+	Name        string   `json:"name" validate:"required,min=2,max=64"`
+	Description string   `json:"description" validate:"max=255"`
+	Permissions []string `json:"permissions" validate:"required,min=1,dive,required,max=128"`
+}
+
+type AssignRoleRequest struct {
+	Role string `json:"role" validate:"required,max=64"`
+}
+
+// Repository interface (contract)
+type RoleRepository interface {
+	GetUserPermissions(ctx context.Context, userID int) ([]string, error)
+	GetAll(ctx context.Context) ([]Role, error)
+	Create(ctx context.Context, role *Role) error
+	AssignToUser(ctx context.Context, userID int, roleName string) error
+	RemoveFromUser(ctx context.Context, userID int, roleName string) error
+}
+
+// Service interface (contract)
+type AuthorizationService interface {
+	GetActor(ctx context.Context, userID int) (*Actor, error)
+	GetRoles(ctx context.Context) ([]Role, error)
+	CreateRole(ctx context.Context, req *CreateRoleRequest) (*Role, error)
+	AssignRole(ctx context.Context, userID int, req *AssignRoleRequest) error
+	RemoveRole(ctx context.Context, userID int, roleName string) error
+}
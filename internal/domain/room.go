@@ -0,0 +1,110 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	RoomPublic  = "public"
+	RoomPrivate = "private"
+)
+
+// Member roles, a role includes everything the roles below it may do
+const (
+	RoomRoleOwner     = "owner"
+	RoomRoleModerator = "moderator"
+	RoomRoleMember    = "member"
+)
+
+// Room event types sent to clients
+const (
+	EventRoomInvited     = "room.invited"
+	EventRoomJoined      = "room.joined"
+	EventRoomLeft        = "room.left"
+	EventRoomKicked      = "room.kicked"
+	EventRoomBanned      = "room.banned"
+	EventRoomRoleChanged = "room.role_changed"
+)
+
+// Entity
+type Room struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Topic       string    `json:"topic"`
+	Visibility  string    `json:"visibility"`
+	CreatedBy   int       `json:"created_by"`
+	MemberCount int       `json:"member_count"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// RoomMemberEvent is the payload of room membership events
+type RoomMemberEvent struct {
+	RoomID  int64  `json:"room_id"`
+	UserID  int    `json:"user_id"`
+	ActorID int    `json:"actor_id,omitempty"`
+	Role    string `json:"role,omitempty"`
+}
+
+// RoleRank orders member roles, it is 0 for users who are not members
+func RoleRank(role string) int {
+	switch role {
+	case RoomRoleOwner:
+		return 3
+	case RoomRoleModerator:
+		return 2
+	case RoomRoleMember:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// DTOs (Data Transfer Object)
+type CreateRoomRequest struct {
+	Name       string `json:"name" validate:"required,max=100"`
+	Topic      string `json:"topic" validate:"max=500"`
+	Visibility string `json:"visibility" validate:"required,oneof=public private"`
+}
+
+type RoomMemberRequest struct {
+	UserID int `json:"user_id" validate:"required,gt=0"`
+}
+
+type SetRoomRoleRequest struct {
+	Role string `json:"role" validate:"required,oneof=owner moderator member"`
+}
+
+// Repository interface (contract)
+type RoomRepository interface {
+	// Create stores the room and makes its creator the owner
+	Create(ctx context.Context, room *Room) error
+	GetByID(ctx context.Context, id int64) (*Room, error)
+	// GetVisible returns public rooms and rooms the user is a member of or invited to
+	GetVisible(ctx context.Context, userID int) ([]Room, error)
+	// AddMember also consumes a pending invite of the user
+	AddMember(ctx context.Context, roomID int64, userID int, role string) error
+	RemoveMember(ctx context.Context, roomID int64, userID int) error
+	SetMemberRole(ctx context.Context, roomID int64, userID int, role string) error
+	CreateInvite(ctx context.Context, roomID int64, userID, invitedBy int) error
+	HasInvite(ctx context.Context, roomID int64, userID int) (bool, error)
+	// Ban removes the user from the room and drops their invite
+	Ban(ctx context.Context, roomID int64, userID, bannedBy int) error
+	Unban(ctx context.Context, roomID int64, userID int) error
+	IsBanned(ctx context.Context, roomID int64, userID int) (bool, error)
+}
+
+// Service interface (contract)
+type RoomService interface {
+	CreateRoom(ctx context.Context, userID int, req *CreateRoomRequest) (*Room, error)
+	GetRoom(ctx context.Context, userID int, roomID int64) (*Room, error)
+	GetRooms(ctx context.Context, userID int) ([]Room, error)
+	GetMembers(ctx context.Context, userID int, roomID int64) ([]ConversationMember, error)
+	Invite(ctx context.Context, actorID int, roomID int64, userID int) error
+	Join(ctx context.Context, userID int, roomID int64) error
+	Leave(ctx context.Context, userID int, roomID int64) error
+	Kick(ctx context.Context, actorID int, roomID int64, userID int) error
+	Ban(ctx context.Context, actorID int, roomID int64, userID int) error
+	Unban(ctx context.Context, actorID int, roomID int64, userID int) error
+	SetRole(ctx context.Context, actorID int, roomID int64, userID int, role string) error
+}
@@ -0,0 +1,66 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Entity
+type RefreshToken struct {
+	ID        int
+	UserID    int
+	FamilyID  string
+	TokenHash string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// AccessClaims are the verified claims of an access token
+type AccessClaims struct {
+	UserID    int
+	Email     string
+	ExpiresAt time.Time
+}
+
+// DTOs (Data Transfer Object)
+type TokenPair struct {
+	AccessToken           string    `json:"access_token"`
+	TokenType             string    `json:"token_type"`
+	AccessTokenExpiresAt  time.Time `json:"access_token_expires_at"`
+	RefreshToken          string    `json:"refresh_token"`
+	RefreshTokenExpiresAt time.Time `json:"refresh_token_expires_at"`
+}
+
+type AuthResponse struct {
+	User   *User      `json:"user"`
+	Tokens *TokenPair `json:"tokens"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=255"`
+}
+
+// TokenManager issues and verifies signed access tokens
+type TokenManager interface {
+	Generate(user *User) (token string, expiresAt time.Time, err error)
+	Parse(token string) (*AccessClaims, error)
+}
+
+// CursorCodec turns pagination positions into opaque signed cursors and back,
+// so clients can neither read nor forge them
+type CursorCodec interface {
+	Encode(position any) (string, error)
+	// Decode returns ErrInvalidCursor for cursors it did not issue
+	Decode(cursor string, position any) error
+}
+
+// Repository interface (contract)
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *RefreshToken) error
+	GetByHash(ctx context.Context, hash string) (*RefreshToken, error)
+	// Rotate revokes the token with oldID and stores next in its place.
+	// It fails if the old token was already revoked by a concurrent rotation.
+	Rotate(ctx context.Context, oldID int, next *RefreshToken) error
+	RevokeFamily(ctx context.Context, familyID string) error
+}
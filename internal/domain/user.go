@@ -7,11 +7,17 @@ import (
 
 // Entity
 type User struct {
-	ID        int       `json:"id"`
-	Email     string    `json:"string"`
-	Name      string    `json:"name"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           int        `json:"id"`
+	Email        string     `json:"email"`
+	Name         string     `json:"name"`
+	PasswordHash string     `json:"-"`
+	VerifiedAt   *time.Time `json:"verified_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	// Version grows with every change, the ETag of the user is derived from it
+	Version int `json:"version"`
+	// DeletedAt is only set on deleted users, which are only listed on request
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
 }
 
 // DTOs (Data Transfer Object)
@@ -20,9 +26,100 @@ type CreateUserRequest struct {
 	Name  string `json:"name" validate:"required,min=2,max=255"`
 }
 
+// UpdateUserRequest holds every writable field of a user, updates replace
+// all of them. Patches are applied to it as well.
 type UpdateUserRequest struct {
-	Email string `json:"email" validate:"omitempty,email,max=255"`
-	Name  string `json:"name" validate:"omitempty,min=2,max=255"`
+	Email string `json:"email" validate:"required,email,max=255"`
+	Name  string `json:"name" validate:"required,min=2,max=255"`
+}
+
+// UserSortFields are the fields users may be sorted by
+var UserSortFields = []string{"id", "name", "email", "created_at", "updated_at"}
+
+// SortField orders by one field, descending when Desc is set
+type SortField struct {
+	Field string
+	Desc  bool
+}
+
+// UserFilter narrows the user listing, zero fields do not filter
+type UserFilter struct {
+	EmailDomain   string     `json:"email_domain" validate:"omitempty,max=255"`
+	CreatedAfter  *time.Time `json:"created_after"`
+	CreatedBefore *time.Time `json:"created_before"`
+	Verified      *bool      `json:"verified"`
+	// Query matches part of the name or email, ignoring case
+	Query string `json:"q" validate:"omitempty,max=100"`
+	// IncludeDeleted lists deleted users along with active ones
+	IncludeDeleted bool `json:"include_deleted"`
+}
+
+// UserListRequest filters and sorts the user listing. Sort is a comma
+// separated list of UserSortFields, a leading minus sorts descending.
+type UserListRequest struct {
+	Filter UserFilter
+	Sort   string
+}
+
+// UserPageRequest asks for a page of users. An empty cursor starts at the
+// first user, a cursor only works with the sort it was issued for.
+type UserPageRequest struct {
+	UserListRequest
+	Cursor       string
+	Limit        int
+	IncludeTotal bool
+}
+
+// UserPage is a page of users. Next and Prev are passed back as the cursor
+// to move forward or backward, HasMore tells whether there are users beyond
+// the page in the direction it was requested. Total is only set when
+// requested.
+type UserPage struct {
+	Users   []User `json:"users"`
+	Limit   int    `json:"limit"`
+	HasMore bool   `json:"has_more"`
+	Next    string `json:"next,omitempty"`
+	Prev    string `json:"prev,omitempty"`
+	Total   *int   `json:"total,omitempty"`
+}
+
+// UserQuery selects users. Sort always ends with id, so the order is total.
+type UserQuery struct {
+	Filter UserFilter
+	Sort   []SortField
+}
+
+// UserPageQuery selects up to Limit users after the user whose sort values
+// are After, or before it when Backward is set. A nil After starts at the
+// first or the last user.
+type UserPageQuery struct {
+	UserQuery
+	After    []string
+	Backward bool
+	Limit    int
+}
+
+// UserSearchRequest searches users by name and email. Query takes web search
+// syntax: quoted phrases, OR and a leading minus to exclude a word.
+type UserSearchRequest struct {
+	Query  string `json:"q" validate:"required,max=200"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+}
+
+// UserSearchResult is a user matching a search. Highlight is the matching
+// name and email as escaped HTML with the matched words wrapped in <mark>.
+type UserSearchResult struct {
+	User
+	Rank      float64 `json:"rank"`
+	Highlight string  `json:"highlight"`
+}
+
+// UserSearchResponse holds search results, best match first
+type UserSearchResponse struct {
+	Results []UserSearchResult `json:"results"`
+	Limit   int                `json:"limit"`
+	Offset  int                `json:"offset"`
 }
 
 type PaginationResponse struct {
@@ -36,18 +133,43 @@ type PaginationResponse struct {
 // Repository interface (contract)
 type UserRepository interface {
 	Create(ctx context.Context, user *User) error
+	// CreateBatch creates users in one statement and skips those whose email
+	// is taken, their ID stays zero
+	CreateBatch(ctx context.Context, users []*User) error
+	// ExistingEmails returns which of the emails belong to active users
+	ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error)
 	GetByID(ctx context.Context, id int) (*User, error)
 	GetByEmail(ctx context.Context, email string) (*User, error)
-	GetAll(ctx context.Context, limit, offset int) ([]User, int, error)
+	GetAll(ctx context.Context, query *UserQuery, limit, offset int) ([]User, int, error)
+	// GetPage returns users in query order without counting them, it does not
+	// skip or repeat users inserted while a client is paging
+	GetPage(ctx context.Context, query *UserPageQuery) ([]User, error)
+	Count(ctx context.Context, filter *UserFilter) (int, error)
+	// Search runs a full-text search over the search_vector column
+	Search(ctx context.Context, query string, limit, offset int) ([]UserSearchResult, error)
+	// Update only succeeds while the stored version is still user.Version,
+	// it returns ErrUserVersionMismatch otherwise and bumps the version
 	Update(ctx context.Context, id int, user *User) error
+	// Delete marks the user as deleted, every method but Restore and Purge
+	// ignores deleted users
 	Delete(ctx context.Context, id int) error
+	Restore(ctx context.Context, id int) (*User, error)
+	// Purge removes users deleted before the given time for good
+	Purge(ctx context.Context, before time.Time) (int64, error)
 }
 
 // Service interface (contract)
 type UserService interface {
 	CreateUser(ctx context.Context, req *CreateUserRequest) (*User, error)
 	GetUser(ctx context.Context, id int) (*User, error)
-	GetUsers(ctx context.Context, limit, offset int) (*PaginationResponse, error)
-	UpdateUser(ctx context.Context, id int, req *UpdateUserRequest) (*User, error)
+	GetUsers(ctx context.Context, req *UserListRequest, limit, offset int) (*PaginationResponse, error)
+	GetUsersPage(ctx context.Context, req *UserPageRequest) (*UserPage, error)
+	SearchUsers(ctx context.Context, req *UserSearchRequest) (*UserSearchResponse, error)
+	// UpdateUser fails with ErrUserVersionMismatch unless the user is at
+	// version, zero skips the check
+	UpdateUser(ctx context.Context, id int, version int, req *UpdateUserRequest) (*User, error)
 	DeleteUser(ctx context.Context, id int) error
+	RestoreUser(ctx context.Context, id int) (*User, error)
+	// PurgeDeleted removes users deleted longer than retention ago
+	PurgeDeleted(ctx context.Context, retention time.Duration) error
 }
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// Import file formats
+const (
+	ImportFormatCSV    = "csv"
+	ImportFormatNDJSON = "ndjson"
+)
+
+// Outcomes of an imported row. Dry runs report rows that would be created as
+// valid.
+const (
+	ImportRowCreated   = "created"
+	ImportRowValid     = "valid"
+	ImportRowDuplicate = "duplicate"
+	ImportRowInvalid   = "invalid"
+)
+
+// Import job statuses
+const (
+	ImportJobRunning   = "running"
+	ImportJobSucceeded = "succeeded"
+	ImportJobFailed    = "failed"
+)
+
+// Entity
+
+// UserImportJob is an import running in the background. Processed counts the
+// rows read so far, Result is set once the job succeeded and Error once it
+// failed.
+type UserImportJob struct {
+	ID         int               `json:"id"`
+	UserID     int               `json:"-"`
+	Format     string            `json:"format"`
+	DryRun     bool              `json:"dry_run"`
+	Status     string            `json:"status"`
+	Processed  int               `json:"processed"`
+	Result     *UserImportResult `json:"result,omitempty"`
+	Error      string            `json:"error,omitempty"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
+	FinishedAt *time.Time        `json:"finished_at,omitempty"`
+}
+
+// DTOs (Data Transfer Object)
+
+// UserImportRequest describes an import. Async runs it in the background
+// regardless of its size.
+type UserImportRequest struct {
+	Format string
+	DryRun bool
+	Async  bool
+}
+
+// UserImportRow is the outcome of one row. Line is where the row starts in
+// the file, counting from 1. Errors is only set on invalid rows.
+type UserImportRow struct {
+	Line   int          `json:"line"`
+	Email  string       `json:"email,omitempty"`
+	Status string       `json:"status"`
+	UserID int          `json:"user_id,omitempty"`
+	Errors []FieldError `json:"errors,omitempty"`
+}
+
+// UserImportResult reports every row of an import in file order
+type UserImportResult struct {
+	DryRun     bool            `json:"dry_run"`
+	Total      int             `json:"total"`
+	Created    int             `json:"created"`
+	Valid      int             `json:"valid"`
+	Duplicates int             `json:"duplicates"`
+	Invalid    int             `json:"invalid"`
+	Rows       []UserImportRow `json:"rows"`
+}
+
+// Validator checks a request against its validate tags and returns a
+// *ValidationError listing every invalid field
+type Validator interface {
+	Validate(data any) error
+}
+
+// Repository interface (contract)
+type UserImportRepository interface {
+	Create(ctx context.Context, job *UserImportJob) error
+	GetByID(ctx context.Context, id int) (*UserImportJob, error)
+	// Update stores the progress, status, result and error of the job
+	Update(ctx context.Context, job *UserImportJob) error
+	// FailStale fails running jobs whose progress has not been stored for
+	// staleAfter, their instance stopped while running them
+	FailStale(ctx context.Context, staleAfter time.Duration) (int64, error)
+	// DeleteFinished removes jobs that finished before the given time
+	DeleteFinished(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Service interface (contract)
+type UserImportService interface {
+	// ImportUsers creates the users read from body, which is read to the end
+	// before it returns. Small imports run right away and return a finished
+	// job that is not stored. Larger and async ones return a running job to
+	// be polled with GetImportJob.
+	ImportUsers(ctx context.Context, actorID int, req *UserImportRequest, body io.Reader) (*UserImportJob, error)
+	// GetImportJob only returns jobs started by the actor
+	GetImportJob(ctx context.Context, actorID, id int) (*UserImportJob, error)
+	// Sweep fails jobs abandoned by stopped instances and removes old ones
+	Sweep(ctx context.Context) error
+}
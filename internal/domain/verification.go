@@ -0,0 +1,53 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// Entity
+type VerificationCode struct {
+	ID         int
+	UserID     int
+	CodeHash   string
+	Attempts   int
+	ExpiresAt  time.Time
+	ConsumedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// DTOs (Data Transfer Object)
+type VerifyEmailRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	Code  string `json:"code" validate:"required,numeric,len=6"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// Repository interface (contract)
+type VerificationCodeRepository interface {
+	// Create stores code unless the user was sent one less than interval
+	// ago, it returns whether it did
+	Create(ctx context.Context, code *VerificationCode, interval time.Duration) (bool, error)
+	// GetLatest returns the most recently created code of a user, used or not
+	GetLatest(ctx context.Context, userID int) (*VerificationCode, error)
+	// ReserveAttempt counts an attempt on the code unless it already had
+	// maxAttempts, it returns whether the attempt may go ahead
+	ReserveAttempt(ctx context.Context, id, maxAttempts int) (bool, error)
+	// Consume marks the code as used and the user as verified in one transaction
+	Consume(ctx context.Context, id, userID int) error
+}
+
+// Service interface (contract)
+type VerificationService interface {
+	SendCode(ctx context.Context, user *User) error
+	Verify(ctx context.Context, req *VerifyEmailRequest) error
+	// Resend never reports whether the email exists, is verified or was
+	// throttled
+	Resend(ctx context.Context, req *ResendVerificationRequest)
+	// Wait blocks until the resends running in the background are done or
+	// ctx ends, in which case it returns the error of ctx
+	Wait(ctx context.Context) error
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+type AuthHandler struct {
+	service       domain.AuthService
+	userService   domain.UserService
+	verification  domain.VerificationService
+	passwordReset domain.PasswordResetService
+	validator     *validator.Validator
+	logger        *zap.Logger
+}
+
+func NewAuthHandler(
+	service domain.AuthService,
+	userService domain.UserService,
+	verification domain.VerificationService,
+	passwordReset domain.PasswordResetService,
+	validator *validator.Validator,
+	logger *zap.Logger,
+) *AuthHandler {
+	return &AuthHandler{
+		service:       service,
+		userService:   userService,
+		verification:  verification,
+		passwordReset: passwordReset,
+		validator:     validator,
+		logger:        logger,
+	}
+}
+
+func (h *AuthHandler) RegisterRoutes(router fiber.Router, authenticate fiber.Handler) {
+	auth := router.Group("/auth")
+	auth.Post("/register", h.Register)
+	auth.Post("/login", h.Login)
+	auth.Post("/refresh", h.Refresh)
+	auth.Post("/logout", h.Logout)
+	auth.Post("/verify", h.Verify)
+	auth.Post("/resend-verification", h.ResendVerification)
+	auth.Post("/forgot-password", h.ForgotPassword)
+	auth.Post("/reset-password", h.ResetPassword)
+	auth.Get("/me", authenticate, h.Me)
+}
+
+func (h *AuthHandler) Register(c fiber.Ctx) error {
+	req := new(domain.RegisterRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	user, err := h.service.Register(c.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(user)
+}
+
+func (h *AuthHandler) Login(c fiber.Ctx) error {
+	req := new(domain.LoginRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	response, err := h.service.Login(c.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response)
+}
+
+func (h *AuthHandler) Refresh(c fiber.Ctx) error {
+	req := new(domain.RefreshRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	tokens, err := h.service.Refresh(c.Context(), req.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(tokens)
+}
+
+func (h *AuthHandler) Logout(c fiber.Ctx) error {
+	req := new(domain.RefreshRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	if err := h.service.Logout(c.Context(), req.RefreshToken); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *AuthHandler) Verify(c fiber.Ctx) error {
+	req := new(domain.VerifyEmailRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	if err := h.verification.Verify(c.Context(), req); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Email verified",
+	})
+}
+
+func (h *AuthHandler) ResendVerification(c fiber.Ctx) error {
+	req := new(domain.ResendVerificationRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	h.verification.Resend(c.Context(), req)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "If the email is registered and not verified yet, a new code was sent",
+	})
+}
+
+func (h *AuthHandler) ForgotPassword(c fiber.Ctx) error {
+	req := new(domain.ForgotPasswordRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	h.passwordReset.ForgotPassword(c.Context(), req)
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "If the email is registered, a password reset link was sent",
+	})
+}
+
+func (h *AuthHandler) ResetPassword(c fiber.Ctx) error {
+	req := new(domain.ResetPasswordRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	if err := h.passwordReset.ResetPassword(c.Context(), req); err != nil {
+		return err
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Password was reset, please log in again",
+	})
+}
+
+func (h *AuthHandler) Me(c fiber.Ctx) error {
+	claims := middleware.Claims(c)
+	if claims == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "Missing bearer token")
+	}
+
+	user, err := h.userService.GetUser(c.Context(), claims.UserID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(user)
+}
@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+type ConversationHandler struct {
+	service   domain.ChatService
+	validator *validator.Validator
+	logger    *zap.Logger
+}
+
+func NewConversationHandler(service domain.ChatService, validator *validator.Validator, logger *zap.Logger) *ConversationHandler {
+	return &ConversationHandler{
+		service:   service,
+		validator: validator,
+		logger:    logger,
+	}
+}
+
+func (h *ConversationHandler) RegisterRoutes(router fiber.Router, authenticate fiber.Handler) {
+	conversations := router.Group("/conversations", authenticate)
+
+	conversations.Get("/", h.GetConversations)
+	conversations.Get("/:id/messages", h.GetMessages)
+	conversations.Post("/:id/read", h.MarkRead)
+}
+
+func (h *ConversationHandler) GetConversations(c fiber.Ctx) error {
+	conversations, err := h.service.GetConversations(c.Context(), middleware.Claims(c).UserID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(conversations)
+}
+
+func (h *ConversationHandler) GetMessages(c fiber.Ctx) error {
+	id, err := conversationIDParam(c)
+	if err != nil {
+		return err
+	}
+	limit, _ := strconv.Atoi(c.Query("limit", "0"))
+
+	page, err := h.service.GetMessages(c.Context(), middleware.Claims(c).UserID, id, c.Query("before"), limit)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(page)
+}
+
+func (h *ConversationHandler) MarkRead(c fiber.Ctx) error {
+	id, err := conversationIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(domain.MarkReadRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	if err := h.service.MarkRead(c.Context(), middleware.Claims(c).UserID, id, req); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func conversationIDParam(c fiber.Ctx) (int64, error) {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid conversation ID")
+	}
+	return id, nil
+}
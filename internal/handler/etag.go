@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/gofiber/fiber/v3"
+)
+
+// versionETag is the strong ETag of a resource at version
+func versionETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// ifMatchVersion returns the version named by the If-Match header, zero when
+// the header is missing or "*". Updates compare and swap on a single version,
+// so only one ETag may be given.
+func ifMatchVersion(c fiber.Ctx) (int, error) {
+	header := strings.TrimSpace(c.Get(fiber.HeaderIfMatch))
+	if header == "" || header == "*" {
+		return 0, nil
+	}
+	if strings.Contains(header, ",") {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "If-Match must name a single ETag")
+	}
+
+	// If-Match compares strongly, weak and foreign ETags never match
+	version, err := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(header, `"`), `"`))
+	if err != nil || version <= 0 || !strings.HasPrefix(header, `"`) {
+		return 0, domain.ErrUserVersionMismatch
+	}
+	return version, nil
+}
+
+// notModified tells whether the If-None-Match header names etag, which
+// compares weakly
+func notModified(c fiber.Ctx, etag string) bool {
+	header := c.Get(fiber.HeaderIfNoneMatch)
+	if header == "" {
+		return false
+	}
+	for tag := range strings.SplitSeq(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
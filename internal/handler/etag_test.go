@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		version int
+		status  int
+	}{
+		{"missing", "", 0, fiber.StatusOK},
+		{"any", "*", 0, fiber.StatusOK},
+		{"strong", `"7"`, 7, fiber.StatusOK},
+		{"weak", `W/"7"`, 0, fiber.StatusPreconditionFailed},
+		{"foreign", `"abc"`, 0, fiber.StatusPreconditionFailed},
+		{"list", `"7", "8"`, 0, fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler(zap.NewNop())})
+			app.Put("/", func(c fiber.Ctx) error {
+				version, err := ifMatchVersion(c)
+				if err != nil {
+					return err
+				}
+				return c.SendString(strconv.Itoa(version))
+			})
+
+			req := httptest.NewRequest(fiber.MethodPut, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(fiber.HeaderIfMatch, tt.header)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c fiber.Ctx) error {
+		etag := versionETag(3)
+		c.Set(fiber.HeaderETag, etag)
+		if notModified(c, etag) {
+			return c.SendStatus(fiber.StatusNotModified)
+		}
+		return c.JSON(domain.User{ID: 1, Version: 3})
+	})
+
+	for header, status := range map[string]int{
+		"":         fiber.StatusOK,
+		`"2"`:      fiber.StatusOK,
+		`"2", "3"`: fiber.StatusNotModified,
+		`W/"3"`:    fiber.StatusNotModified,
+		"*":        fiber.StatusNotModified,
+	} {
+		req := httptest.NewRequest(fiber.MethodGet, "/", nil)
+		if header != "" {
+			req.Header.Set(fiber.HeaderIfNoneMatch, header)
+		}
+		resp, err := app.Test(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		assert.Equal(t, status, resp.StatusCode, header)
+		assert.Equal(t, `"3"`, resp.Header.Get(fiber.HeaderETag))
+	}
+}
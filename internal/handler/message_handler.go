@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+type MessageHandler struct {
+	service   domain.ChatService
+	validator *validator.Validator
+	logger    *zap.Logger
+}
+
+func NewMessageHandler(service domain.ChatService, validator *validator.Validator, logger *zap.Logger) *MessageHandler {
+	return &MessageHandler{
+		service:   service,
+		validator: validator,
+		logger:    logger,
+	}
+}
+
+func (h *MessageHandler) RegisterRoutes(router fiber.Router, authenticate fiber.Handler) {
+	messages := router.Group("/messages", authenticate)
+
+	messages.Put("/:id", h.EditMessage)
+	messages.Delete("/:id", h.DeleteMessage)
+	messages.Get("/:id/edits", h.GetMessageEdits)
+	messages.Post("/:id/reactions", h.AddReaction)
+	messages.Delete("/:id/reactions/:emoji", h.RemoveReaction)
+}
+
+func (h *MessageHandler) EditMessage(c fiber.Ctx) error {
+	id, err := messageIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(domain.EditMessageRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	msg, err := h.service.EditMessage(c.Context(), middleware.Claims(c).UserID, id, req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(msg)
+}
+
+func (h *MessageHandler) DeleteMessage(c fiber.Ctx) error {
+	id, err := messageIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.DeleteMessage(c.Context(), middleware.Claims(c).UserID, id); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *MessageHandler) GetMessageEdits(c fiber.Ctx) error {
+	id, err := messageIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	edits, err := h.service.GetMessageEdits(c.Context(), middleware.Claims(c).UserID, id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(edits)
+}
+
+func (h *MessageHandler) AddReaction(c fiber.Ctx) error {
+	id, err := messageIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(domain.ReactionRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	if err := h.service.AddReaction(c.Context(), middleware.Claims(c).UserID, id, req); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *MessageHandler) RemoveReaction(c fiber.Ctx) error {
+	id, err := messageIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	// Emoji are sent percent-encoded in the path
+	emoji, err := url.PathUnescape(c.Params("emoji"))
+	if err != nil || emoji == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid emoji")
+	}
+
+	if err := h.service.RemoveReaction(c.Context(), middleware.Claims(c).UserID, id, emoji); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func messageIDParam(c fiber.Ctx) (int64, error) {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid message ID")
+	}
+	return id, nil
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"mime"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/jsonpatch"
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+// applyPatch applies the request body to doc as a merge patch or a JSON
+// Patch, depending on its content type
+func applyPatch(c fiber.Ctx, doc []byte) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+
+	var patched []byte
+	var err error
+	switch mediaType {
+	case mergePatchType:
+		patched, err = jsonpatch.MergePatch(doc, c.Body())
+	case jsonPatchType:
+		patched, err = jsonpatch.Apply(doc, c.Body())
+	default:
+		c.Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		return nil, fiber.NewError(fiber.StatusUnsupportedMediaType, "Patch must be "+mergePatchType+" or "+jsonPatchType)
+	}
+
+	switch {
+	case errors.Is(err, jsonpatch.ErrInvalidPatch):
+		return nil, fiber.NewError(fiber.StatusBadRequest, "Invalid patch: "+err.Error())
+	case errors.Is(err, jsonpatch.ErrNotApplicable):
+		return nil, fiber.NewError(fiber.StatusConflict, "Patch does not apply: "+err.Error())
+	case err != nil:
+		return nil, err
+	}
+	return patched, nil
+}
+
+// decodePatched decodes a patched document into the struct target points
+// to. Members target has no field for and values of the wrong type are
+// reported as invalid fields, nulls leave fields at their zero value.
+func decodePatched(data []byte, target any) error {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil || members == nil {
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Patched document must be an object")
+	}
+
+	known := make(map[string]bool)
+	typ := reflect.TypeOf(target).Elem()
+	for i := range typ.NumField() {
+		name, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+		known[name] = true
+	}
+
+	var fields []domain.FieldError
+	for _, name := range slices.Sorted(maps.Keys(members)) {
+		if !known[name] {
+			fields = append(fields, domain.FieldError{Field: name, Rule: "unknown", Message: "cannot be changed"})
+		}
+	}
+	if len(fields) > 0 {
+		return &domain.ValidationError{Fields: fields}
+	}
+
+	if err := json.Unmarshal(data, target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return &domain.ValidationError{Fields: []domain.FieldError{{
+				Field:   typeErr.Field,
+				Rule:    "type",
+				Param:   typeErr.Type.String(),
+				Message: "must be a " + typeErr.Type.String(),
+			}}}
+		}
+		return fiber.NewError(fiber.StatusUnprocessableEntity, "Patched document is invalid")
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// newPatchApp patches a fixed user and answers with the result
+func newPatchApp() *fiber.App {
+	val := validator.New()
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler(zap.NewNop())})
+	app.Patch("/", func(c fiber.Ctx) error {
+		doc, err := json.Marshal(domain.UpdateUserRequest{Email: "ann@example.com", Name: "Ann"})
+		if err != nil {
+			return err
+		}
+		patched, err := applyPatch(c, doc)
+		if err != nil {
+			return err
+		}
+		req := new(domain.UpdateUserRequest)
+		if err := decodePatched(patched, req); err != nil {
+			return err
+		}
+		if err := val.Validate(req); err != nil {
+			return err
+		}
+		return c.JSON(req)
+	})
+	return app
+}
+
+func TestPatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		want        string
+	}{
+		{"merge patch", mergePatchType, `{"name":"Ann Lee"}`, 200, `{"email":"ann@example.com","name":"Ann Lee"}`},
+		{"json patch", jsonPatchType + "; charset=utf-8", `[{"op":"test","path":"/name","value":"Ann"},{"op":"replace","path":"/email","value":"lee@example.com"}]`, 200, `{"email":"lee@example.com","name":"Ann"}`},
+		{"merge null clears", mergePatchType, `{"name":null}`, 400, `"rule":"required"`},
+		{"json null clears", jsonPatchType, `[{"op":"replace","path":"/name","value":null}]`, 400, `"rule":"required"`},
+		{"read-only member", mergePatchType, `{"id":5}`, 400, `"field":"id","rule":"unknown"`},
+		{"wrong type", mergePatchType, `{"name":5}`, 400, `"field":"name","rule":"type"`},
+		{"malformed patch", jsonPatchType, `[{"op":"upsert","path":"/name"}]`, 400, `Invalid patch`},
+		{"failed test", jsonPatchType, `[{"op":"test","path":"/name","value":"Bob"}]`, 409, `Patch does not apply`},
+		{"not an object", jsonPatchType, `[{"op":"replace","path":"","value":[]}]`, 422, `must be an object`},
+		{"plain json", fiber.MIMEApplicationJSON, `{"name":"Ann Lee"}`, 415, mergePatchType},
+	}
+
+	app := newPatchApp()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(fiber.MethodPatch, "/", strings.NewReader(tt.body))
+			req.Header.Set(fiber.HeaderContentType, tt.contentType)
+
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.StatusCode, string(body))
+			if tt.status == fiber.StatusOK {
+				assert.JSONEq(t, tt.want, string(body))
+			} else {
+				assert.Contains(t, string(body), tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+type PresenceHandler struct {
+	service domain.PresenceService
+	logger  *zap.Logger
+}
+
+func NewPresenceHandler(service domain.PresenceService, logger *zap.Logger) *PresenceHandler {
+	return &PresenceHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *PresenceHandler) RegisterRoutes(router fiber.Router, authenticate fiber.Handler) {
+	router.Get("/presence", authenticate, h.GetContacts)
+}
+
+// GetContacts returns the presence of the users sharing a conversation with
+// the caller, later changes arrive as presence.changed events
+func (h *PresenceHandler) GetContacts(c fiber.Ctx) error {
+	presence, err := h.service.GetContacts(c.Context(), middleware.Claims(c).UserID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(presence)
+}
@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/requestid"
+	"go.uber.org/zap"
+)
+
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 error response
+type Problem struct {
+	Type      string              `json:"type"`
+	Title     string              `json:"title"`
+	Status    int                 `json:"status"`
+	Detail    string              `json:"detail,omitempty"`
+	Instance  string              `json:"instance,omitempty"`
+	RequestID string              `json:"request_id,omitempty"`
+	Errors    []domain.FieldError `json:"errors,omitempty"`
+}
+
+var problemStatuses = []struct {
+	kind error
+	code int
+}{
+	{domain.ErrNotFound, fiber.StatusNotFound},
+	{domain.ErrConflict, fiber.StatusConflict},
+	{domain.ErrValidation, fiber.StatusBadRequest},
+	{domain.ErrUnauthorized, fiber.StatusUnauthorized},
+	{domain.ErrForbidden, fiber.StatusForbidden},
+	{domain.ErrTooManyRequests, fiber.StatusTooManyRequests},
+	{domain.ErrPreconditionFailed, fiber.StatusPreconditionFailed},
+	{domain.ErrUnprocessable, fiber.StatusUnprocessableEntity},
+	{domain.ErrTooLarge, fiber.StatusRequestEntityTooLarge},
+}
+
+// ErrorHandler is the one place where errors become HTTP responses. Domain
+// errors are mapped by kind, unknown errors are reported as 500 without
+// leaking their message to the client.
+func ErrorHandler(logger *zap.Logger) fiber.ErrorHandler {
+	return func(c fiber.Ctx, err error) error {
+		problem := NewProblem(err)
+		problem.Instance = c.Path()
+		problem.RequestID = requestid.FromContext(c)
+
+		log := logger.Warn
+		if problem.Status >= fiber.StatusInternalServerError {
+			log = logger.Error
+		}
+		log("Request error",
+			zap.String("request_id", problem.RequestID),
+			zap.String("path", c.Path()),
+			zap.String("method", c.Method()),
+			zap.Int("status", problem.Status),
+			zap.Error(err),
+		)
+
+		return c.Status(problem.Status).JSON(problem, problemContentType)
+	}
+}
+
+// NewProblem describes err without request specific fields
+func NewProblem(err error) *Problem {
+	problem := &Problem{
+		Type:   "about:blank",
+		Status: fiber.StatusInternalServerError,
+	}
+
+	var (
+		fiberErr      *fiber.Error
+		validationErr *domain.ValidationError
+		domainErr     *domain.Error
+	)
+	switch {
+	case errors.As(err, &fiberErr):
+		problem.Status = fiberErr.Code
+		problem.Detail = fiberErr.Message
+	case errors.As(err, &validationErr):
+		problem.Status = fiber.StatusBadRequest
+		problem.Detail = "Request has invalid fields"
+		problem.Errors = validationErr.Fields
+	case errors.As(err, &domainErr):
+		for _, status := range problemStatuses {
+			if errors.Is(domainErr, status.kind) {
+				problem.Status = status.code
+				problem.Detail = domainErr.Message
+				break
+			}
+		}
+	}
+
+	problem.Title = http.StatusText(problem.Status)
+	return problem
+}
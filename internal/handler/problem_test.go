@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func TestNewProblem(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		detail string
+	}{
+		{"fiber error", fiber.NewError(fiber.StatusBadRequest, "Invalid user ID"), 400, "Invalid user ID"},
+		{"not found", domain.ErrUserNotFound, 404, "user not found"},
+		{"wrapped conflict", fmt.Errorf("failed to create role: %w", domain.ErrRoleExists), 409, "role already exists"},
+		{"forbidden", domain.ErrUserChangeForbidden, 403, "not allowed to modify other users"},
+		{"too many requests", domain.NewError(domain.ErrTooManyRequests, "slow down"), 429, "slow down"},
+		{"precondition failed", domain.ErrUserVersionMismatch, 412, "user has been modified since it was read"},
+		{"unprocessable", domain.ErrIdempotencyKeyReused, 422, "idempotency key was used for a different request"},
+		{"too large", domain.ErrImportTooLarge, 413, "import is too large"},
+		{"unknown error", errors.New("pq: connection refused"), 500, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			problem := NewProblem(tt.err)
+
+			assert.Equal(t, "about:blank", problem.Type)
+			assert.Equal(t, tt.status, problem.Status)
+			assert.Equal(t, tt.detail, problem.Detail)
+			assert.NotEmpty(t, problem.Title)
+		})
+	}
+}
+
+func TestErrorHandler_ValidationProblem(t *testing.T) {
+	app := fiber.New(fiber.Config{ErrorHandler: ErrorHandler(zap.NewNop())})
+	app.Post("/users", func(c fiber.Ctx) error {
+		return &domain.ValidationError{Fields: []domain.FieldError{
+			{Field: "email", Rule: "email", Message: "must be valid email"},
+			{Field: "name", Rule: "min", Param: "2", Message: "must be at least 2 characters"},
+		}}
+	})
+
+	resp, err := app.Test(httptest.NewRequest(fiber.MethodPost, "/users", nil))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, fiber.StatusBadRequest, resp.StatusCode)
+	assert.Equal(t, "application/problem+json", resp.Header.Get("Content-Type"))
+
+	var problem Problem
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&problem))
+	assert.Equal(t, "Bad Request", problem.Title)
+	assert.Equal(t, "/users", problem.Instance)
+	assert.Len(t, problem.Errors, 2)
+	assert.Equal(t, "name", problem.Errors[1].Field)
+	assert.Equal(t, "2", problem.Errors[1].Param)
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+type RoleHandler struct {
+	service   domain.AuthorizationService
+	validator *validator.Validator
+	logger    *zap.Logger
+}
+
+func NewRoleHandler(service domain.AuthorizationService, validator *validator.Validator, logger *zap.Logger) *RoleHandler {
+	return &RoleHandler{
+		service:   service,
+		validator: validator,
+		logger:    logger,
+	}
+}
+
+func (h *RoleHandler) RegisterRoutes(router fiber.Router, authenticate fiber.Handler, authz *middleware.Authorizer) {
+	manage := authz.RequirePermission(domain.PermissionRolesManage)
+
+	router.Get("/roles", authenticate, manage, h.GetRoles)
+	router.Post("/roles", authenticate, manage, h.CreateRole)
+	router.Post("/users/:id/roles", authenticate, manage, h.AssignRole)
+	router.Delete("/users/:id/roles/:role", authenticate, manage, h.RemoveRole)
+}
+
+func (h *RoleHandler) GetRoles(c fiber.Ctx) error {
+	roles, err := h.service.GetRoles(c.Context())
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(roles)
+}
+
+func (h *RoleHandler) CreateRole(c fiber.Ctx) error {
+	req := new(domain.CreateRoleRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	role, err := h.service.CreateRole(c.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(role)
+}
+
+func (h *RoleHandler) AssignRole(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	req := new(domain.AssignRoleRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	if err := h.service.AssignRole(c.Context(), id, req); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoleHandler) RemoveRole(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	if err := h.service.RemoveRole(c.Context(), id, c.Params("role")); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
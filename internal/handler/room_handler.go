@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"strconv"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+type RoomHandler struct {
+	service   domain.RoomService
+	validator *validator.Validator
+	logger    *zap.Logger
+}
+
+func NewRoomHandler(service domain.RoomService, validator *validator.Validator, logger *zap.Logger) *RoomHandler {
+	return &RoomHandler{
+		service:   service,
+		validator: validator,
+		logger:    logger,
+	}
+}
+
+func (h *RoomHandler) RegisterRoutes(router fiber.Router, authenticate fiber.Handler) {
+	rooms := router.Group("/rooms", authenticate)
+
+	rooms.Get("/", h.GetRooms)
+	rooms.Post("/", h.CreateRoom)
+	rooms.Get("/:id", h.GetRoom)
+	rooms.Get("/:id/members", h.GetMembers)
+	rooms.Post("/:id/join", h.Join)
+	rooms.Post("/:id/leave", h.Leave)
+	rooms.Post("/:id/invites", h.Invite)
+	rooms.Delete("/:id/members/:userId", h.Kick)
+	rooms.Put("/:id/members/:userId/role", h.SetRole)
+	rooms.Post("/:id/bans", h.Ban)
+	rooms.Delete("/:id/bans/:userId", h.Unban)
+}
+
+func (h *RoomHandler) GetRooms(c fiber.Ctx) error {
+	rooms, err := h.service.GetRooms(c.Context(), middleware.Claims(c).UserID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(rooms)
+}
+
+func (h *RoomHandler) CreateRoom(c fiber.Ctx) error {
+	req := new(domain.CreateRoomRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	room, err := h.service.CreateRoom(c.Context(), middleware.Claims(c).UserID, req)
+	if err != nil {
+		return err
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(room)
+}
+
+func (h *RoomHandler) GetRoom(c fiber.Ctx) error {
+	roomID, err := roomIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	room, err := h.service.GetRoom(c.Context(), middleware.Claims(c).UserID, roomID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(room)
+}
+
+func (h *RoomHandler) GetMembers(c fiber.Ctx) error {
+	roomID, err := roomIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	members, err := h.service.GetMembers(c.Context(), middleware.Claims(c).UserID, roomID)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(members)
+}
+
+func (h *RoomHandler) Join(c fiber.Ctx) error {
+	roomID, err := roomIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Join(c.Context(), middleware.Claims(c).UserID, roomID); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoomHandler) Leave(c fiber.Ctx) error {
+	roomID, err := roomIDParam(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Leave(c.Context(), middleware.Claims(c).UserID, roomID); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoomHandler) Invite(c fiber.Ctx) error {
+	roomID, req, err := h.memberRequest(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Invite(c.Context(), middleware.Claims(c).UserID, roomID, req.UserID); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoomHandler) Kick(c fiber.Ctx) error {
+	roomID, userID, err := roomMemberParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Kick(c.Context(), middleware.Claims(c).UserID, roomID, userID); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoomHandler) SetRole(c fiber.Ctx) error {
+	roomID, userID, err := roomMemberParams(c)
+	if err != nil {
+		return err
+	}
+
+	req := new(domain.SetRoomRoleRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	if err := h.service.SetRole(c.Context(), middleware.Claims(c).UserID, roomID, userID, req.Role); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoomHandler) Ban(c fiber.Ctx) error {
+	roomID, req, err := h.memberRequest(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Ban(c.Context(), middleware.Claims(c).UserID, roomID, req.UserID); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+func (h *RoomHandler) Unban(c fiber.Ctx) error {
+	roomID, userID, err := roomMemberParams(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.service.Unban(c.Context(), middleware.Claims(c).UserID, roomID, userID); err != nil {
+		return err
+	}
+
+	return c.SendStatus(fiber.StatusNoContent)
+}
+
+// memberRequest parses the room id and a body naming the target user
+func (h *RoomHandler) memberRequest(c fiber.Ctx) (int64, *domain.RoomMemberRequest, error) {
+	roomID, err := roomIDParam(c)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	req := new(domain.RoomMemberRequest)
+	if err := c.Bind().JSON(req); err != nil {
+		return 0, nil, fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+	}
+
+	if err := h.validator.Validate(req); err != nil {
+		return 0, nil, err
+	}
+
+	return roomID, req, nil
+}
+
+func roomIDParam(c fiber.Ctx) (int64, error) {
+	id, err := strconv.ParseInt(c.Params("id"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fiber.NewError(fiber.StatusBadRequest, "Invalid room ID")
+	}
+	return id, nil
+}
+
+func roomMemberParams(c fiber.Ctx) (int64, int, error) {
+	roomID, err := roomIDParam(c)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	userID, err := strconv.Atoi(c.Params("userId"))
+	if err != nil {
+		return 0, 0, fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+	return roomID, userID, nil
+}
@@ -1,9 +1,16 @@
 package handler
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
 	"github.com/DMaryanskiy/go-idk/internal/validator"
 	"github.com/gofiber/fiber/v3"
 	"go.uber.org/zap"
@@ -11,25 +18,39 @@ import (
 
 type UserHandler struct {
 	service   domain.UserService
+	imports   domain.UserImportService
 	validator *validator.Validator
 	logger    *zap.Logger
 }
 
-func NewUserHandler(service domain.UserService, validator *validator.Validator, logger *zap.Logger) *UserHandler {
+func NewUserHandler(service domain.UserService, imports domain.UserImportService, validator *validator.Validator, logger *zap.Logger) *UserHandler {
 	return &UserHandler{
 		service:   service,
+		imports:   imports,
 		validator: validator,
 		logger:    logger,
 	}
 }
 
-func (h *UserHandler) RegisterRoutes(router fiber.Router) {
-	users := router.Group("/users")
-	users.Post("/", h.CreateUser)
-	users.Get("/", h.GetUsers)
-	users.Get("/:id", h.GetUser)
-	users.Put("/:id", h.UpdateUser)
-	users.Delete("/:id", h.DeleteUser)
+// importFormats maps the content types accepted by ImportUsers to formats
+var importFormats = map[string]string{
+	"text/csv":             domain.ImportFormatCSV,
+	"application/x-ndjson": domain.ImportFormatNDJSON,
+	"application/ndjson":   domain.ImportFormatNDJSON,
+}
+
+func (h *UserHandler) RegisterRoutes(router fiber.Router, authenticate fiber.Handler, authz *middleware.Authorizer, idempotent fiber.Handler) {
+	users := router.Group("/users", authenticate)
+	users.Post("/", authz.RequirePermission(domain.PermissionUsersCreate), idempotent, h.CreateUser)
+	users.Get("/", authz.RequirePermission(domain.PermissionUsersList), h.GetUsers)
+	users.Get("/search", authz.RequirePermission(domain.PermissionUsersList), h.SearchUsers)
+	users.Post("/import", authz.RequirePermission(domain.PermissionUsersCreate), idempotent, h.ImportUsers)
+	users.Get("/import/:id", authz.RequirePermission(domain.PermissionUsersCreate), h.GetImportJob)
+	users.Get("/:id", authz.RequirePermission(domain.PermissionUsersRead), h.GetUser)
+	users.Put("/:id", authz.RequirePermission(domain.PermissionUsersUpdate), h.UpdateUser)
+	users.Patch("/:id", authz.RequirePermission(domain.PermissionUsersUpdate), h.PatchUser)
+	users.Delete("/:id", authz.RequirePermission(domain.PermissionUsersDelete), h.DeleteUser)
+	users.Post("/:id/restore", authz.RequirePermission(domain.PermissionUsersManage), h.RestoreUser)
 }
 
 func (h *UserHandler) CreateUser(c fiber.Ctx) error {
@@ -39,15 +60,12 @@ func (h *UserHandler) CreateUser(c fiber.Ctx) error {
 	}
 
 	if err := h.validator.Validate(req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return err
 	}
 
 	user, err := h.service.CreateUser(c.Context(), req)
 	if err != nil {
-		if err.Error() == "user with email already exists" {
-			return fiber.NewError(fiber.StatusConflict, err.Error())
-		}
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to create user")
+		return err
 	}
 
 	return c.Status(fiber.StatusCreated).JSON(user)
@@ -61,56 +79,221 @@ func (h *UserHandler) GetUser(c fiber.Ctx) error {
 
 	user, err := h.service.GetUser(c.Context(), id)
 	if err != nil {
-		if err.Error() == "user not found" {
-			return fiber.NewError(fiber.StatusNotFound, err.Error())
-		}
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get user")
+		return err
+	}
+
+	etag := versionETag(user.Version)
+	c.Set(fiber.HeaderETag, etag)
+	if notModified(c, etag) {
+		return c.SendStatus(fiber.StatusNotModified)
 	}
 
 	return c.JSON(user)
 }
 
+// GetUsers pages with limit and offset by default. Passing the cursor query
+// parameter, empty for the first page, switches to cursor pagination.
 func (h *UserHandler) GetUsers(c fiber.Ctx) error {
+	list, err := h.userListRequest(c)
+	if err != nil {
+		return err
+	}
+	if c.RequestCtx().QueryArgs().Has("cursor") {
+		return h.getUsersPage(c, list)
+	}
+
 	limit, _ := strconv.Atoi(c.Query("limit", "10"))
 	offset, _ := strconv.Atoi(c.Query("offset", "0"))
 
-	response, err := h.service.GetUsers(c.Context(), limit, offset)
+	response, err := h.service.GetUsers(c.Context(), list, limit, offset)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to get users") 
+		return err
 	}
 
 	return c.JSON(response)
 }
 
+func (h *UserHandler) getUsersPage(c fiber.Ctx, list *domain.UserListRequest) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+
+	page, err := h.service.GetUsersPage(c.Context(), &domain.UserPageRequest{
+		UserListRequest: *list,
+		Cursor:          c.Query("cursor"),
+		Limit:           limit,
+		IncludeTotal:    fiber.Query[bool](c, "include_total"),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(page)
+}
+
+func (h *UserHandler) SearchUsers(c fiber.Ctx) error {
+	limit, _ := strconv.Atoi(c.Query("limit", "10"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+
+	req := &domain.UserSearchRequest{
+		Query:  strings.TrimSpace(c.Query("q")),
+		Limit:  limit,
+		Offset: offset,
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	response, err := h.service.SearchUsers(c.Context(), req)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(response)
+}
+
+// userListRequest reads the sort and filters of the user listing
+func (h *UserHandler) userListRequest(c fiber.Ctx) (*domain.UserListRequest, error) {
+	req := &domain.UserListRequest{
+		Sort: c.Query("sort"),
+		Filter: domain.UserFilter{
+			EmailDomain: strings.TrimSpace(c.Query("email_domain")),
+			Query:       strings.TrimSpace(c.Query("q")),
+		},
+	}
+
+	var fields []domain.FieldError
+	bounds := []struct {
+		name   string
+		target **time.Time
+	}{
+		{"created_after", &req.Filter.CreatedAfter},
+		{"created_before", &req.Filter.CreatedBefore},
+	}
+	for _, bound := range bounds {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		value, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			fields = append(fields, domain.FieldError{
+				Field:   bound.name,
+				Rule:    "datetime",
+				Param:   time.RFC3339,
+				Message: "must be an RFC 3339 timestamp",
+			})
+			continue
+		}
+		// Timestamps are stored in UTC without a zone
+		value = value.UTC()
+		*bound.target = &value
+	}
+	if raw := c.Query("verified"); raw != "" {
+		verified, err := strconv.ParseBool(raw)
+		if err != nil {
+			fields = append(fields, domain.FieldError{Field: "verified", Rule: "boolean", Message: "must be true or false"})
+		} else {
+			req.Filter.Verified = &verified
+		}
+	}
+	if raw := c.Query("include_deleted"); raw != "" {
+		includeDeleted, err := strconv.ParseBool(raw)
+		if err != nil {
+			fields = append(fields, domain.FieldError{Field: "include_deleted", Rule: "boolean", Message: "must be true or false"})
+		} else {
+			req.Filter.IncludeDeleted = includeDeleted
+		}
+	}
+	if len(fields) > 0 {
+		return nil, &domain.ValidationError{Fields: fields}
+	}
+
+	if err := h.validator.Validate(&req.Filter); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
 func (h *UserHandler) UpdateUser(c fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
 	}
 
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
 	req := new(domain.UpdateUserRequest)
 	if err := c.Bind().JSON(req); err != nil {
 		return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
 	}
 
 	if err := h.validator.Validate(req); err != nil {
-		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		return err
 	}
 
-	user, err := h.service.UpdateUser(c.Context(), id, req)
+	user, err := h.service.UpdateUser(c.Context(), id, version, req)
 	if err != nil {
-		if err.Error() == "user not found" {
-			return fiber.NewError(fiber.StatusNotFound, err.Error())
-		}
-		if err.Error() == "email already in use" {
-			return fiber.NewError(fiber.StatusConflict, err.Error())
-		}
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to update user")
+		return err
 	}
 
+	c.Set(fiber.HeaderETag, versionETag(user.Version))
 	return c.JSON(user)
 }
 
+// PatchUser applies a merge patch or JSON Patch to the writable fields of a
+// user. Absent members keep their value and null members clear it, which
+// fails validation for required fields.
+func (h *UserHandler) PatchUser(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	version, err := ifMatchVersion(c)
+	if err != nil {
+		return err
+	}
+
+	user, err := h.service.GetUser(c.Context(), id)
+	if err != nil {
+		return err
+	}
+	if version != 0 && user.Version != version {
+		return domain.ErrUserVersionMismatch
+	}
+
+	doc, err := json.Marshal(domain.UpdateUserRequest{Email: user.Email, Name: user.Name})
+	if err != nil {
+		return fmt.Errorf("error encoding user: %w", err)
+	}
+	patched, err := applyPatch(c, doc)
+	if err != nil {
+		return err
+	}
+
+	req := new(domain.UpdateUserRequest)
+	if err := decodePatched(patched, req); err != nil {
+		return err
+	}
+	if err := h.validator.Validate(req); err != nil {
+		return err
+	}
+
+	// The update only applies to the version the patch was applied to
+	updated, err := h.service.UpdateUser(c.Context(), id, user.Version, req)
+	if version == 0 && errors.Is(err, domain.ErrUserVersionMismatch) {
+		return domain.ErrUserModified
+	}
+	if err != nil {
+		return err
+	}
+
+	c.Set(fiber.HeaderETag, versionETag(updated.Version))
+	return c.JSON(updated)
+}
+
 func (h *UserHandler) DeleteUser(c fiber.Ctx) error {
 	id, err := strconv.Atoi(c.Params("id"))
 	if err != nil {
@@ -118,11 +301,64 @@ func (h *UserHandler) DeleteUser(c fiber.Ctx) error {
 	}
 
 	if err := h.service.DeleteUser(c.Context(), id); err != nil {
-		if err.Error() == "user not found" {
-			return fiber.NewError(fiber.StatusNotFound, err.Error())
-		}
-		return fiber.NewError(fiber.StatusInternalServerError, "Failed to delete user")
+		return err
 	}
 
 	return c.SendStatus(fiber.StatusNoContent)
 }
+
+func (h *UserHandler) RestoreUser(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid user ID")
+	}
+
+	user, err := h.service.RestoreUser(c.Context(), id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(user)
+}
+
+// ImportUsers creates users from a CSV or NDJSON body. Small imports answer
+// with the outcome of every row, larger ones are accepted as a job whose
+// URL is in the Location header. The body is read from the connection as it
+// arrives instead of being buffered whole.
+func (h *UserHandler) ImportUsers(c fiber.Ctx) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Get(fiber.HeaderContentType))
+	format, ok := importFormats[mediaType]
+	if !ok {
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, "Import must be text/csv or application/x-ndjson")
+	}
+
+	req := &domain.UserImportRequest{
+		Format: format,
+		DryRun: fiber.Query[bool](c, "dry_run"),
+		Async:  fiber.Query[bool](c, "async"),
+	}
+	job, err := h.imports.ImportUsers(c.Context(), middleware.Claims(c).UserID, req, middleware.Body(c))
+	if err != nil {
+		return err
+	}
+
+	if job.Status == domain.ImportJobRunning {
+		c.Location(strings.TrimSuffix(c.Path(), "/") + "/" + strconv.Itoa(job.ID))
+		return c.Status(fiber.StatusAccepted).JSON(job)
+	}
+	return c.JSON(job.Result)
+}
+
+func (h *UserHandler) GetImportJob(c fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return fiber.NewError(fiber.StatusBadRequest, "Invalid job ID")
+	}
+
+	job, err := h.imports.GetImportJob(c.Context(), middleware.Claims(c).UserID, id)
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(job)
+}
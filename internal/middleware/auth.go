@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"strings"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/gofiber/fiber/v3"
+)
+
+const claimsKey = "claims"
+
+// Auth validates the "Authorization: Bearer <token>" header and stores the
+// access token claims in c.Locals for the following handlers
+func Auth(tokens domain.TokenManager) fiber.Handler {
+	return authenticate(tokens, false)
+}
+
+// StreamAuth is Auth that also accepts the token in the access_token query
+// parameter, browsers cannot set headers on WebSocket and EventSource requests
+func StreamAuth(tokens domain.TokenManager) fiber.Handler {
+	return authenticate(tokens, true)
+}
+
+func authenticate(tokens domain.TokenManager, allowQuery bool) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		raw := bearerToken(c)
+		if raw == "" && allowQuery {
+			raw = c.Query("access_token")
+		}
+		if raw == "" {
+			c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+			return fiber.NewError(fiber.StatusUnauthorized, "Missing bearer token")
+		}
+
+		claims, err := tokens.Parse(raw)
+		if err != nil {
+			c.Set(fiber.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+			return fiber.NewError(fiber.StatusUnauthorized, "Invalid or expired token")
+		}
+
+		c.Locals(claimsKey, claims)
+		return c.Next()
+	}
+}
+
+func bearerToken(c fiber.Ctx) string {
+	scheme, raw, found := strings.Cut(c.Get(fiber.HeaderAuthorization), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(raw)
+}
+
+// Claims returns the claims stored by Auth, or nil for anonymous requests
+func Claims(c fiber.Ctx) *domain.AccessClaims {
+	if claims, ok := c.Locals(claimsKey).(*domain.AccessClaims); ok {
+		return claims
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/gofiber/fiber/v3"
+)
+
+const actorKey = "actor"
+
+// Authorizer checks permissions of the user authenticated by Auth
+type Authorizer struct {
+	service domain.AuthorizationService
+}
+
+func NewAuthorizer(service domain.AuthorizationService) *Authorizer {
+	return &Authorizer{service: service}
+}
+
+// RequirePermission rejects requests whose user lacks permission. The loaded
+// actor is put into the request context, so services can apply ownership rules.
+func (a *Authorizer) RequirePermission(permission string) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		actor, err := a.actor(c)
+		if err != nil {
+			return err
+		}
+
+		if !actor.Can(permission) {
+			return fiber.NewError(fiber.StatusForbidden, "Missing permission "+permission)
+		}
+
+		return c.Next()
+	}
+}
+
+// actor loads the permissions once per request
+func (a *Authorizer) actor(c fiber.Ctx) (*domain.Actor, error) {
+	if actor, ok := c.Locals(actorKey).(*domain.Actor); ok {
+		return actor, nil
+	}
+
+	claims := Claims(c)
+	if claims == nil {
+		return nil, fiber.NewError(fiber.StatusUnauthorized, "Missing bearer token")
+	}
+
+	actor, err := a.service.GetActor(c.Context(), claims.UserID)
+	if err != nil {
+		return nil, fiber.NewError(fiber.StatusInternalServerError, "Failed to load permissions")
+	}
+
+	c.Locals(actorKey, actor)
+	c.SetContext(domain.WithActor(c.Context(), actor))
+	return actor, nil
+}
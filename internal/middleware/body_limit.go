@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"bytes"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+const bodyKey = "body"
+
+// streamedBody is a request body read as it arrives, it notes whether it
+// was read to the end
+type streamedBody struct {
+	io.Reader
+	eof bool
+}
+
+func (b *streamedBody) Read(p []byte) (int, error) {
+	n, err := b.Reader.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+// BodyLimit refuses request bodies larger than limit bytes with 413. The
+// server streams request bodies, so this is what bounds them: bodies are
+// read here and later handlers get them whole. Requests to the streamed
+// paths are left alone, their handlers read the stream with Body and bound
+// it themselves.
+//
+// A body left unread would be taken for the next request on the connection,
+// so refused requests and streamed ones not read to the end close it.
+func BodyLimit(limit int, streamed ...string) fiber.Handler {
+	paths := make([]string, len(streamed))
+	for i, path := range streamed {
+		paths[i] = strings.TrimSuffix(path, "/")
+	}
+
+	return func(c fiber.Ctx) error {
+		req := c.Request()
+		if !req.IsBodyStream() {
+			return c.Next()
+		}
+		if slices.Contains(paths, strings.TrimSuffix(c.Path(), "/")) {
+			body := &streamedBody{Reader: req.BodyStream()}
+			c.Locals(bodyKey, body)
+			err := c.Next()
+			if err != nil || !body.eof {
+				c.RequestCtx().SetConnectionClose()
+			}
+			return err
+		}
+		if req.Header.ContentLength() > limit {
+			c.RequestCtx().SetConnectionClose()
+			return fiber.ErrRequestEntityTooLarge
+		}
+
+		// Chunked bodies have no length up front
+		body, err := io.ReadAll(io.LimitReader(req.BodyStream(), int64(limit)+1))
+		if err != nil {
+			c.RequestCtx().SetConnectionClose()
+			return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+		}
+		if len(body) > limit {
+			c.RequestCtx().SetConnectionClose()
+			return fiber.ErrRequestEntityTooLarge
+		}
+		req.SetBody(body)
+		return c.Next()
+	}
+}
+
+// Body returns the request body, bodies of the paths streamed by BodyLimit
+// are read as they arrive
+func Body(c fiber.Ctx) io.Reader {
+	if body := streamed(c); body != nil {
+		return body
+	}
+	return bytes.NewReader(c.Body())
+}
+
+func streamed(c fiber.Ctx) *streamedBody {
+	if body, ok := c.Locals(bodyKey).(*streamedBody); ok {
+		return body
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package middleware_test
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newBodyLimitApp() *fiber.App {
+	app := fiber.New(fiber.Config{BodyLimit: 16, StreamRequestBody: true})
+	app.Use(middleware.BodyLimit(16, "/import"))
+	app.Post("/echo", func(c fiber.Ctx) error {
+		return c.Send(c.Body())
+	})
+	app.Post("/import", func(c fiber.Ctx) error {
+		body, err := io.ReadAll(middleware.Body(c))
+		if err != nil {
+			return err
+		}
+		return c.Send(body)
+	})
+	return app
+}
+
+func TestBodyLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		chunked bool
+		status  int
+	}{
+		{"within the limit", "/echo", "small body", false, fiber.StatusOK},
+		{"above the limit", "/echo", strings.Repeat("x", 17), false, fiber.StatusRequestEntityTooLarge},
+		{"chunked within the limit", "/echo", "small body", true, fiber.StatusOK},
+		{"chunked above the limit", "/echo", strings.Repeat("x", 17), true, fiber.StatusRequestEntityTooLarge},
+		{"streamed path", "/import", strings.Repeat("x", 1024), false, fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newBodyLimitApp()
+
+			req := httptest.NewRequest(fiber.MethodPost, tt.path, strings.NewReader(tt.body))
+			if tt.chunked {
+				req.ContentLength = -1
+				req.TransferEncoding = []string{"chunked"}
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+
+			assert.Equal(t, tt.status, resp.StatusCode)
+			// The rest of a refused body must not be read as another request
+			assert.Equal(t, tt.status != fiber.StatusOK, resp.Close)
+			if tt.status == fiber.StatusOK {
+				echoed, err := io.ReadAll(resp.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.body, string(echoed))
+			}
+		})
+	}
+}
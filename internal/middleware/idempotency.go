@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/gofiber/fiber/v3"
+)
+
+const (
+	HeaderIdempotencyKey     = "Idempotency-Key"
+	HeaderIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// unreplayedHeaders are set by the server for every response, a replay gets
+// fresh ones
+var unreplayedHeaders = map[string]bool{
+	fiber.HeaderDate:             true,
+	fiber.HeaderContentLength:    true,
+	fiber.HeaderServer:           true,
+	fiber.HeaderConnection:       true,
+	fiber.HeaderSetCookie:        true,
+	fiber.HeaderTransferEncoding: true,
+}
+
+// Idempotency makes requests carrying an Idempotency-Key header safe to
+// retry. The first request with a key runs and its response is stored,
+// retries get the stored response back. Keys are scoped to the user
+// authenticated by Auth, requests without a key or a user run as usual.
+//
+// Bodies streamed by BodyLimit are hashed as the handler reads them and
+// their digest is stored with the response, a retry reads its body to
+// compare it. Responses to requests whose body was not read to the end are
+// not stored.
+func Idempotency(service domain.IdempotencyService) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		key := c.Get(HeaderIdempotencyKey)
+		claims := Claims(c)
+		if key == "" || claims == nil {
+			return c.Next()
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			return fiber.NewError(fiber.StatusBadRequest, "Idempotency-Key is longer than 255 characters")
+		}
+
+		body := streamed(c)
+		digest := sha256.New()
+		if body != nil {
+			body.Reader = io.TeeReader(body.Reader, digest)
+		}
+
+		record := &domain.IdempotencyRecord{
+			UserID:      claims.UserID,
+			Key:         key,
+			Fingerprint: fingerprint(c, body != nil),
+		}
+		stored, err := service.Begin(c.Context(), record)
+		if err != nil {
+			return err
+		}
+		if stored != nil {
+			if body != nil {
+				if _, err := io.Copy(io.Discard, body); err != nil {
+					return fiber.NewError(fiber.StatusBadRequest, "Invalid request body")
+				}
+				if hex.EncodeToString(digest.Sum(nil)) != stored.BodyDigest {
+					return domain.ErrIdempotencyKeyReused
+				}
+			}
+			for name, value := range stored.Headers {
+				c.Set(name, value)
+			}
+			c.Set(HeaderIdempotentReplayed, "true")
+			return c.Status(stored.Status).Send(stored.Body)
+		}
+
+		// Errors are turned into responses here, so they are stored as well
+		if err := c.Next(); err != nil {
+			if err := c.App().ErrorHandler(c, err); err != nil {
+				_ = service.Release(c.Context(), record)
+				return err
+			}
+		}
+
+		// Server errors are not final, the retry should run again. Neither is
+		// a response to a body that was not read whole, it cannot be told
+		// apart from one to a different body.
+		status := c.Response().StatusCode()
+		if status >= fiber.StatusInternalServerError || (body != nil && !body.eof) {
+			_ = service.Release(c.Context(), record)
+			return nil
+		}
+
+		response := &domain.IdempotentResponse{
+			Status:  status,
+			Headers: map[string]string{},
+			Body:    bytes.Clone(c.Response().Body()),
+		}
+		if body != nil {
+			response.BodyDigest = hex.EncodeToString(digest.Sum(nil))
+		}
+		for name, value := range c.Response().Header.All() {
+			if !unreplayedHeaders[string(name)] {
+				response.Headers[string(name)] = string(value)
+			}
+		}
+		if err := service.Complete(c.Context(), record, response); err != nil {
+			_ = service.Release(c.Context(), record)
+		}
+		return nil
+	}
+}
+
+// fingerprint identifies the request a key was used for by its method, URL
+// and body. Streamed bodies are left out, they are compared by their digest
+// once read.
+func fingerprint(c fiber.Ctx, streamed bool) string {
+	var body []byte
+	if !streamed {
+		body = c.Body()
+	}
+
+	hash := sha256.New()
+	for _, part := range [][]byte{[]byte(c.Method()), c.Request().URI().RequestURI(), body} {
+		hash.Write(part)
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
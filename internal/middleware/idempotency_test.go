@@ -0,0 +1,248 @@
+package middleware_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/handler"
+	"github.com/DMaryanskiy/go-idk/internal/middleware"
+	"github.com/gofiber/fiber/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// stubTokens treats every token as user 1
+type stubTokens struct{}
+
+func (stubTokens) Generate(*domain.User) (string, time.Time, error) {
+	return "", time.Time{}, errors.New("not implemented")
+}
+
+func (stubTokens) Parse(string) (*domain.AccessClaims, error) {
+	return &domain.AccessClaims{UserID: 1}, nil
+}
+
+// memoryIdempotency keeps records in a map, records without a response are
+// in flight
+type memoryIdempotency struct {
+	records map[string]*domain.IdempotencyRecord
+}
+
+func (m *memoryIdempotency) Begin(_ context.Context, record *domain.IdempotencyRecord) (*domain.IdempotentResponse, error) {
+	held, ok := m.records[record.Key]
+	switch {
+	case !ok:
+		m.records[record.Key] = record
+		return nil, nil
+	case held.Fingerprint != record.Fingerprint:
+		return nil, domain.ErrIdempotencyKeyReused
+	case held.Response == nil:
+		return nil, domain.ErrIdempotencyKeyInFlight
+	default:
+		return held.Response, nil
+	}
+}
+
+func (m *memoryIdempotency) Complete(_ context.Context, record *domain.IdempotencyRecord, response *domain.IdempotentResponse) error {
+	m.records[record.Key].Response = response
+	return nil
+}
+
+func (m *memoryIdempotency) Release(_ context.Context, record *domain.IdempotencyRecord) error {
+	delete(m.records, record.Key)
+	return nil
+}
+
+func (m *memoryIdempotency) Prune(context.Context) error {
+	return nil
+}
+
+// newIdempotentApp counts the requests that reach the handler, bodies other
+// than "fail" and "conflict" create a resource
+func newIdempotentApp(service domain.IdempotencyService, calls *int) *fiber.App {
+	app := fiber.New(fiber.Config{ErrorHandler: handler.ErrorHandler(zap.NewNop())})
+	app.Post("/", middleware.Auth(stubTokens{}), middleware.Idempotency(service), func(c fiber.Ctx) error {
+		*calls++
+		switch string(c.Body()) {
+		case "fail":
+			return fiber.ErrInternalServerError
+		case "conflict":
+			return domain.ErrEmailInUse
+		}
+		c.Set(fiber.HeaderLocation, "/1")
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"call": *calls})
+	})
+	return app
+}
+
+type result struct {
+	status   int
+	location string
+	replayed string
+	body     string
+}
+
+func post(app *fiber.App, key, body string) (*result, error) {
+	req := httptest.NewRequest(fiber.MethodPost, "/", strings.NewReader(body))
+	req.Header.Set(fiber.HeaderAuthorization, "Bearer 1")
+	if key != "" {
+		req.Header.Set(middleware.HeaderIdempotencyKey, key)
+	}
+	resp, err := app.Test(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &result{
+		status:   resp.StatusCode,
+		location: resp.Header.Get(fiber.HeaderLocation),
+		replayed: resp.Header.Get(middleware.HeaderIdempotentReplayed),
+		body:     string(data),
+	}, nil
+}
+
+func TestIdempotency_ReplaysResponse(t *testing.T) {
+	calls := 0
+	app := newIdempotentApp(&memoryIdempotency{records: map[string]*domain.IdempotencyRecord{}}, &calls)
+
+	first, err := post(app, "key", "create")
+	require.NoError(t, err)
+	retry, err := post(app, "key", "create")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, fiber.StatusCreated, retry.status)
+	assert.Equal(t, first.body, retry.body)
+	assert.Equal(t, "/1", retry.location)
+	assert.Empty(t, first.replayed)
+	assert.Equal(t, "true", retry.replayed)
+}
+
+func TestIdempotency_ReplaysClientErrors(t *testing.T) {
+	calls := 0
+	app := newIdempotentApp(&memoryIdempotency{records: map[string]*domain.IdempotencyRecord{}}, &calls)
+
+	first, err := post(app, "key", "conflict")
+	require.NoError(t, err)
+	retry, err := post(app, "key", "conflict")
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, fiber.StatusConflict, retry.status)
+	assert.Equal(t, first.body, retry.body)
+}
+
+func TestIdempotency_RetriesServerErrors(t *testing.T) {
+	calls := 0
+	app := newIdempotentApp(&memoryIdempotency{records: map[string]*domain.IdempotencyRecord{}}, &calls)
+
+	first, err := post(app, "key", "fail")
+	require.NoError(t, err)
+	retry, err := post(app, "key", "fail")
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, fiber.StatusInternalServerError, first.status)
+	assert.Empty(t, retry.replayed)
+}
+
+func TestIdempotency_Rejects(t *testing.T) {
+	calls := 0
+	service := &memoryIdempotency{records: map[string]*domain.IdempotencyRecord{
+		"running": {Key: "running"},
+	}}
+	app := newIdempotentApp(service, &calls)
+
+	_, err := post(app, "key", "create")
+	require.NoError(t, err)
+	service.records["running"].Fingerprint = service.records["key"].Fingerprint
+
+	tests := []struct {
+		name   string
+		key    string
+		status int
+	}{
+		{"other payload", "key", fiber.StatusUnprocessableEntity},
+		{"in flight", "running", fiber.StatusConflict},
+		{"long key", strings.Repeat("k", 256), fiber.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := "create"
+			if tt.key == "key" {
+				body = "create again"
+			}
+			resp, err := post(app, tt.key, body)
+			require.NoError(t, err)
+			assert.Equal(t, tt.status, resp.status, resp.body)
+		})
+	}
+	assert.Equal(t, 1, calls)
+}
+
+func TestIdempotency_WithoutKey(t *testing.T) {
+	calls := 0
+	app := newIdempotentApp(&memoryIdempotency{records: map[string]*domain.IdempotencyRecord{}}, &calls)
+
+	for range 2 {
+		resp, err := post(app, "", "create")
+		require.NoError(t, err)
+		assert.Equal(t, fiber.StatusCreated, resp.status)
+	}
+	assert.Equal(t, 2, calls)
+}
+
+func TestIdempotency_StreamedBody(t *testing.T) {
+	calls := 0
+	app := fiber.New(fiber.Config{ErrorHandler: handler.ErrorHandler(zap.NewNop()), StreamRequestBody: true})
+	app.Use(middleware.BodyLimit(1024, "/"))
+	app.Post("/", middleware.Auth(stubTokens{}), middleware.Idempotency(&memoryIdempotency{records: map[string]*domain.IdempotencyRecord{}}), func(c fiber.Ctx) error {
+		calls++
+		// Bodies starting with "skip" are left unread
+		body, err := io.ReadAll(io.LimitReader(middleware.Body(c), 4))
+		if err != nil {
+			return err
+		}
+		if string(body) != "skip" {
+			if _, err := io.Copy(io.Discard, middleware.Body(c)); err != nil {
+				return err
+			}
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{"call": calls})
+	})
+
+	first, err := post(app, "key", "email,name")
+	require.NoError(t, err)
+	retry, err := post(app, "key", "email,name")
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusCreated, retry.status)
+	assert.Equal(t, first.body, retry.body)
+	assert.Equal(t, "true", retry.replayed)
+
+	// The length of the body alone does not tell requests apart
+	other, err := post(app, "key", "name,email")
+	require.NoError(t, err)
+	assert.Equal(t, fiber.StatusUnprocessableEntity, other.status)
+	assert.Equal(t, 1, calls)
+
+	// A response to a body that was not read whole is not stored
+	for range 2 {
+		resp, err := post(app, "unread", "skip the rest")
+		require.NoError(t, err)
+		assert.Empty(t, resp.replayed)
+	}
+	assert.Equal(t, 3, calls)
+}
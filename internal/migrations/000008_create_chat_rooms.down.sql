@@ -0,0 +1,13 @@
+DROP TABLE IF EXISTS room_bans;
+DROP TABLE IF EXISTS room_invites;
+
+ALTER TABLE conversation_members DROP COLUMN IF EXISTS role;
+
+DELETE FROM conversations WHERE kind = 'room';
+DROP INDEX IF EXISTS idx_conversations_public_rooms;
+ALTER TABLE conversations DROP COLUMN IF EXISTS created_by;
+ALTER TABLE conversations DROP COLUMN IF EXISTS visibility;
+ALTER TABLE conversations DROP COLUMN IF EXISTS topic;
+ALTER TABLE conversations DROP COLUMN IF EXISTS name;
+ALTER TABLE conversations DROP CONSTRAINT IF EXISTS conversations_kind_check;
+ALTER TABLE conversations ADD CONSTRAINT conversations_kind_check CHECK (kind IN ('direct'));
@@ -0,0 +1,3 @@
+-- Messages up to this id were read by the member, 0 means nothing was read yet
+ALTER TABLE conversation_members ADD COLUMN IF NOT EXISTS last_read_message_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE conversation_members ADD COLUMN IF NOT EXISTS last_read_at TIMESTAMP;
@@ -0,0 +1,11 @@
+-- One row per open WebSocket connection on any instance. Rows of crashed
+-- instances stop being refreshed and are swept once their heartbeat is stale.
+CREATE TABLE IF NOT EXISTS presence_connections (
+    id UUID PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status VARCHAR(16) NOT NULL DEFAULT 'online' CHECK (status IN ('online', 'away')),
+    last_seen_at TIMESTAMP NOT NULL,
+    connected_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_presence_connections_user_id ON presence_connections(user_id, last_seen_at);
+CREATE INDEX IF NOT EXISTS idx_presence_connections_last_seen_at ON presence_connections(last_seen_at);
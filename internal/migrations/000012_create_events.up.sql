@@ -0,0 +1,11 @@
+-- Events delivered to users, kept for EVENT_RETENTION so clients of the
+-- event stream can resume from the last event they saw
+CREATE TABLE IF NOT EXISTS events (
+    id BIGSERIAL PRIMARY KEY,
+    user_ids INTEGER[] NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_events_user_ids ON events USING GIN (user_ids);
+CREATE INDEX IF NOT EXISTS idx_events_created_at ON events(created_at);
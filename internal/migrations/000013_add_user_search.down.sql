@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_users_name;
+DROP INDEX IF EXISTS idx_users_email_domain;
+DROP INDEX IF EXISTS idx_users_email_trgm;
+DROP INDEX IF EXISTS idx_users_name_trgm;
+ALTER TABLE users ALTER COLUMN created_at DROP NOT NULL, ALTER COLUMN updated_at DROP NOT NULL;
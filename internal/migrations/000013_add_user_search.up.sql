@@ -0,0 +1,11 @@
+-- Keyset pagination compares these columns, NULLs would break the ordering
+UPDATE users SET created_at = CURRENT_TIMESTAMP WHERE created_at IS NULL;
+UPDATE users SET updated_at = created_at WHERE updated_at IS NULL;
+ALTER TABLE users ALTER COLUMN created_at SET NOT NULL, ALTER COLUMN updated_at SET NOT NULL;
+
+-- Trigram indexes serve the case-insensitive partial matches of ?q=
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX IF NOT EXISTS idx_users_name_trgm ON users USING GIN (name gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_email_trgm ON users USING GIN (email gin_trgm_ops);
+CREATE INDEX IF NOT EXISTS idx_users_email_domain ON users (split_part(email, '@', 2));
+CREATE INDEX IF NOT EXISTS idx_users_name ON users (name, id);
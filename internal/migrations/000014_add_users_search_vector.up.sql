@@ -0,0 +1,10 @@
+-- Names and emails are not prose, the simple configuration keeps every word
+-- as it is instead of stemming it. Emails are indexed whole and split at @ so
+-- both the address and its domain match. Future profile fields are added by
+-- dropping and recreating the column with a longer expression.
+ALTER TABLE users ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    setweight(to_tsvector('simple', name), 'A') ||
+    setweight(to_tsvector('simple', email || ' ' || replace(email, '@', ' ')), 'B')
+) STORED;
+
+CREATE INDEX IF NOT EXISTS idx_users_search_vector ON users USING GIN (search_vector);
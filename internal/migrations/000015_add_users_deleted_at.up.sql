@@ -0,0 +1,10 @@
+ALTER TABLE users ADD COLUMN deleted_at TIMESTAMP;
+
+-- Emails are only unique among active users, the email of a deleted user can
+-- be registered again. Restoring the old user then fails until one of them
+-- changes it.
+ALTER TABLE users DROP CONSTRAINT IF EXISTS users_email_key;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_email_active ON users (email) WHERE deleted_at IS NULL;
+
+-- The purger looks for users deleted before the retention period
+CREATE INDEX IF NOT EXISTS idx_users_deleted_at ON users (deleted_at) WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Bumped by every change to what clients see of a user, the ETag of a user
+-- is its version
+ALTER TABLE users ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
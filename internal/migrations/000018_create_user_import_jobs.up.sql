@@ -0,0 +1,19 @@
+-- Bulk user imports running in the background. result holds the outcome of
+-- every row once the job succeeded, updated_at tells running jobs whose
+-- instance stopped apart from slow ones.
+CREATE TABLE IF NOT EXISTS user_import_jobs (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    format VARCHAR(10) NOT NULL,
+    dry_run BOOLEAN NOT NULL DEFAULT FALSE,
+    status VARCHAR(20) NOT NULL,
+    processed INTEGER NOT NULL DEFAULT 0,
+    result JSONB,
+    error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_import_jobs_running ON user_import_jobs (updated_at) WHERE finished_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx_user_import_jobs_finished_at ON user_import_jobs (finished_at) WHERE finished_at IS NOT NULL;
@@ -0,0 +1,3 @@
+-- Bodies of streamed requests are hashed as they are read, so their digest
+-- is only known once the response is stored
+ALTER TABLE idempotency_keys ADD COLUMN body_digest CHAR(64);
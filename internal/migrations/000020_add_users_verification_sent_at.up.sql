@@ -0,0 +1,8 @@
+-- When the user was last sent a verification code. Issuing a code checks
+-- and sets it in one statement, so parallel resends cannot both pass the
+-- throttle.
+ALTER TABLE users ADD COLUMN verification_sent_at TIMESTAMP;
+
+UPDATE users SET verification_sent_at = (
+    SELECT MAX(created_at) FROM verification_codes WHERE verification_codes.user_id = users.id
+);
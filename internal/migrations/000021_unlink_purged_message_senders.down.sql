@@ -0,0 +1,5 @@
+DELETE FROM messages WHERE sender_id IS NULL;
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_sender_id_fkey;
+ALTER TABLE messages ADD CONSTRAINT messages_sender_id_fkey
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE messages ALTER COLUMN sender_id SET NOT NULL;
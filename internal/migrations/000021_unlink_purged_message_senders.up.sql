@@ -0,0 +1,6 @@
+-- Messages outlive their sender, the other members of the conversation
+-- still expect to see them. Purging a user unlinks the messages they sent.
+ALTER TABLE messages ALTER COLUMN sender_id DROP NOT NULL;
+ALTER TABLE messages DROP CONSTRAINT IF EXISTS messages_sender_id_fkey;
+ALTER TABLE messages ADD CONSTRAINT messages_sender_id_fkey
+    FOREIGN KEY (sender_id) REFERENCES users(id) ON DELETE SET NULL;
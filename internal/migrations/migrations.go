@@ -0,0 +1,11 @@
+// Package migrations holds the versioned SQL migrations of the service.
+//
+// Files are named <version>_<name>.up.sql and <version>_<name>.down.sql and
+// are embedded into the binary, so every environment applies exactly the
+// same schema changes.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,587 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/lib/pq"
+)
+
+// messageColumns never exposes the body of a deleted message, messages of
+// purged senders have sender 0
+const messageColumns = `
+	m.id, m.conversation_id, COALESCE(m.sender_id, 0), CASE WHEN m.deleted_at IS NULL THEN m.body ELSE '' END,
+	m.created_at, m.edited_at, m.deleted_at`
+
+func scanMessage(row interface{ Scan(...any) error }, msg *domain.Message) error {
+	return row.Scan(
+		&msg.ID, &msg.ConversationID, &msg.SenderID, &msg.Body, &msg.CreatedAt, &msg.EditedAt, &msg.DeletedAt,
+	)
+}
+
+type chatRepository struct {
+	db *database.DB
+}
+
+func NewChatRepository(db *database.DB) domain.ChatRepository {
+	return &chatRepository{db: db}
+}
+
+func (r *chatRepository) CreateDirectMessage(ctx context.Context, recipientID int, msg *domain.Message) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	// The no-op update makes RETURNING yield the id of an existing conversation
+	conversation := `
+	INSERT INTO conversations (kind, direct_key)
+	VALUES ($1, $2)
+	ON CONFLICT (direct_key) DO UPDATE SET direct_key = EXCLUDED.direct_key
+	RETURNING id;`
+
+	err = tx.QueryRowContext(ctx, conversation, domain.ConversationDirect, directKey(msg.SenderID, recipientID)).
+		Scan(&msg.ConversationID)
+	if err != nil {
+		return fmt.Errorf("error creating conversation: %w", err)
+	}
+
+	members := `
+	INSERT INTO conversation_members (conversation_id, user_id)
+	VALUES ($1, $2), ($1, $3)
+	ON CONFLICT DO NOTHING;`
+
+	if _, err = tx.ExecContext(ctx, members, msg.ConversationID, msg.SenderID, recipientID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgForeignKeyViolation {
+			return domain.ErrRecipientNotFound
+		}
+		return fmt.Errorf("error adding conversation members: %w", err)
+	}
+
+	insert := `
+	INSERT INTO messages (conversation_id, sender_id, body)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at;`
+
+	err = tx.QueryRowContext(ctx, insert, msg.ConversationID, msg.SenderID, msg.Body).Scan(&msg.ID, &msg.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating message: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *chatRepository) CreateMessage(ctx context.Context, msg *domain.Message) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO messages (conversation_id, sender_id, body)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at;`
+
+	err := r.db.QueryRowContext(ctx, query, msg.ConversationID, msg.SenderID, msg.Body).Scan(&msg.ID, &msg.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating message: %w", err)
+	}
+	return nil
+}
+
+func (r *chatRepository) GetMember(ctx context.Context, conversationID int64, userID int) (*domain.ConversationMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	member := &domain.ConversationMember{}
+	query := `
+	SELECT conversation_id, user_id, role, last_read_message_id, last_read_at, joined_at
+	FROM conversation_members
+	WHERE conversation_id = $1 AND user_id = $2;`
+
+	err := r.db.QueryRowContext(ctx, query, conversationID, userID).Scan(
+		&member.ConversationID, &member.UserID, &member.Role,
+		&member.LastReadMessageID, &member.LastReadAt, &member.JoinedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting conversation member: %w", err)
+	}
+
+	return member, nil
+}
+
+func (r *chatRepository) GetMembers(ctx context.Context, conversationID int64) (members []domain.ConversationMember, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT conversation_id, user_id, role, last_read_message_id, last_read_at, joined_at
+	FROM conversation_members
+	WHERE conversation_id = $1
+	ORDER BY joined_at, user_id;`
+
+	rows, err := r.db.QueryContext(ctx, query, conversationID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting conversation members: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	members = []domain.ConversationMember{}
+	for rows.Next() {
+		var member domain.ConversationMember
+		err = rows.Scan(
+			&member.ConversationID, &member.UserID, &member.Role,
+			&member.LastReadMessageID, &member.LastReadAt, &member.JoinedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning conversation member: %w", err)
+		}
+		members = append(members, member)
+	}
+
+	return members, nil
+}
+
+func (r *chatRepository) GetContactIDs(ctx context.Context, userID int) (ids []int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT DISTINCT other.user_id
+	FROM conversation_members me
+	JOIN conversation_members other
+		ON other.conversation_id = me.conversation_id AND other.user_id <> me.user_id
+	WHERE me.user_id = $1;`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting contacts: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	ids = []int{}
+	for rows.Next() {
+		var id int
+		if err = rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("error scanning contact: %w", err)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+func (r *chatRepository) GetConversations(ctx context.Context, userID int) (conversations []domain.ConversationSummary, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// The lateral join uses the (conversation_id, id) index to find the last message
+	query := `
+	SELECT c.id, c.kind, COALESCE(c.name, ''), COALESCE(peer.user_id, 0), me.last_read_message_id,
+		last.id, last.sender_id, last.body, last.created_at, last.edited_at, last.deleted_at,
+		(SELECT COUNT(*) FROM messages unread
+		WHERE unread.conversation_id = c.id
+			AND unread.id > me.last_read_message_id
+			AND unread.sender_id IS DISTINCT FROM me.user_id
+			AND unread.deleted_at IS NULL)
+	FROM conversation_members me
+	JOIN conversations c ON c.id = me.conversation_id
+	LEFT JOIN LATERAL (
+		SELECT` + messageColumns + `
+		FROM messages m
+		WHERE m.conversation_id = c.id
+		ORDER BY m.id DESC
+		LIMIT 1
+	) last (id, conversation_id, sender_id, body, created_at, edited_at, deleted_at) ON TRUE
+	LEFT JOIN conversation_members peer
+		ON c.kind = 'direct' AND peer.conversation_id = c.id AND peer.user_id <> me.user_id
+	WHERE me.user_id = $1
+	ORDER BY COALESCE(last.created_at, c.created_at) DESC, c.id DESC;`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting conversations: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	conversations = []domain.ConversationSummary{}
+	for rows.Next() {
+		var (
+			c         domain.ConversationSummary
+			lastID    *int64
+			senderID  *int
+			body      *string
+			sentAt    *time.Time
+			editedAt  *time.Time
+			deletedAt *time.Time
+		)
+		err = rows.Scan(
+			&c.ID, &c.Kind, &c.Name, &c.PeerID, &c.LastReadMessageID,
+			&lastID, &senderID, &body, &sentAt, &editedAt, &deletedAt, &c.UnreadCount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning conversation: %w", err)
+		}
+		if lastID != nil {
+			c.LastMessage = &domain.Message{
+				ID:             *lastID,
+				ConversationID: c.ID,
+				SenderID:       *senderID,
+				Body:           *body,
+				CreatedAt:      *sentAt,
+				EditedAt:       editedAt,
+				DeletedAt:      deletedAt,
+			}
+		}
+		conversations = append(conversations, c)
+	}
+
+	return conversations, nil
+}
+
+func (r *chatRepository) GetMessages(ctx context.Context, conversationID, before int64, limit int) (messages []domain.Message, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if before <= 0 {
+		before = math.MaxInt64
+	}
+
+	query := `SELECT` + messageColumns + `
+	FROM messages m
+	WHERE m.conversation_id = $1 AND m.id < $2
+	ORDER BY m.id DESC
+	LIMIT $3;`
+
+	rows, err := r.db.QueryContext(ctx, query, conversationID, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting messages: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	messages = []domain.Message{}
+	for rows.Next() {
+		var msg domain.Message
+		if err = scanMessage(rows, &msg); err != nil {
+			return nil, fmt.Errorf("error scanning message: %w", err)
+		}
+		messages = append(messages, msg)
+	}
+
+	if err = r.loadReactions(ctx, messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (r *chatRepository) GetMessage(ctx context.Context, id int64) (*domain.Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT` + messageColumns + `
+	FROM messages m
+	WHERE m.id = $1;`
+
+	msg := &domain.Message{}
+	err := scanMessage(r.db.QueryRowContext(ctx, query, id), msg)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting message: %w", err)
+	}
+
+	messages := []domain.Message{*msg}
+	if err := r.loadReactions(ctx, messages); err != nil {
+		return nil, err
+	}
+	return &messages[0], nil
+}
+
+func (r *chatRepository) UpdateMessage(ctx context.Context, msg *domain.Message) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	// The row lock keeps concurrent edits from recording the same previous body
+	var (
+		previous  string
+		deletedAt *time.Time
+	)
+	lock := `SELECT body, deleted_at FROM messages WHERE id = $1 FOR UPDATE;`
+	err = tx.QueryRowContext(ctx, lock, msg.ID).Scan(&previous, &deletedAt)
+	if err == sql.ErrNoRows {
+		return domain.ErrMessageNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error locking message: %w", err)
+	}
+	if deletedAt != nil {
+		return domain.ErrMessageDeleted
+	}
+
+	history := `
+	INSERT INTO message_edits (message_id, body, edited_at)
+	VALUES ($1, $2, $3);`
+
+	if _, err = tx.ExecContext(ctx, history, msg.ID, previous, *msg.EditedAt); err != nil {
+		return fmt.Errorf("error recording message edit: %w", err)
+	}
+
+	update := `
+	UPDATE messages
+	SET body = $2, edited_at = $3
+	WHERE id = $1;`
+
+	if _, err = tx.ExecContext(ctx, update, msg.ID, msg.Body, *msg.EditedAt); err != nil {
+		return fmt.Errorf("error updating message: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *chatRepository) DeleteMessage(ctx context.Context, id int64, deletedAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	UPDATE messages
+	SET deleted_at = $2
+	WHERE id = $1 AND deleted_at IS NULL;`
+
+	result, err := r.db.ExecContext(ctx, query, id, deletedAt)
+	if err != nil {
+		return fmt.Errorf("error deleting message: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking updated rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrMessageDeleted
+	}
+	return nil
+}
+
+func (r *chatRepository) GetMessageEdits(ctx context.Context, messageID int64) (edits []domain.MessageEdit, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT message_id, body, edited_at
+	FROM message_edits
+	WHERE message_id = $1
+	ORDER BY id;`
+
+	rows, err := r.db.QueryContext(ctx, query, messageID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting message edits: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	edits = []domain.MessageEdit{}
+	for rows.Next() {
+		var edit domain.MessageEdit
+		if err = rows.Scan(&edit.MessageID, &edit.Body, &edit.EditedAt); err != nil {
+			return nil, fmt.Errorf("error scanning message edit: %w", err)
+		}
+		edits = append(edits, edit)
+	}
+
+	return edits, nil
+}
+
+func (r *chatRepository) AddReaction(ctx context.Context, reaction *domain.Reaction) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO message_reactions (message_id, user_id, emoji)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING;`
+
+	result, err := r.db.ExecContext(ctx, query, reaction.MessageID, reaction.UserID, reaction.Emoji)
+	if err != nil {
+		return false, fmt.Errorf("error adding reaction: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error checking inserted rows: %w", err)
+	}
+	return rows > 0, nil
+}
+
+func (r *chatRepository) RemoveReaction(ctx context.Context, reaction *domain.Reaction) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	DELETE FROM message_reactions
+	WHERE message_id = $1 AND user_id = $2 AND emoji = $3;`
+
+	result, err := r.db.ExecContext(ctx, query, reaction.MessageID, reaction.UserID, reaction.Emoji)
+	if err != nil {
+		return fmt.Errorf("error removing reaction: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking removed rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrReactionNotFound
+	}
+	return nil
+}
+
+// loadReactions fills the reactions of messages with a single query
+func (r *chatRepository) loadReactions(ctx context.Context, messages []domain.Message) (err error) {
+	if len(messages) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, 0, len(messages))
+	index := make(map[int64]int, len(messages))
+	for i, msg := range messages {
+		ids = append(ids, msg.ID)
+		index[msg.ID] = i
+	}
+
+	// Emojis are ordered by their first use, so they do not jump around in clients
+	query := `
+	SELECT message_id, emoji, array_agg(user_id ORDER BY created_at, user_id)
+	FROM message_reactions
+	WHERE message_id = ANY($1)
+	GROUP BY message_id, emoji
+	ORDER BY message_id, MIN(created_at), emoji;`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("error getting reactions: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	for rows.Next() {
+		var (
+			messageID int64
+			emoji     string
+			userIDs   pq.Int64Array
+		)
+		if err = rows.Scan(&messageID, &emoji, &userIDs); err != nil {
+			return fmt.Errorf("error scanning reaction: %w", err)
+		}
+
+		summary := domain.ReactionSummary{Emoji: emoji, UserIDs: make([]int, 0, len(userIDs))}
+		for _, id := range userIDs {
+			summary.UserIDs = append(summary.UserIDs, int(id))
+		}
+		msg := &messages[index[messageID]]
+		msg.Reactions = append(msg.Reactions, summary)
+	}
+
+	return nil
+}
+
+func (r *chatRepository) MarkRead(ctx context.Context, conversationID int64, userID int, messageID int64, readAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// GREATEST keeps the marker from moving back when receipts arrive out of order
+	query := `
+	UPDATE conversation_members
+	SET last_read_message_id = GREATEST(last_read_message_id, $3),
+		last_read_at = CASE WHEN last_read_message_id < $3 THEN $4 ELSE last_read_at END
+	WHERE conversation_id = $1 AND user_id = $2
+		AND EXISTS (SELECT 1 FROM messages WHERE id = $3 AND conversation_id = $1);`
+
+	result, err := r.db.ExecContext(ctx, query, conversationID, userID, messageID, readAt)
+	if err != nil {
+		return fmt.Errorf("error marking conversation read: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking updated rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrMessageNotFound
+	}
+
+	return nil
+}
+
+// directKey identifies the direct conversation of two users regardless of
+// who writes first
+func directKey(a, b int) string {
+	if a > b {
+		a, b = b, a
+	}
+	return fmt.Sprintf("%d:%d", a, b)
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateDirectMessage(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewChatRepository(&database.DB{DB: db})
+	msg := &domain.Message{SenderID: 7, Body: "hello"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO conversations").
+		WithArgs(domain.ConversationDirect, "3:7").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectExec("INSERT INTO conversation_members").
+		WithArgs(int64(11), 7, 3).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery("INSERT INTO messages").
+		WithArgs(int64(11), 7, "hello").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(42, time.Now()))
+	mock.ExpectCommit()
+
+	err = repo.CreateDirectMessage(context.Background(), 3, msg)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(11), msg.ConversationID)
+	assert.Equal(t, int64(42), msg.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateDirectMessage_UnknownRecipient(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewChatRepository(&database.DB{DB: db})
+	msg := &domain.Message{SenderID: 7, Body: "hello"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("INSERT INTO conversations").
+		WithArgs(domain.ConversationDirect, "7:99").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(11))
+	mock.ExpectExec("INSERT INTO conversation_members").
+		WillReturnError(&pq.Error{Code: pgForeignKeyViolation})
+	mock.ExpectRollback()
+
+	err = repo.CreateDirectMessage(context.Background(), 99, msg)
+
+	assert.ErrorIs(t, err, domain.ErrRecipientNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetMessages(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewChatRepository(&database.DB{DB: db})
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "conversation_id", "sender_id", "body", "created_at", "edited_at", "deleted_at"}).
+		AddRow(41, 11, 7, "second", now, now, nil).
+		AddRow(40, 11, 3, "", now, nil, now)
+
+	mock.ExpectQuery("SELECT (.+) FROM messages m WHERE m.conversation_id = (.+) AND m.id <").
+		WithArgs(int64(11), int64(42), 2).
+		WillReturnRows(rows)
+	mock.ExpectQuery("SELECT (.+) FROM message_reactions").
+		WithArgs(pq.Array([]int64{41, 40})).
+		WillReturnRows(sqlmock.NewRows([]string{"message_id", "emoji", "user_ids"}).AddRow(41, "👍", "{3,7}"))
+
+	messages, err := repo.GetMessages(context.Background(), 11, 42, 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, messages, 2)
+	assert.Equal(t, int64(41), messages[0].ID)
+	assert.NotNil(t, messages[0].EditedAt)
+	assert.Equal(t, []domain.ReactionSummary{{Emoji: "👍", UserIDs: []int{3, 7}}}, messages[0].Reactions)
+	assert.NotNil(t, messages[1].DeletedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMarkRead_MessageNotFound(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewChatRepository(&database.DB{DB: db})
+	readAt := time.Now()
+
+	mock.ExpectExec("UPDATE conversation_members").
+		WithArgs(int64(11), 7, int64(99), readAt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.MarkRead(context.Background(), 11, 7, 99, readAt)
+
+	assert.ErrorIs(t, err, domain.ErrMessageNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateMessage_Deleted(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewChatRepository(&database.DB{DB: db})
+	editedAt := time.Now()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT body, deleted_at FROM messages WHERE id = (.+) FOR UPDATE").
+		WithArgs(int64(41)).
+		WillReturnRows(sqlmock.NewRows([]string{"body", "deleted_at"}).AddRow("old", time.Now()))
+	mock.ExpectRollback()
+
+	err = repo.UpdateMessage(context.Background(), &domain.Message{ID: 41, Body: "new", EditedAt: &editedAt})
+
+	assert.ErrorIs(t, err, domain.ErrMessageDeleted)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
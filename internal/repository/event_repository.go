@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/lib/pq"
+)
+
+type eventRepository struct {
+	db *database.DB
+}
+
+func NewEventRepository(db *database.DB) domain.EventRepository {
+	return &eventRepository{db: db}
+}
+
+// eventLockID is the key of the Postgres advisory lock that serializes event
+// inserts. An event gets its ID only after the previous one committed, so
+// IDs become visible in order and nobody reading up to an ID misses a
+// smaller one that commits later.
+//
+// The lock is global: every chat event of the cluster is inserted one at a
+// time, which bounds the events per second to what a single short
+// transaction allows. That is the price of gap-free ordering without a
+// sequence per recipient; the transaction only holds the lock for the insert.
+const eventLockID int64 = 7_120_426_335_190_012
+
+func (r *eventRepository) Create(ctx context.Context, event *domain.StoredEvent) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	// Released on commit or rollback
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1);", eventLockID); err != nil {
+		return fmt.Errorf("error locking events: %w", err)
+	}
+
+	query := `
+	INSERT INTO events (user_ids, type, payload)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at;`
+
+	err = tx.QueryRowContext(ctx, query, pq.Array(event.UserIDs), event.Type, []byte(event.Payload)).
+		Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating event: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *eventRepository) GetLatestID(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var id int64
+	if err := r.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(id), 0) FROM events;").Scan(&id); err != nil {
+		return 0, fmt.Errorf("error getting latest event id: %w", err)
+	}
+	return id, nil
+}
+
+func (r *eventRepository) GetAfter(ctx context.Context, afterID int64, userIDs []int, limit int) (events []domain.StoredEvent, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT id, user_ids, type, payload, created_at
+	FROM events
+	WHERE id > $1 AND user_ids && $2::INTEGER[]
+	ORDER BY id
+	LIMIT $3;`
+
+	rows, err := r.db.QueryContext(ctx, query, afterID, pq.Array(userIDs), limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting events: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	events = []domain.StoredEvent{}
+	for rows.Next() {
+		var (
+			event   domain.StoredEvent
+			userIDs pq.Int64Array
+			payload []byte
+		)
+		if err := rows.Scan(&event.ID, &userIDs, &event.Type, &payload, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning event: %w", err)
+		}
+		event.UserIDs = make([]int, 0, len(userIDs))
+		for _, id := range userIDs {
+			event.UserIDs = append(event.UserIDs, int(id))
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (r *eventRepository) GetSince(ctx context.Context, userID int, afterID int64, limit int) (events []domain.StoredEvent, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT id, type, payload, created_at
+	FROM events
+	WHERE user_ids @> ARRAY[$1]::INTEGER[] AND id > $2
+	ORDER BY id
+	LIMIT $3;`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, afterID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error getting events: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	events = []domain.StoredEvent{}
+	for rows.Next() {
+		event := domain.StoredEvent{UserIDs: []int{userID}}
+		var payload []byte
+		if err := rows.Scan(&event.ID, &event.Type, &payload, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("error scanning event: %w", err)
+		}
+		event.Payload = payload
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating events: %w", err)
+	}
+
+	return events, nil
+}
+
+func (r *eventRepository) DeleteBefore(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	result, err := r.db.ExecContext(ctx, "DELETE FROM events WHERE created_at < $1", before)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting events: %w", err)
+	}
+
+	removed, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error checking removed rows: %w", err)
+	}
+	return removed, nil
+}
@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateEventLocksBeforeInsert(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewEventRepository(&database.DB{DB: db})
+
+	createdAt := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectExec(`SELECT pg_advisory_xact_lock\(\$1\)`).
+		WithArgs(eventLockID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(`INSERT INTO events`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(101, createdAt))
+	mock.ExpectCommit()
+
+	event := &domain.StoredEvent{UserIDs: []int{1, 2}, Type: domain.EventMessageNew, Payload: json.RawMessage(`"hi"`)}
+	err = repo.Create(context.Background(), event)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(101), event.ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetEventsAfterForConnectedUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewEventRepository(&database.DB{DB: db})
+
+	rows := sqlmock.NewRows([]string{"id", "user_ids", "type", "payload", "created_at"}).
+		AddRow(102, "{1,2}", domain.EventMessageNew, []byte(`"hi"`), time.Now())
+	mock.ExpectQuery(`SELECT (.+) FROM events WHERE id > \$1 AND user_ids && \$2::INTEGER\[\] ORDER BY id LIMIT \$3`).
+		WithArgs(int64(101), "{2,3}", 500).
+		WillReturnRows(rows)
+
+	events, err := repo.GetAfter(context.Background(), 101, []int{2, 3}, 500)
+
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, []int{1, 2}, events[0].UserIDs)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
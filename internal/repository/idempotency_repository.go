@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+)
+
+type idempotencyRepository struct {
+	db *database.DB
+}
+
+func NewIdempotencyRepository(db *database.DB) domain.IdempotencyRepository {
+	return &idempotencyRepository{db: db}
+}
+
+func (r *idempotencyRepository) Claim(ctx context.Context, record *domain.IdempotencyRecord, ttl, lockTimeout time.Duration) (*domain.IdempotencyRecord, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Expired records and requests that never completed, because their
+	// instance crashed, give the key up in the same statement. Times are
+	// taken from the database so instances with skewed clocks agree.
+	claim := `
+	INSERT INTO idempotency_keys (user_id, idempotency_key, fingerprint, expires_at)
+	VALUES ($1, $2, $3, CURRENT_TIMESTAMP + make_interval(secs => $4))
+	ON CONFLICT (user_id, idempotency_key) DO UPDATE
+	SET fingerprint = EXCLUDED.fingerprint, status = NULL, headers = NULL, body = NULL, body_digest = NULL,
+		created_at = CURRENT_TIMESTAMP, expires_at = EXCLUDED.expires_at
+	WHERE idempotency_keys.expires_at < CURRENT_TIMESTAMP
+		OR (idempotency_keys.status IS NULL AND idempotency_keys.created_at < CURRENT_TIMESTAMP - make_interval(secs => $5))
+	RETURNING created_at, expires_at;`
+
+	err := r.db.QueryRowContext(ctx, claim, record.UserID, record.Key, record.Fingerprint, ttl.Seconds(), lockTimeout.Seconds()).
+		Scan(&record.CreatedAt, &record.ExpiresAt)
+	if err == nil {
+		return nil, true, nil
+	}
+	if err != sql.ErrNoRows {
+		return nil, false, fmt.Errorf("error claiming idempotency key: %w", err)
+	}
+
+	held := &domain.IdempotencyRecord{UserID: record.UserID, Key: record.Key}
+	query := `
+	SELECT fingerprint, status, headers, body, body_digest, created_at, expires_at
+	FROM idempotency_keys
+	WHERE user_id = $1 AND idempotency_key = $2;`
+
+	var status sql.NullInt64
+	var headers, body []byte
+	var bodyDigest sql.NullString
+	err = r.db.QueryRowContext(ctx, query, record.UserID, record.Key).
+		Scan(&held.Fingerprint, &status, &headers, &body, &bodyDigest, &held.CreatedAt, &held.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error getting idempotency key: %w", err)
+	}
+
+	if status.Valid {
+		held.Response = &domain.IdempotentResponse{Status: int(status.Int64), Body: body, BodyDigest: bodyDigest.String}
+		if err := json.Unmarshal(headers, &held.Response.Headers); err != nil {
+			return nil, false, fmt.Errorf("error decoding stored headers: %w", err)
+		}
+	}
+	return held, false, nil
+}
+
+func (r *idempotencyRepository) Complete(ctx context.Context, record *domain.IdempotencyRecord, response *domain.IdempotentResponse) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	headers, err := json.Marshal(response.Headers)
+	if err != nil {
+		return fmt.Errorf("error encoding headers: %w", err)
+	}
+
+	query := `
+	UPDATE idempotency_keys
+	SET status = $5, headers = $6, body = $7, body_digest = NULLIF($8, '')
+	WHERE user_id = $1 AND idempotency_key = $2 AND fingerprint = $3 AND created_at = $4 AND status IS NULL;`
+
+	_, err = r.db.ExecContext(ctx, query, record.UserID, record.Key, record.Fingerprint, record.CreatedAt,
+		response.Status, headers, response.Body, response.BodyDigest)
+	if err != nil {
+		return fmt.Errorf("error storing idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) Release(ctx context.Context, record *domain.IdempotencyRecord) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	DELETE FROM idempotency_keys
+	WHERE user_id = $1 AND idempotency_key = $2 AND fingerprint = $3 AND created_at = $4 AND status IS NULL;`
+
+	if _, err := r.db.ExecContext(ctx, query, record.UserID, record.Key, record.Fingerprint, record.CreatedAt); err != nil {
+		return fmt.Errorf("error releasing idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (r *idempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM idempotency_keys WHERE expires_at < CURRENT_TIMESTAMP;`
+	result, err := r.db.ExecContext(ctx, query)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired idempotency keys: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error checking deleted rows: %w", err)
+	}
+	return rows, nil
+}
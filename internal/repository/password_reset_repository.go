@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+)
+
+type passwordResetRepository struct {
+	db *database.DB
+}
+
+func NewPasswordResetRepository(db *database.DB) domain.PasswordResetRepository {
+	return &passwordResetRepository{db: db}
+}
+
+func (r *passwordResetRepository) Create(ctx context.Context, token *domain.PasswordResetToken) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO password_reset_tokens (user_id, token_hash, expires_at)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at;`
+
+	err := r.db.QueryRowContext(ctx, query, token.UserID, token.TokenHash, token.ExpiresAt).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating password reset token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *passwordResetRepository) GetByHash(ctx context.Context, hash string) (*domain.PasswordResetToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	token := &domain.PasswordResetToken{}
+	query := `
+	SELECT id, user_id, token_hash, expires_at, used_at, created_at
+	FROM password_reset_tokens
+	WHERE token_hash = $1;`
+
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(
+		&token.ID, &token.UserID, &token.TokenHash, &token.ExpiresAt, &token.UsedAt, &token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting password reset token: %w", err)
+	}
+
+	return token, nil
+}
+
+func (r *passwordResetRepository) ResetPassword(ctx context.Context, tokenID, userID int, passwordHash string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	use := `
+	UPDATE password_reset_tokens
+	SET used_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND used_at IS NULL;`
+
+	result, err := tx.ExecContext(ctx, use, tokenID)
+	if err != nil {
+		return fmt.Errorf("error using password reset token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking used rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrResetTokenUsed
+	}
+
+	// Other outstanding links of the user must not work after a reset
+	invalidate := `
+	UPDATE password_reset_tokens
+	SET used_at = CURRENT_TIMESTAMP
+	WHERE user_id = $1 AND used_at IS NULL;`
+
+	if _, err = tx.ExecContext(ctx, invalidate, userID); err != nil {
+		return fmt.Errorf("error invalidating password reset tokens: %w", err)
+	}
+
+	update := `
+	UPDATE users
+	SET password_hash = $1, updated_at = CURRENT_TIMESTAMP, version = version + 1
+	WHERE id = $2;`
+
+	if _, err = tx.ExecContext(ctx, update, passwordHash, userID); err != nil {
+		return fmt.Errorf("error updating password: %w", err)
+	}
+
+	revoke := `
+	UPDATE refresh_tokens
+	SET revoked_at = CURRENT_TIMESTAMP
+	WHERE user_id = $1 AND revoked_at IS NULL;`
+
+	if _, err = tx.ExecContext(ctx, revoke, userID); err != nil {
+		return fmt.Errorf("error revoking refresh tokens: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
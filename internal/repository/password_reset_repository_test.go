@@ -0,0 +1,44 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResetPassword(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewPasswordResetRepository(&database.DB{DB: db})
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE id = \$1`).
+		WithArgs(3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE password_reset_tokens SET used_at = CURRENT_TIMESTAMP WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	// ETags of the user change with the password
+	mock.ExpectExec(`UPDATE users SET password_hash = \$1, updated_at = CURRENT_TIMESTAMP, version = version \+ 1 WHERE id = \$2`).
+		WithArgs("hash", 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = \$1`).
+		WithArgs(1).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	err = repo.ResetPassword(context.Background(), 3, 1, "hash")
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/lib/pq"
+)
+
+type presenceRepository struct {
+	db *database.DB
+}
+
+func NewPresenceRepository(db *database.DB) domain.PresenceRepository {
+	return &presenceRepository{db: db}
+}
+
+func (r *presenceRepository) Create(ctx context.Context, conn *domain.PresenceConnection) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO presence_connections (id, user_id, status, last_seen_at)
+	VALUES ($1, $2, $3, $4)
+	RETURNING connected_at;`
+
+	err := r.db.QueryRowContext(ctx, query, conn.ID, conn.UserID, conn.Status, conn.LastSeenAt).Scan(&conn.ConnectedAt)
+	if err != nil {
+		return fmt.Errorf("error creating presence connection: %w", err)
+	}
+	return nil
+}
+
+func (r *presenceRepository) Heartbeat(ctx context.Context, conn *domain.PresenceConnection) (*time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Every part of the statement sees the same snapshot, so previous holds
+	// the heartbeat from before the upsert
+	query := `
+	WITH previous AS (
+		SELECT last_seen_at FROM presence_connections WHERE id = $1
+	)
+	INSERT INTO presence_connections (id, user_id, status, last_seen_at)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (id) DO UPDATE SET last_seen_at = EXCLUDED.last_seen_at
+	RETURNING (SELECT last_seen_at FROM previous);`
+
+	var previous *time.Time
+	err := r.db.QueryRowContext(ctx, query, conn.ID, conn.UserID, conn.Status, conn.LastSeenAt).Scan(&previous)
+	if err != nil {
+		return nil, fmt.Errorf("error refreshing presence connection: %w", err)
+	}
+	return previous, nil
+}
+
+func (r *presenceRepository) SetStatus(ctx context.Context, id, status string, seenAt time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	UPDATE presence_connections
+	SET status = $2, last_seen_at = $3
+	WHERE id = $1;`
+
+	if _, err := r.db.ExecContext(ctx, query, id, status, seenAt); err != nil {
+		return fmt.Errorf("error setting presence status: %w", err)
+	}
+	return nil
+}
+
+func (r *presenceRepository) Delete(ctx context.Context, id string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := r.db.ExecContext(ctx, "DELETE FROM presence_connections WHERE id = $1", id); err != nil {
+		return fmt.Errorf("error deleting presence connection: %w", err)
+	}
+	return nil
+}
+
+func (r *presenceRepository) GetStatuses(ctx context.Context, userIDs []int, since time.Time) (statuses map[int]string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// A single online device makes the user online
+	query := `
+	SELECT user_id, CASE WHEN bool_or(status = 'online') THEN 'online' ELSE 'away' END
+	FROM presence_connections
+	WHERE user_id = ANY($1) AND last_seen_at > $2
+	GROUP BY user_id;`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(userIDs), since)
+	if err != nil {
+		return nil, fmt.Errorf("error getting presence: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	statuses = make(map[int]string, len(userIDs))
+	for rows.Next() {
+		var (
+			userID int
+			status string
+		)
+		if err = rows.Scan(&userID, &status); err != nil {
+			return nil, fmt.Errorf("error scanning presence: %w", err)
+		}
+		statuses[userID] = status
+	}
+
+	return statuses, nil
+}
+
+func (r *presenceRepository) DeleteStale(ctx context.Context, before time.Time) (userIDs []int, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	DELETE FROM presence_connections
+	WHERE last_seen_at <= $1
+	RETURNING user_id;`
+
+	rows, err := r.db.QueryContext(ctx, query, before)
+	if err != nil {
+		return nil, fmt.Errorf("error deleting stale presence connections: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	userIDs = []int{}
+	for rows.Next() {
+		var userID int
+		if err = rows.Scan(&userID); err != nil {
+			return nil, fmt.Errorf("error scanning stale presence connection: %w", err)
+		}
+		userIDs = append(userIDs, userID)
+	}
+
+	return userIDs, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+)
+
+type refreshTokenRepository struct {
+	db *database.DB
+}
+
+func NewRefreshTokenRepository(db *database.DB) domain.RefreshTokenRepository {
+	return &refreshTokenRepository{db: db}
+}
+
+func (r *refreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at;`
+
+	err := r.db.QueryRowContext(ctx, query, token.UserID, token.FamilyID, token.TokenHash, token.ExpiresAt).Scan(
+		&token.ID,
+		&token.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating refresh token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *refreshTokenRepository) GetByHash(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	token := &domain.RefreshToken{}
+	query := `
+	SELECT id, user_id, family_id, token_hash, expires_at, revoked_at, created_at
+	FROM refresh_tokens
+	WHERE token_hash = $1;`
+
+	var revokedAt sql.NullTime
+	err := r.db.QueryRowContext(ctx, query, hash).Scan(
+		&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.ExpiresAt, &revokedAt, &token.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting refresh token: %w", err)
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+
+	return token, nil
+}
+
+func (r *refreshTokenRepository) Rotate(ctx context.Context, oldID int, next *domain.RefreshToken) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	insert := `
+	INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at;`
+
+	err = tx.QueryRowContext(ctx, insert, next.UserID, next.FamilyID, next.TokenHash, next.ExpiresAt).Scan(
+		&next.ID,
+		&next.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("error creating refresh token: %w", err)
+	}
+
+	// Only a token that is still active can be rotated, so two concurrent
+	// refreshes with the same token cannot both succeed
+	revoke := `
+	UPDATE refresh_tokens
+	SET revoked_at = CURRENT_TIMESTAMP, replaced_by = $1
+	WHERE id = $2 AND revoked_at IS NULL;`
+
+	result, err := tx.ExecContext(ctx, revoke, next.ID, oldID)
+	if err != nil {
+		return fmt.Errorf("error revoking refresh token: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking revoked rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrRefreshTokenUsed
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	UPDATE refresh_tokens
+	SET revoked_at = CURRENT_TIMESTAMP
+	WHERE family_id = $1 AND revoked_at IS NULL;`
+
+	if _, err := r.db.ExecContext(ctx, query, familyID); err != nil {
+		return fmt.Errorf("error revoking refresh token family: %w", err)
+	}
+	return nil
+}
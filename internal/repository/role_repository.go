@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/lib/pq"
+)
+
+const (
+	pgUniqueViolation     = "23505"
+	pgForeignKeyViolation = "23503"
+)
+
+type roleRepository struct {
+	db *database.DB
+}
+
+func NewRoleRepository(db *database.DB) domain.RoleRepository {
+	return &roleRepository{db: db}
+}
+
+func (r *roleRepository) GetUserPermissions(ctx context.Context, userID int) (permissions []string, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT DISTINCT p.name
+	FROM user_roles ur
+	JOIN role_permissions rp ON rp.role_id = ur.role_id
+	JOIN permissions p ON p.id = rp.permission_id
+	WHERE ur.user_id = $1;`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user permissions: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	permissions = []string{}
+	for rows.Next() {
+		var name string
+		if err = rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("error scanning permission: %w", err)
+		}
+		permissions = append(permissions, name)
+	}
+
+	return permissions, nil
+}
+
+func (r *roleRepository) GetAll(ctx context.Context) (roles []domain.Role, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT r.id, r.name, r.description, r.created_at,
+		COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+	FROM roles r
+	LEFT JOIN role_permissions rp ON rp.role_id = r.id
+	LEFT JOIN permissions p ON p.id = rp.permission_id
+	GROUP BY r.id
+	ORDER BY r.id;`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting roles: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	roles = []domain.Role{}
+	for rows.Next() {
+		var role domain.Role
+		err = rows.Scan(&role.ID, &role.Name, &role.Description, &role.CreatedAt, pq.Array(&role.Permissions))
+		if err != nil {
+			return nil, fmt.Errorf("error scanning role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+
+	return roles, nil
+}
+
+func (r *roleRepository) Create(ctx context.Context, role *domain.Role) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	insert := `
+	INSERT INTO roles (name, description)
+	VALUES ($1, $2)
+	RETURNING id, created_at;`
+
+	err = tx.QueryRowContext(ctx, insert, role.Name, role.Description).Scan(&role.ID, &role.CreatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return domain.ErrRoleExists
+		}
+		return fmt.Errorf("error creating role: %w", err)
+	}
+
+	grant := `
+	INSERT INTO role_permissions (role_id, permission_id)
+	SELECT $1, id FROM permissions WHERE name = ANY($2);`
+
+	result, err := tx.ExecContext(ctx, grant, role.ID, pq.Array(role.Permissions))
+	if err != nil {
+		return fmt.Errorf("error granting permissions: %w", err)
+	}
+
+	granted, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking granted permissions: %w", err)
+	}
+	if int(granted) != len(role.Permissions) {
+		return domain.ErrUnknownPermission
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *roleRepository) AssignToUser(ctx context.Context, userID int, roleName string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var roleID int
+	err := r.db.QueryRowContext(ctx, "SELECT id FROM roles WHERE name = $1;", roleName).Scan(&roleID)
+	if err == sql.ErrNoRows {
+		return domain.ErrRoleNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("error getting role: %w", err)
+	}
+
+	query := `
+	INSERT INTO user_roles (user_id, role_id)
+	VALUES ($1, $2)
+	ON CONFLICT DO NOTHING;`
+
+	if _, err := r.db.ExecContext(ctx, query, userID, roleID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgForeignKeyViolation {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("error assigning role: %w", err)
+	}
+	return nil
+}
+
+func (r *roleRepository) RemoveFromUser(ctx context.Context, userID int, roleName string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	DELETE FROM user_roles
+	WHERE user_id = $1 AND role_id = (SELECT id FROM roles WHERE name = $2);`
+
+	result, err := r.db.ExecContext(ctx, query, userID, roleName)
+	if err != nil {
+		return fmt.Errorf("error removing role: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking removed rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrRoleAssignmentNotFound
+	}
+	return nil
+}
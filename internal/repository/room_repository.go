@@ -0,0 +1,320 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/lib/pq"
+)
+
+type roomRepository struct {
+	db *database.DB
+}
+
+func NewRoomRepository(db *database.DB) domain.RoomRepository {
+	return &roomRepository{db: db}
+}
+
+const roomColumns = `
+	c.id, c.name, c.topic, c.visibility, COALESCE(c.created_by, 0), c.created_at,
+	(SELECT COUNT(*) FROM conversation_members m WHERE m.conversation_id = c.id)`
+
+func scanRoom(row interface{ Scan(...any) error }, room *domain.Room) error {
+	return row.Scan(
+		&room.ID, &room.Name, &room.Topic, &room.Visibility, &room.CreatedBy, &room.CreatedAt, &room.MemberCount,
+	)
+}
+
+func (r *roomRepository) Create(ctx context.Context, room *domain.Room) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	insert := `
+	INSERT INTO conversations (kind, name, topic, visibility, created_by)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING id, created_at;`
+
+	err = tx.QueryRowContext(ctx, insert, domain.ConversationRoom, room.Name, room.Topic, room.Visibility, room.CreatedBy).
+		Scan(&room.ID, &room.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating room: %w", err)
+	}
+
+	owner := `
+	INSERT INTO conversation_members (conversation_id, user_id, role)
+	VALUES ($1, $2, $3);`
+
+	if _, err = tx.ExecContext(ctx, owner, room.ID, room.CreatedBy, domain.RoomRoleOwner); err != nil {
+		return fmt.Errorf("error adding room owner: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	room.MemberCount = 1
+	return nil
+}
+
+func (r *roomRepository) GetByID(ctx context.Context, id int64) (*domain.Room, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	room := &domain.Room{}
+	query := `SELECT` + roomColumns + `
+	FROM conversations c
+	WHERE c.id = $1 AND c.kind = 'room';`
+
+	err := scanRoom(r.db.QueryRowContext(ctx, query, id), room)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting room: %w", err)
+	}
+
+	return room, nil
+}
+
+func (r *roomRepository) GetVisible(ctx context.Context, userID int) (rooms []domain.Room, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `SELECT` + roomColumns + `
+	FROM conversations c
+	WHERE c.kind = 'room' AND (
+		c.visibility = 'public'
+		OR EXISTS (SELECT 1 FROM conversation_members m WHERE m.conversation_id = c.id AND m.user_id = $1)
+		OR EXISTS (SELECT 1 FROM room_invites i WHERE i.conversation_id = c.id AND i.user_id = $1)
+	)
+	ORDER BY c.name, c.id;`
+
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting rooms: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	rooms = []domain.Room{}
+	for rows.Next() {
+		var room domain.Room
+		if err = scanRoom(rows, &room); err != nil {
+			return nil, fmt.Errorf("error scanning room: %w", err)
+		}
+		rooms = append(rooms, room)
+	}
+
+	return rooms, nil
+}
+
+func (r *roomRepository) AddMember(ctx context.Context, roomID int64, userID int, role string) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	insert := `
+	INSERT INTO conversation_members (conversation_id, user_id, role)
+	VALUES ($1, $2, $3);`
+
+	if _, err = tx.ExecContext(ctx, insert, roomID, userID, role); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code {
+			case pgUniqueViolation:
+				return domain.ErrAlreadyRoomMember
+			case pgForeignKeyViolation:
+				return domain.ErrUserNotFound
+			}
+		}
+		return fmt.Errorf("error adding room member: %w", err)
+	}
+
+	invite := `DELETE FROM room_invites WHERE conversation_id = $1 AND user_id = $2;`
+	if _, err = tx.ExecContext(ctx, invite, roomID, userID); err != nil {
+		return fmt.Errorf("error deleting room invite: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *roomRepository) RemoveMember(ctx context.Context, roomID int64, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2;`
+	result, err := r.db.ExecContext(ctx, query, roomID, userID)
+	if err != nil {
+		return fmt.Errorf("error removing room member: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking removed rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrRoomMemberNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) SetMemberRole(ctx context.Context, roomID int64, userID int, role string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	UPDATE conversation_members
+	SET role = $3
+	WHERE conversation_id = $1 AND user_id = $2;`
+
+	result, err := r.db.ExecContext(ctx, query, roomID, userID, role)
+	if err != nil {
+		return fmt.Errorf("error setting room member role: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking updated rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrRoomMemberNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) CreateInvite(ctx context.Context, roomID int64, userID, invitedBy int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO room_invites (conversation_id, user_id, invited_by)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (conversation_id, user_id) DO UPDATE
+	SET invited_by = EXCLUDED.invited_by, created_at = CURRENT_TIMESTAMP;`
+
+	if _, err := r.db.ExecContext(ctx, query, roomID, userID, invitedBy); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgForeignKeyViolation {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("error creating room invite: %w", err)
+	}
+	return nil
+}
+
+func (r *roomRepository) HasInvite(ctx context.Context, roomID int64, userID int) (bool, error) {
+	return r.exists(ctx, `SELECT EXISTS (SELECT 1 FROM room_invites WHERE conversation_id = $1 AND user_id = $2);`, roomID, userID)
+}
+
+func (r *roomRepository) Ban(ctx context.Context, roomID int64, userID, bannedBy int) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	ban := `
+	INSERT INTO room_bans (conversation_id, user_id, banned_by)
+	VALUES ($1, $2, $3)
+	ON CONFLICT (conversation_id, user_id) DO NOTHING;`
+
+	if _, err = tx.ExecContext(ctx, ban, roomID, userID, bannedBy); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgForeignKeyViolation {
+			return domain.ErrUserNotFound
+		}
+		return fmt.Errorf("error banning room member: %w", err)
+	}
+
+	for _, query := range []string{
+		`DELETE FROM conversation_members WHERE conversation_id = $1 AND user_id = $2;`,
+		`DELETE FROM room_invites WHERE conversation_id = $1 AND user_id = $2;`,
+	} {
+		if _, err = tx.ExecContext(ctx, query, roomID, userID); err != nil {
+			return fmt.Errorf("error removing banned user: %w", err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
+
+func (r *roomRepository) Unban(ctx context.Context, roomID int64, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM room_bans WHERE conversation_id = $1 AND user_id = $2;`
+	result, err := r.db.ExecContext(ctx, query, roomID, userID)
+	if err != nil {
+		return fmt.Errorf("error removing room ban: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking removed rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrRoomBanNotFound
+	}
+	return nil
+}
+
+func (r *roomRepository) IsBanned(ctx context.Context, roomID int64, userID int) (bool, error) {
+	return r.exists(ctx, `SELECT EXISTS (SELECT 1 FROM room_bans WHERE conversation_id = $1 AND user_id = $2);`, roomID, userID)
+}
+
+func (r *roomRepository) exists(ctx context.Context, query string, roomID int64, userID int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	var exists bool
+	if err := r.db.QueryRowContext(ctx, query, roomID, userID).Scan(&exists); err != nil {
+		return false, fmt.Errorf("error checking room state: %w", err)
+	}
+	return exists, nil
+}
@@ -0,0 +1,150 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+)
+
+// importInterrupted is the error of jobs whose instance stopped running them
+const importInterrupted = "import was interrupted, run it again"
+
+type userImportRepository struct {
+	db *database.DB
+}
+
+func NewUserImportRepository(db *database.DB) domain.UserImportRepository {
+	return &userImportRepository{db: db}
+}
+
+func (r *userImportRepository) Create(ctx context.Context, job *domain.UserImportJob) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	INSERT INTO user_import_jobs (user_id, format, dry_run, status)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at, updated_at;`
+
+	err := r.db.QueryRowContext(ctx, query, job.UserID, job.Format, job.DryRun, job.Status).
+		Scan(&job.ID, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("error creating import job: %w", err)
+	}
+	return nil
+}
+
+func (r *userImportRepository) GetByID(ctx context.Context, id int) (*domain.UserImportJob, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	query := `
+	SELECT id, user_id, format, dry_run, status, processed, result, COALESCE(error, ''),
+		created_at, updated_at, finished_at
+	FROM user_import_jobs
+	WHERE id = $1;`
+
+	job := &domain.UserImportJob{}
+	var result []byte
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&job.ID,
+		&job.UserID,
+		&job.Format,
+		&job.DryRun,
+		&job.Status,
+		&job.Processed,
+		&result,
+		&job.Error,
+		&job.CreatedAt,
+		&job.UpdatedAt,
+		&job.FinishedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting import job: %w", err)
+	}
+
+	if result != nil {
+		job.Result = &domain.UserImportResult{}
+		if err := json.Unmarshal(result, job.Result); err != nil {
+			return nil, fmt.Errorf("error decoding import result: %w", err)
+		}
+	}
+	return job, nil
+}
+
+func (r *userImportRepository) Update(ctx context.Context, job *domain.UserImportJob) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	// Jobs without a result store NULL
+	var result any
+	if job.Result != nil {
+		encoded, err := json.Marshal(job.Result)
+		if err != nil {
+			return fmt.Errorf("error encoding import result: %w", err)
+		}
+		result = encoded
+	}
+
+	query := `
+	UPDATE user_import_jobs
+	SET status = $2, processed = $3, result = $4, error = NULLIF($5, ''),
+		updated_at = CURRENT_TIMESTAMP,
+		finished_at = CASE WHEN $6 THEN CURRENT_TIMESTAMP END
+	WHERE id = $1
+	RETURNING updated_at, finished_at;`
+
+	finished := job.Status != domain.ImportJobRunning
+	err := r.db.QueryRowContext(ctx, query, job.ID, job.Status, job.Processed, result, job.Error, finished).
+		Scan(&job.UpdatedAt, &job.FinishedAt)
+	if err != nil {
+		return fmt.Errorf("error updating import job: %w", err)
+	}
+	return nil
+}
+
+func (r *userImportRepository) FailStale(ctx context.Context, staleAfter time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `
+	UPDATE user_import_jobs
+	SET status = $1, error = $2, updated_at = CURRENT_TIMESTAMP, finished_at = CURRENT_TIMESTAMP
+	WHERE finished_at IS NULL AND updated_at < CURRENT_TIMESTAMP - make_interval(secs => $3);`
+
+	result, err := r.db.ExecContext(ctx, query, domain.ImportJobFailed, importInterrupted, staleAfter.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("error failing stale import jobs: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error checking failed jobs: %w", err)
+	}
+	return rows, nil
+}
+
+func (r *userImportRepository) DeleteFinished(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	query := `DELETE FROM user_import_jobs WHERE finished_at < $1;`
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting import jobs: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error checking deleted jobs: %w", err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,171 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+)
+
+const userColumns = "id, email, name, verified_at, created_at, updated_at, version, deleted_at"
+
+// userSortColumns maps sort fields to columns, fields missing here never
+// reach the SQL
+var userSortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"email":      "email",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns, backslash is the
+// default escape character in Postgres
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// Postgres wraps matches in these markers instead of HTML tags, the text
+// around them still has to be escaped
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+// headlineOptions highlights every match in the name and email, they are
+// short enough to be returned whole
+const headlineOptions = "StartSel=" + headlineStart + ", StopSel=" + headlineStop + ", HighlightAll=true"
+
+// highlight turns a headline into escaped HTML with matches wrapped in <mark>
+func highlight(headline string) string {
+	escaped := html.EscapeString(headline)
+	return strings.NewReplacer(headlineStart, "<mark>", headlineStop, "</mark>").Replace(escaped)
+}
+
+// userQueryBuilder collects the clauses of a user listing. Clauses are fixed
+// SQL fragments and columns from userSortColumns, every value coming from a
+// request is passed as an argument.
+type userQueryBuilder struct {
+	conditions []string
+	args       []any
+}
+
+// arg adds an argument and returns its placeholder
+func (b *userQueryBuilder) arg(value any) string {
+	b.args = append(b.args, value)
+	return "$" + strconv.Itoa(len(b.args))
+}
+
+func (b *userQueryBuilder) filter(f *domain.UserFilter) {
+	if !f.IncludeDeleted {
+		b.conditions = append(b.conditions, "deleted_at IS NULL")
+	}
+	if f.EmailDomain != "" {
+		b.conditions = append(b.conditions, "split_part(email, '@', 2) = "+b.arg(strings.ToLower(f.EmailDomain)))
+	}
+	if f.CreatedAfter != nil {
+		b.conditions = append(b.conditions, "created_at > "+b.arg(*f.CreatedAfter))
+	}
+	if f.CreatedBefore != nil {
+		b.conditions = append(b.conditions, "created_at < "+b.arg(*f.CreatedBefore))
+	}
+	if f.Verified != nil {
+		if *f.Verified {
+			b.conditions = append(b.conditions, "verified_at IS NOT NULL")
+		} else {
+			b.conditions = append(b.conditions, "verified_at IS NULL")
+		}
+	}
+	if f.Query != "" {
+		pattern := b.arg("%" + likeEscaper.Replace(f.Query) + "%")
+		b.conditions = append(b.conditions, "(name ILIKE "+pattern+" OR email ILIKE "+pattern+")")
+	}
+}
+
+// after keeps the users that come after the position in sort order, or
+// before it when backward is set. With sort a, b it is
+// (a > $1) OR (a = $1 AND b > $2), flipped for descending fields.
+func (b *userQueryBuilder) after(sort []domain.SortField, position []string, backward bool) error {
+	if len(position) != len(sort) {
+		return fmt.Errorf("position has %d values for %d sort fields", len(position), len(sort))
+	}
+
+	columns, err := sortColumns(sort)
+	if err != nil {
+		return err
+	}
+	placeholders := make([]string, len(position))
+	for i, value := range position {
+		placeholders[i] = b.arg(value)
+	}
+
+	alternatives := make([]string, 0, len(sort))
+	for i, field := range sort {
+		parts := make([]string, 0, i+1)
+		for j := range i {
+			parts = append(parts, columns[j]+" = "+placeholders[j])
+		}
+		op := " > "
+		if field.Desc != backward {
+			op = " < "
+		}
+		parts = append(parts, columns[i]+op+placeholders[i])
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+
+	b.conditions = append(b.conditions, "("+strings.Join(alternatives, " OR ")+")")
+	return nil
+}
+
+func (b *userQueryBuilder) where() string {
+	if len(b.conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(b.conditions, " AND ")
+}
+
+// orderBy sorts by sort, reversed when backward is set
+func orderBy(sort []domain.SortField, backward bool) (string, error) {
+	columns, err := sortColumns(sort)
+	if err != nil {
+		return "", err
+	}
+
+	terms := make([]string, len(sort))
+	for i, field := range sort {
+		terms[i] = columns[i] + " ASC"
+		if field.Desc != backward {
+			terms[i] = columns[i] + " DESC"
+		}
+	}
+	return " ORDER BY " + strings.Join(terms, ", "), nil
+}
+
+func sortColumns(sort []domain.SortField) ([]string, error) {
+	columns := make([]string, len(sort))
+	for i, field := range sort {
+		column, ok := userSortColumns[field.Field]
+		if !ok {
+			return nil, fmt.Errorf("unknown sort field %q", field.Field)
+		}
+		columns[i] = column
+	}
+	return columns, nil
+}
+
+func scanUsers(rows *sql.Rows) ([]domain.User, error) {
+	users := []domain.User{}
+	for rows.Next() {
+		var user domain.User
+		err := rows.Scan(&user.ID, &user.Email, &user.Name, &user.VerifiedAt, &user.CreatedAt, &user.UpdatedAt, &user.Version, &user.DeletedAt)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating users: %w", err)
+	}
+	return users, nil
+}
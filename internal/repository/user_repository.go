@@ -3,11 +3,14 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"slices"
 	"time"
 
 	"github.com/DMaryanskiy/go-idk/internal/domain"
 	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/lib/pq"
 )
 
 type userRepository struct {
@@ -22,36 +25,137 @@ func (r *userRepository) Create(ctx context.Context, user *domain.User) error {
 	ctx, cancel := context.WithTimeout(ctx, 5 * time.Second)
 	defer cancel()
 
+	// Every user gets the default role in the same statement
 	query := `
-	INSERT INTO users (email, name)
-	VALUES ($1, $2)
-	RETURNING id, created_at, updated_at`
+	WITH inserted AS (
+		INSERT INTO users (email, name, password_hash)
+		VALUES ($1, $2, NULLIF($3, ''))
+		RETURNING id, created_at, updated_at, version
+	), default_role AS (
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT inserted.id, roles.id FROM inserted, roles WHERE roles.name = $4
+	)
+	SELECT id, created_at, updated_at, version FROM inserted;`
 
-	err := r.db.QueryRowContext(ctx, query, user.Email, user.Name).Scan(
+	err := r.db.QueryRowContext(ctx, query, user.Email, user.Name, user.PasswordHash, domain.RoleUser).Scan(
 		&user.ID,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Version,
 	)
 
 	if err != nil {
+		// Another request took the email after the caller checked it
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return domain.ErrEmailInUse
+		}
 		return fmt.Errorf("error creating user: %w", err)
 	}
 
 	return nil
 }
 
+func (r *userRepository) CreateBatch(ctx context.Context, users []*domain.User) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 30 * time.Second)
+	defer cancel()
+
+	emails := make([]string, len(users))
+	names := make([]string, len(users))
+	byEmail := make(map[string]*domain.User, len(users))
+	for i, user := range users {
+		emails[i] = user.Email
+		names[i] = user.Name
+		byEmail[user.Email] = user
+	}
+
+	// Taken emails are skipped instead of failing the batch, the users
+	// created get the default role like in Create
+	query := `
+	WITH inserted AS (
+		INSERT INTO users (email, name)
+		SELECT * FROM unnest($1::text[], $2::text[])
+		ON CONFLICT (email) WHERE deleted_at IS NULL DO NOTHING
+		RETURNING id, email, created_at, updated_at, version
+	), default_role AS (
+		INSERT INTO user_roles (user_id, role_id)
+		SELECT inserted.id, roles.id FROM inserted, roles WHERE roles.name = $3
+	)
+	SELECT id, email, created_at, updated_at, version FROM inserted;`
+
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(emails), pq.Array(names), domain.RoleUser)
+	if err != nil {
+		return fmt.Errorf("error creating users: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	for rows.Next() {
+		var created domain.User
+		if err := rows.Scan(&created.ID, &created.Email, &created.CreatedAt, &created.UpdatedAt, &created.Version); err != nil {
+			return fmt.Errorf("error scanning created user: %w", err)
+		}
+		if user, ok := byEmail[created.Email]; ok {
+			user.ID = created.ID
+			user.CreatedAt = created.CreatedAt
+			user.UpdatedAt = created.UpdatedAt
+			user.Version = created.Version
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating created users: %w", err)
+	}
+
+	return nil
+}
+
+func (r *userRepository) ExistingEmails(ctx context.Context, emails []string) (existing map[string]bool, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5 * time.Second)
+	defer cancel()
+
+	query := `SELECT email FROM users WHERE email = ANY($1) AND deleted_at IS NULL;`
+	rows, err := r.db.QueryContext(ctx, query, pq.Array(emails))
+	if err != nil {
+		return nil, fmt.Errorf("error getting existing emails: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	existing = make(map[string]bool)
+	for rows.Next() {
+		var email string
+		if err := rows.Scan(&email); err != nil {
+			return nil, fmt.Errorf("error scanning email: %w", err)
+		}
+		existing[email] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating emails: %w", err)
+	}
+
+	return existing, nil
+}
+
 func (r *userRepository) GetByID(ctx context.Context, id int) (*domain.User, error) {
 	ctx, cancel := context.WithTimeout(ctx, 5 * time.Second)
 	defer cancel()
 
 	user := &domain.User{}
 	query := `
-	SELECT id, email, name, created_at, updated_at
+	SELECT id, email, name, verified_at, created_at, updated_at, version
 	FROM users
-	WHERE id = $1;`
+	WHERE id = $1 AND deleted_at IS NULL;`
 
 	err := r.db.QueryRowContext(ctx, query, id).Scan(
-		&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &user.Name, &user.VerifiedAt, &user.CreatedAt, &user.UpdatedAt, &user.Version,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -69,12 +173,12 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.
 
 	user := &domain.User{}
 	query := `
-	SELECT id, email, name, created_at, updated_at
+	SELECT id, email, name, COALESCE(password_hash, ''), verified_at, created_at, updated_at, version
 	FROM users
-	WHERE email = $1;`
+	WHERE email = $1 AND deleted_at IS NULL;`
 
 	err := r.db.QueryRowContext(ctx, query, email).Scan(
-		&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt,
+		&user.ID, &user.Email, &user.Name, &user.PasswordHash, &user.VerifiedAt, &user.CreatedAt, &user.UpdatedAt, &user.Version,
 	)
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -86,19 +190,27 @@ func (r *userRepository) GetByEmail(ctx context.Context, email string) (*domain.
 	return user, nil
 }
 
-func (r *userRepository) GetAll(ctx context.Context, limit, offset int) (users []domain.User, total int, err error) {
+func (r *userRepository) GetAll(ctx context.Context, query *domain.UserQuery, limit, offset int) (users []domain.User, total int, err error) {
 	ctx, cancel := context.WithTimeout(ctx, 10 * time.Second)
 	defer cancel()
 
-	countQuery := "SELECT COUNT(*) FROM users;"
+	builder := &userQueryBuilder{}
+	builder.filter(&query.Filter)
+	order, err := orderBy(query.Sort, false)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	countQuery := "SELECT COUNT(*) FROM users" + builder.where()
 
-	err = r.db.QueryRowContext(ctx, countQuery).Scan(&total)
+	err = r.db.QueryRowContext(ctx, countQuery, builder.args...).Scan(&total)
 	if err != nil {
 		return nil, 0, fmt.Errorf("error counting users: %w", err)
 	}
 
-	query := "SELECT id, email, name, created_at, updated_at FROM users ORDER BY id LIMIT $1 OFFSET $2;"
-	rows, err := r.db.QueryContext(ctx, query, limit, offset)
+	pagination := " LIMIT " + builder.arg(limit) + " OFFSET " + builder.arg(offset)
+	sqlQuery := "SELECT " + userColumns + " FROM users" + builder.where() + order + pagination
+	rows, err := r.db.QueryContext(ctx, sqlQuery, builder.args...)
 	if err != nil {
 		return nil, 0, fmt.Errorf("error getting users: %w", err)
 	}
@@ -109,17 +221,111 @@ func (r *userRepository) GetAll(ctx context.Context, limit, offset int) (users [
 		}
 	}()
 
-	users = []domain.User{}
+	users, err = scanUsers(rows)
+	if err != nil {
+		return nil, 0, err
+	}
+	return users, total, nil
+}
+
+func (r *userRepository) GetPage(ctx context.Context, query *domain.UserPageQuery) (users []domain.User, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5 * time.Second)
+	defer cancel()
+
+	builder := &userQueryBuilder{}
+	builder.filter(&query.Filter)
+	if query.After != nil {
+		if err := builder.after(query.Sort, query.After, query.Backward); err != nil {
+			return nil, err
+		}
+	}
+	// Pages before a cursor are read backward from it and reversed below
+	order, err := orderBy(query.Sort, query.Backward)
+	if err != nil {
+		return nil, err
+	}
+
+	sqlQuery := "SELECT " + userColumns + " FROM users" + builder.where() + order + " LIMIT " + builder.arg(query.Limit)
+	rows, err := r.db.QueryContext(ctx, sqlQuery, builder.args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting users: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	users, err = scanUsers(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Backward {
+		slices.Reverse(users)
+	}
+	return users, nil
+}
+
+func (r *userRepository) Count(ctx context.Context, filter *domain.UserFilter) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, 10 * time.Second)
+	defer cancel()
+
+	builder := &userQueryBuilder{}
+	builder.filter(filter)
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users"+builder.where(), builder.args...).Scan(&total); err != nil {
+		return 0, fmt.Errorf("error counting users: %w", err)
+	}
+	return total, nil
+}
+
+func (r *userRepository) Search(ctx context.Context, query string, limit, offset int) (results []domain.UserSearchResult, err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5 * time.Second)
+	defer cancel()
+
+	// ts_rank_cd favours results where the words are close together, names
+	// weigh more than emails through the weights of search_vector
+	sqlQuery := `
+	SELECT id, email, name, verified_at, created_at, updated_at, version,
+		ts_rank_cd(search_vector, query) AS rank,
+		ts_headline('simple', name || ' ' || email, query, $4)
+	FROM users, websearch_to_tsquery('simple', $1) AS query
+	WHERE search_vector @@ query AND deleted_at IS NULL
+	ORDER BY rank DESC, id
+	LIMIT $2 OFFSET $3;`
+
+	rows, err := r.db.QueryContext(ctx, sqlQuery, query, limit, offset, headlineOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error searching users: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	results = []domain.UserSearchResult{}
 	for rows.Next() {
-		var user domain.User
-		err = rows.Scan(&user.ID, &user.Email, &user.Name, &user.CreatedAt, &user.UpdatedAt)
+		var result domain.UserSearchResult
+		var headline string
+		err := rows.Scan(
+			&result.ID, &result.Email, &result.Name, &result.VerifiedAt, &result.CreatedAt, &result.UpdatedAt, &result.Version,
+			&result.Rank, &headline,
+		)
 		if err != nil {
-			return nil, 0, fmt.Errorf("error scanning user: %w", err)
+			return nil, fmt.Errorf("error scanning search result: %w", err)
 		}
-		users = append(users, user)
+		result.Highlight = highlight(headline)
+		results = append(results, result)
 	}
-
-	return users, total, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating search results: %w", err)
+	}
+	return results, nil
 }
 
 func (r *userRepository) Update(ctx context.Context, id int, user *domain.User) error {
@@ -128,26 +334,49 @@ func (r *userRepository) Update(ctx context.Context, id int, user *domain.User)
 
 	query := `
 	UPDATE users
-	SET email = $1, name = $2, updated_at = CURRENT_TIMESTAMP
-	WHERE id = $3
-	RETURNING updated_at;`
+	SET email = $1, name = $2, updated_at = CURRENT_TIMESTAMP, version = version + 1
+	WHERE id = $3 AND version = $4 AND deleted_at IS NULL
+	RETURNING updated_at, version;`
 
-	err := r.db.QueryRowContext(ctx, query, user.Email, user.Name, id).Scan(&user.UpdatedAt)
+	err := r.db.QueryRowContext(ctx, query, user.Email, user.Name, id, user.Version).Scan(&user.UpdatedAt, &user.Version)
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("user not found")
+		return r.updateMissed(ctx, id)
 	}
 	if err != nil {
+		// Another request took the email after the caller checked it
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return domain.ErrEmailInUse
+		}
 		return fmt.Errorf("error updating user: %w", err)
 	}
 	user.ID = id
 	return nil
 }
 
+// updateMissed tells why an update matched no row: the user is gone or its
+// version moved on
+func (r *userRepository) updateMissed(ctx context.Context, id int) error {
+	var exists bool
+	query := `SELECT EXISTS (SELECT 1 FROM users WHERE id = $1 AND deleted_at IS NULL);`
+	if err := r.db.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return fmt.Errorf("error checking user existence: %w", err)
+	}
+	if !exists {
+		return domain.ErrUserNotFound
+	}
+	return domain.ErrUserVersionMismatch
+}
+
 func (r *userRepository) Delete(ctx context.Context, id int) error {
 	ctx, cancel := context.WithTimeout(ctx, 5 * time.Second)
 	defer cancel()
 
-	query := `DELETE FROM users WHERE id = $1;`
+	query := `
+	UPDATE users
+	SET deleted_at = CURRENT_TIMESTAMP, version = version + 1
+	WHERE id = $1 AND deleted_at IS NULL;`
+
 	result, err := r.db.ExecContext(ctx, query, id)
 	if err != nil {
 		return fmt.Errorf("error deleting user: %w", err)
@@ -158,7 +387,55 @@ func (r *userRepository) Delete(ctx context.Context, id int) error {
 		return fmt.Errorf("error checking deleted rows: %w", err)
 	}
 	if rows == 0 {
-		return fmt.Errorf("user not found")
+		return domain.ErrUserNotFound
 	}
 	return nil
 }
+
+func (r *userRepository) Restore(ctx context.Context, id int) (*domain.User, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5 * time.Second)
+	defer cancel()
+
+	user := &domain.User{}
+	query := `
+	UPDATE users
+	SET deleted_at = NULL, updated_at = CURRENT_TIMESTAMP, version = version + 1
+	WHERE id = $1 AND deleted_at IS NOT NULL
+	RETURNING id, email, name, verified_at, created_at, updated_at, version;`
+
+	err := r.db.QueryRowContext(ctx, query, id).Scan(
+		&user.ID, &user.Email, &user.Name, &user.VerifiedAt, &user.CreatedAt, &user.UpdatedAt, &user.Version,
+	)
+	if err == sql.ErrNoRows {
+		return nil, domain.ErrUserNotFound
+	}
+	if err != nil {
+		// The email was taken by an active user after the deletion
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == pgUniqueViolation {
+			return nil, domain.ErrEmailInUse
+		}
+		return nil, fmt.Errorf("error restoring user: %w", err)
+	}
+	return user, nil
+}
+
+func (r *userRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := context.WithTimeout(ctx, 30 * time.Second)
+	defer cancel()
+
+	// Rows owned by the users, like their tokens, memberships and reactions,
+	// are removed by their foreign keys. Messages they sent stay in the
+	// conversations and rooms with the sender unlinked.
+	query := `DELETE FROM users WHERE deleted_at < $1;`
+	result, err := r.db.ExecContext(ctx, query, before)
+	if err != nil {
+		return 0, fmt.Errorf("error purging users: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error checking purged rows: %w", err)
+	}
+	return rows, nil
+}
@@ -2,12 +2,14 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/DMaryanskiy/go-idk/internal/domain"
 	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -28,11 +30,11 @@ func TestCreateUser(t *testing.T) {
 		Name:  "Test User",
 	}
 
-	rows := sqlmock.NewRows([]string{"id", "created_at", "updated_at"}).
-		AddRow(1, time.Now(), time.Now())
+	rows := sqlmock.NewRows([]string{"id", "created_at", "updated_at", "version"}).
+		AddRow(1, time.Now(), time.Now(), 1)
 
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs(user.Email, user.Name).
+		WithArgs(user.Email, user.Name, "", domain.RoleUser).
 		WillReturnRows(rows)
 
 	ctx := context.Background()
@@ -43,6 +45,87 @@ func TestCreateUser(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestCreateUser_EmailTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	// A parallel request created a user with the email first
+	mock.ExpectQuery("INSERT INTO users").
+		WillReturnError(&pq.Error{Code: pgUniqueViolation})
+
+	err = repo.Create(context.Background(), &domain.User{Email: "test@example.com", Name: "Test User"})
+
+	assert.ErrorIs(t, err, domain.ErrEmailInUse)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUser_EmailTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	mock.ExpectQuery(`UPDATE users SET email = \$1`).
+		WillReturnError(&pq.Error{Code: pgUniqueViolation})
+
+	err = repo.Update(context.Background(), 1, &domain.User{Email: "taken@example.com", Name: "Test User", Version: 1})
+
+	assert.ErrorIs(t, err, domain.ErrEmailInUse)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateUsersBatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	users := []*domain.User{
+		{Email: "ann@example.com", Name: "Ann"},
+		{Email: "taken@example.com", Name: "Bob"},
+	}
+
+	// Only the user whose email was free comes back
+	rows := sqlmock.NewRows([]string{"id", "email", "created_at", "updated_at", "version"}).
+		AddRow(5, "ann@example.com", time.Now(), time.Now(), 1)
+
+	mock.ExpectQuery(`INSERT INTO users (.+) unnest(.+) ON CONFLICT \(email\) WHERE deleted_at IS NULL DO NOTHING`).
+		WithArgs(
+			pq.Array([]string{"ann@example.com", "taken@example.com"}),
+			pq.Array([]string{"Ann", "Bob"}),
+			domain.RoleUser,
+		).
+		WillReturnRows(rows)
+
+	ctx := context.Background()
+	err = repo.CreateBatch(ctx, users)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, users[0].ID)
+	assert.Zero(t, users[1].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestGetUserByID(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -56,8 +139,8 @@ func TestGetUserByID(t *testing.T) {
 	repo := NewUserRepository(&database.DB{DB: db})
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "email", "name", "created_at", "updated_at"}).
-		AddRow(1, "test@example.com", "Test User", now, now)
+	rows := sqlmock.NewRows([]string{"id", "email", "name", "verified_at", "created_at", "updated_at", "version"}).
+		AddRow(1, "test@example.com", "Test User", nil, now, now, 3)
 
 	mock.ExpectQuery("SELECT (.+) FROM users WHERE id").
 		WithArgs(1).
@@ -69,6 +152,7 @@ func TestGetUserByID(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	assert.Equal(t, "test@example.com", user.Email)
+	assert.Equal(t, 3, user.Version)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -86,7 +170,7 @@ func TestGetUserByID_NotFound(t *testing.T) {
 
 	mock.ExpectQuery("SELECT (.+) FROM users WHERE id").
 		WithArgs(999).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name", "created_at", "updated_at"}))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name", "verified_at", "created_at", "updated_at", "version"}))
 
 	ctx := context.Background()
 	user, err := repo.GetByID(ctx, 999)
@@ -109,8 +193,8 @@ func TestGetUserByEmail(t *testing.T) {
 	repo := NewUserRepository(&database.DB{DB: db})
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"id", "email", "name", "created_at", "updated_at"}).
-		AddRow(1, "test@example.com", "Test User", now, now)
+	rows := sqlmock.NewRows([]string{"id", "email", "name", "password_hash", "verified_at", "created_at", "updated_at", "version"}).
+		AddRow(1, "test@example.com", "Test User", "hash", now, now, now, 1)
 
 	mock.ExpectQuery("SELECT (.+) FROM users WHERE email").
 		WithArgs("test@example.com").
@@ -122,6 +206,8 @@ func TestGetUserByEmail(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotNil(t, user)
 	assert.Equal(t, "test@example.com", user.Email)
+	assert.Equal(t, "hash", user.PasswordHash)
+	assert.NotNil(t, user.VerifiedAt)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -144,16 +230,16 @@ func TestGetAllUsers(t *testing.T) {
 
 	// Mock select query
 	now := time.Now()
-	userRows := sqlmock.NewRows([]string{"id", "email", "name", "created_at", "updated_at"}).
-		AddRow(1, "test1@example.com", "Test User 1", now, now).
-		AddRow(2, "test2@example.com", "Test User 2", now, now)
+	userRows := sqlmock.NewRows([]string{"id", "email", "name", "verified_at", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(1, "test1@example.com", "Test User 1", now, now, now, 1, nil).
+		AddRow(2, "test2@example.com", "Test User 2", nil, now, now, 1, nil)
 
-	mock.ExpectQuery("SELECT (.+) FROM users ORDER BY id LIMIT").
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE deleted_at IS NULL ORDER BY id ASC LIMIT").
 		WithArgs(10, 0).
 		WillReturnRows(userRows)
 
 	ctx := context.Background()
-	users, total, err := repo.GetAll(ctx, 10, 0)
+	users, total, err := repo.GetAll(ctx, &domain.UserQuery{Sort: []domain.SortField{{Field: "id"}}}, 10, 0)
 
 	assert.NoError(t, err)
 	assert.Equal(t, 2, total)
@@ -162,6 +248,38 @@ func TestGetAllUsers(t *testing.T) {
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
+func TestGetAllUsers_RowError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	mock.ExpectQuery("SELECT COUNT").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(2))
+
+	// The connection breaks after the first user
+	now := time.Now()
+	userRows := sqlmock.NewRows([]string{"id", "email", "name", "verified_at", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(1, "test1@example.com", "Test User 1", now, now, now, 1, nil).
+		AddRow(2, "test2@example.com", "Test User 2", nil, now, now, 1, nil).
+		RowError(1, errors.New("connection reset"))
+	mock.ExpectQuery("SELECT (.+) FROM users WHERE deleted_at IS NULL ORDER BY id ASC LIMIT").
+		WithArgs(10, 0).
+		WillReturnRows(userRows)
+
+	users, _, err := repo.GetAll(context.Background(), &domain.UserQuery{Sort: []domain.SortField{{Field: "id"}}}, 10, 0)
+
+	assert.ErrorContains(t, err, "connection reset")
+	assert.Nil(t, users)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
 func TestUpdateUser(t *testing.T) {
 	db, mock, err := sqlmock.New()
 	assert.NoError(t, err)
@@ -175,15 +293,16 @@ func TestUpdateUser(t *testing.T) {
 	repo := NewUserRepository(&database.DB{DB: db})
 
 	user := &domain.User{
-		Email: "updated@example.com",
-		Name:  "Updated Name",
+		Email:   "updated@example.com",
+		Name:    "Updated Name",
+		Version: 2,
 	}
 
 	now := time.Now()
-	rows := sqlmock.NewRows([]string{"updated_at"}).AddRow(now)
+	rows := sqlmock.NewRows([]string{"updated_at", "version"}).AddRow(now, 3)
 
-	mock.ExpectQuery("UPDATE users").
-		WithArgs(user.Email, user.Name, 1).
+	mock.ExpectQuery(`UPDATE users (.+) WHERE id = \$3 AND version = \$4`).
+		WithArgs(user.Email, user.Name, 1, 2).
 		WillReturnRows(rows)
 
 	ctx := context.Background()
@@ -191,6 +310,7 @@ func TestUpdateUser(t *testing.T) {
 
 	assert.NoError(t, err)
 	assert.Equal(t, 1, user.ID)
+	assert.Equal(t, 3, user.Version)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -207,19 +327,55 @@ func TestUpdateUser_NotFound(t *testing.T) {
 	repo := NewUserRepository(&database.DB{DB: db})
 
 	user := &domain.User{
-		Email: "updated@example.com",
-		Name:  "Updated Name",
+		Email:   "updated@example.com",
+		Name:    "Updated Name",
+		Version: 1,
 	}
 
 	mock.ExpectQuery("UPDATE users").
-		WithArgs(user.Email, user.Name, 999).
-		WillReturnRows(sqlmock.NewRows([]string{"updated_at"}))
+		WithArgs(user.Email, user.Name, 999, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(999).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
 
 	ctx := context.Background()
 	err = repo.Update(ctx, 999, user)
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "user not found")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdateUser_VersionMismatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	user := &domain.User{
+		Email:   "updated@example.com",
+		Name:    "Updated Name",
+		Version: 1,
+	}
+
+	mock.ExpectQuery("UPDATE users").
+		WithArgs(user.Email, user.Name, 1, 1).
+		WillReturnRows(sqlmock.NewRows([]string{"updated_at", "version"}))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+
+	err = repo.Update(context.Background(), 1, user)
+
+	assert.ErrorIs(t, err, domain.ErrUserVersionMismatch)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -235,7 +391,7 @@ func TestDeleteUser(t *testing.T) {
 
 	repo := NewUserRepository(&database.DB{DB: db})
 
-	mock.ExpectExec("DELETE FROM users WHERE id").
+	mock.ExpectExec(`UPDATE users SET deleted_at = CURRENT_TIMESTAMP, version = version \+ 1 WHERE id = \$1 AND deleted_at IS NULL`).
 		WithArgs(1).
 		WillReturnResult(sqlmock.NewResult(0, 1))
 
@@ -258,7 +414,7 @@ func TestDeleteUser_NotFound(t *testing.T) {
 
 	repo := NewUserRepository(&database.DB{DB: db})
 
-	mock.ExpectExec("DELETE FROM users WHERE id").
+	mock.ExpectExec(`UPDATE users SET deleted_at = CURRENT_TIMESTAMP, version = version \+ 1 WHERE id = \$1 AND deleted_at IS NULL`).
 		WithArgs(999).
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
@@ -267,6 +423,7 @@ func TestDeleteUser_NotFound(t *testing.T) {
 
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "user not found")
+	assert.ErrorIs(t, err, domain.ErrUserNotFound)
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
@@ -292,10 +449,212 @@ func TestCreateUser_WithContext_Timeout(t *testing.T) {
 	cancel()
 
 	mock.ExpectQuery("INSERT INTO users").
-		WithArgs(user.Email, user.Name).
+		WithArgs(user.Email, user.Name, "", domain.RoleUser).
 		WillReturnError(context.Canceled)
 
 	err = repo.Create(ctx, user)
 
 	assert.Error(t, err)
 }
+
+func TestGetAllUsers_Filtered(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+	verified := true
+	query := &domain.UserQuery{
+		Filter: domain.UserFilter{EmailDomain: "Example.com", Verified: &verified, Query: "50%_off", IncludeDeleted: true},
+		Sort:   []domain.SortField{{Field: "created_at", Desc: true}, {Field: "id"}},
+	}
+
+	where := `WHERE split_part\(email, '@', 2\) = \$1 AND verified_at IS NOT NULL AND \(name ILIKE \$2 OR email ILIKE \$2\)`
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM users "+where).
+		WithArgs("example.com", `%50\%\_off%`).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT (.+) FROM users "+where+" ORDER BY created_at DESC, id ASC LIMIT \\$3 OFFSET \\$4").
+		WithArgs("example.com", `%50\%\_off%`, 10, 0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "email", "name", "verified_at", "created_at", "updated_at", "version", "deleted_at"}))
+
+	users, total, err := repo.GetAll(context.Background(), query, 10, 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 0, total)
+	assert.Empty(t, users)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetUsersPage_Backward(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "email", "name", "verified_at", "created_at", "updated_at", "version", "deleted_at"}).
+		AddRow(9, "nine@example.com", "Bob", nil, now, now, 1, nil).
+		AddRow(8, "eight@example.com", "Ann", nil, now, now, 1, nil)
+
+	mock.ExpectQuery(`SELECT (.+) FROM users WHERE deleted_at IS NULL AND \(\(name < \$1\) OR \(name = \$1 AND id < \$2\)\) ORDER BY name DESC, id DESC LIMIT \$3`).
+		WithArgs("Cid", "10", 2).
+		WillReturnRows(rows)
+
+	users, err := repo.GetPage(context.Background(), &domain.UserPageQuery{
+		UserQuery: domain.UserQuery{Sort: []domain.SortField{{Field: "name"}, {Field: "id"}}},
+		After:     []string{"Cid", "10"},
+		Backward:  true,
+		Limit:     2,
+	})
+
+	assert.NoError(t, err)
+	assert.Len(t, users, 2)
+	assert.Equal(t, 8, users[0].ID)
+	assert.Equal(t, 9, users[1].ID)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "email", "name", "verified_at", "created_at", "updated_at", "version", "rank", "ts_headline"}).
+		AddRow(4, "ann@example.com", "Ann <script>", nil, now, now, 1, 0.6, "\x02Ann\x03 <script> ann@example.com")
+
+	mock.ExpectQuery(`SELECT (.+) FROM users, websearch_to_tsquery\('simple', \$1\) AS query WHERE search_vector @@ query AND deleted_at IS NULL ORDER BY rank DESC, id LIMIT \$2 OFFSET \$3`).
+		WithArgs("ann", 10, 0, headlineOptions).
+		WillReturnRows(rows)
+
+	results, err := repo.Search(context.Background(), "ann", 10, 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, 4, results[0].ID)
+	assert.Equal(t, 0.6, results[0].Rank)
+	assert.Equal(t, "<mark>Ann</mark> &lt;script&gt; ann@example.com", results[0].Highlight)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSearchUsers_RowError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	// The connection breaks after the first result
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "email", "name", "verified_at", "created_at", "updated_at", "version", "rank", "ts_headline"}).
+		AddRow(4, "ann@example.com", "Ann", nil, now, now, 1, 0.6, "\x02Ann\x03 ann@example.com").
+		AddRow(5, "anna@example.com", "Anna", nil, now, now, 1, 0.3, "Anna anna@example.com").
+		RowError(1, errors.New("connection reset"))
+	mock.ExpectQuery(`SELECT (.+) FROM users, websearch_to_tsquery`).
+		WithArgs("ann", 10, 0, headlineOptions).
+		WillReturnRows(rows)
+
+	results, err := repo.Search(context.Background(), "ann", 10, 0)
+
+	assert.ErrorContains(t, err, "connection reset")
+	assert.Nil(t, results)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreUser(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "email", "name", "verified_at", "created_at", "updated_at", "version"}).
+		AddRow(1, "test@example.com", "Test User", nil, now, now, 4)
+
+	mock.ExpectQuery(`UPDATE users SET deleted_at = NULL, (.+) WHERE id = \$1 AND deleted_at IS NOT NULL`).
+		WithArgs(1).
+		WillReturnRows(rows)
+
+	user, err := repo.Restore(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, "test@example.com", user.Email)
+	assert.Nil(t, user.DeletedAt)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRestoreUser_EmailTaken(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	mock.ExpectQuery("UPDATE users SET deleted_at = NULL").
+		WithArgs(1).
+		WillReturnError(&pq.Error{Code: pgUniqueViolation})
+
+	user, err := repo.Restore(context.Background(), 1)
+
+	assert.ErrorIs(t, err, domain.ErrEmailInUse)
+	assert.Nil(t, user)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPurgeUsers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewUserRepository(&database.DB{DB: db})
+
+	before := time.Now().Add(-time.Hour)
+	mock.ExpectExec(`DELETE FROM users WHERE deleted_at < \$1`).
+		WithArgs(before).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	purged, err := repo.Purge(context.Background(), before)
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), purged)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+)
+
+type verificationCodeRepository struct {
+	db *database.DB
+}
+
+func NewVerificationCodeRepository(db *database.DB) domain.VerificationCodeRepository {
+	return &verificationCodeRepository{db: db}
+}
+
+func (r *verificationCodeRepository) Create(ctx context.Context, code *domain.VerificationCode, interval time.Duration) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// The update locks the user, so a parallel request waits for it and then
+	// no longer matches. Times are taken from the database so instances with
+	// skewed clocks agree.
+	query := `
+	WITH sent AS (
+		UPDATE users
+		SET verification_sent_at = CURRENT_TIMESTAMP
+		WHERE id = $1
+			AND (verification_sent_at IS NULL OR verification_sent_at <= CURRENT_TIMESTAMP - make_interval(secs => $4))
+		RETURNING id
+	)
+	INSERT INTO verification_codes (user_id, code_hash, expires_at)
+	SELECT id, $2, $3 FROM sent
+	RETURNING id, created_at;`
+
+	err := r.db.QueryRowContext(ctx, query, code.UserID, code.CodeHash, code.ExpiresAt, interval.Seconds()).Scan(
+		&code.ID,
+		&code.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error creating verification code: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *verificationCodeRepository) GetLatest(ctx context.Context, userID int) (*domain.VerificationCode, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	code := &domain.VerificationCode{}
+	query := `
+	SELECT id, user_id, code_hash, attempts, expires_at, consumed_at, created_at
+	FROM verification_codes
+	WHERE user_id = $1
+	ORDER BY created_at DESC, id DESC
+	LIMIT 1;`
+
+	err := r.db.QueryRowContext(ctx, query, userID).Scan(
+		&code.ID, &code.UserID, &code.CodeHash, &code.Attempts, &code.ExpiresAt, &code.ConsumedAt, &code.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting verification code: %w", err)
+	}
+
+	return code, nil
+}
+
+func (r *verificationCodeRepository) ReserveAttempt(ctx context.Context, id, maxAttempts int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Checking and counting in one statement keeps parallel guesses from all
+	// passing the limit
+	query := `
+	UPDATE verification_codes
+	SET attempts = attempts + 1
+	WHERE id = $1 AND attempts < $2
+	RETURNING attempts;`
+
+	var attempts int
+	err := r.db.QueryRowContext(ctx, query, id, maxAttempts).Scan(&attempts)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reserving verification attempt: %w", err)
+	}
+	return true, nil
+}
+
+func (r *verificationCodeRepository) Consume(ctx context.Context, id, userID int) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	consume := `
+	UPDATE verification_codes
+	SET consumed_at = CURRENT_TIMESTAMP
+	WHERE id = $1 AND consumed_at IS NULL;`
+
+	result, err := tx.ExecContext(ctx, consume, id)
+	if err != nil {
+		return fmt.Errorf("error consuming verification code: %w", err)
+	}
+
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error checking consumed rows: %w", err)
+	}
+	if rows == 0 {
+		return domain.ErrVerificationCodeUsed
+	}
+
+	verify := `
+	UPDATE users
+	SET verified_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP, version = version + 1
+	WHERE id = $1 AND verified_at IS NULL;`
+
+	if _, err = tx.ExecContext(ctx, verify, userID); err != nil {
+		return fmt.Errorf("error verifying user: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReserveVerificationAttempt(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewVerificationCodeRepository(&database.DB{DB: db})
+
+	mock.ExpectQuery(`UPDATE verification_codes SET attempts = attempts \+ 1 WHERE id = \$1 AND attempts < \$2`).
+		WithArgs(5, 3).
+		WillReturnRows(sqlmock.NewRows([]string{"attempts"}).AddRow(3))
+	// The limit is reached, the row no longer matches
+	mock.ExpectQuery(`UPDATE verification_codes SET attempts = attempts \+ 1 WHERE id = \$1 AND attempts < \$2`).
+		WithArgs(5, 3).
+		WillReturnError(sql.ErrNoRows)
+
+	ctx := context.Background()
+	reserved, err := repo.ReserveAttempt(ctx, 5, 3)
+	assert.NoError(t, err)
+	assert.True(t, reserved)
+
+	reserved, err = repo.ReserveAttempt(ctx, 5, 3)
+	assert.NoError(t, err)
+	assert.False(t, reserved)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCreateVerificationCodeThrottles(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	repo := NewVerificationCodeRepository(&database.DB{DB: db})
+
+	expiresAt := time.Now().Add(15 * time.Minute)
+	mock.ExpectQuery(`WITH sent AS \( UPDATE users SET verification_sent_at = CURRENT_TIMESTAMP .+ INSERT INTO verification_codes`).
+		WithArgs(1, "hash", expiresAt, 60.0).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(7, time.Now()))
+	// The user was just sent a code, the update matches nothing
+	mock.ExpectQuery(`WITH sent AS \( UPDATE users SET verification_sent_at = CURRENT_TIMESTAMP .+ INSERT INTO verification_codes`).
+		WithArgs(1, "hash", expiresAt, 60.0).
+		WillReturnError(sql.ErrNoRows)
+
+	ctx := context.Background()
+	code := &domain.VerificationCode{UserID: 1, CodeHash: "hash", ExpiresAt: expiresAt}
+	created, err := repo.Create(ctx, code, time.Minute)
+	assert.NoError(t, err)
+	assert.True(t, created)
+	assert.Equal(t, 7, code.ID)
+
+	created, err = repo.Create(ctx, code, time.Minute)
+	assert.NoError(t, err)
+	assert.False(t, created)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
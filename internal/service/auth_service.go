@@ -0,0 +1,236 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/token"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type authService struct {
+	repo          domain.UserRepository
+	refreshTokens domain.RefreshTokenRepository
+	hasher        domain.PasswordHasher
+	tokens        domain.TokenManager
+	verification  domain.VerificationService
+	refreshTTL    time.Duration
+	logger        *zap.Logger
+	// dummyHash is compared against when a user does not exist, so login
+	// takes the same time for unknown emails and wrong passwords
+	dummyHash string
+}
+
+func NewAuthService(
+	repo domain.UserRepository,
+	refreshTokens domain.RefreshTokenRepository,
+	hasher domain.PasswordHasher,
+	tokens domain.TokenManager,
+	verification domain.VerificationService,
+	refreshTTL time.Duration,
+	logger *zap.Logger,
+) domain.AuthService {
+	dummyHash, err := hasher.Hash("dummy password for timing")
+	if err != nil {
+		logger.Warn("Failed to prepare dummy password hash", zap.Error(err))
+	}
+
+	return &authService{
+		repo:          repo,
+		refreshTokens: refreshTokens,
+		hasher:        hasher,
+		tokens:        tokens,
+		verification:  verification,
+		refreshTTL:    refreshTTL,
+		logger:        logger,
+		dummyHash:     dummyHash,
+	}
+}
+
+func (s *authService) Register(ctx context.Context, req *domain.RegisterRequest) (*domain.User, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	existing, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		s.logger.Error("Error checking existing user", zap.Error(err))
+		return nil, fmt.Errorf("failed to check existing user: %w", err)
+	}
+	if existing != nil {
+		return nil, domain.ErrUserExists
+	}
+
+	hash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		s.logger.Error("Error hashing password", zap.Error(err))
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &domain.User{
+		Email:        email,
+		Name:         strings.TrimSpace(req.Name),
+		PasswordHash: hash,
+	}
+
+	if err := s.repo.Create(ctx, user); err != nil {
+		if errors.Is(err, domain.ErrEmailInUse) {
+			return nil, domain.ErrUserExists
+		}
+		s.logger.Error("Error registering user", zap.Error(err))
+		return nil, fmt.Errorf("failed to register user: %w", err)
+	}
+
+	s.logger.Info("User registered", zap.Int("user_id", user.ID), zap.String("email", user.Email))
+
+	// The account exists at this point, a failed mail can be retried with a resend
+	if err := s.verification.SendCode(ctx, user); err != nil {
+		s.logger.Warn("Failed to send verification code after registration", zap.Int("user_id", user.ID), zap.Error(err))
+	}
+
+	return user, nil
+}
+
+func (s *authService) Login(ctx context.Context, req *domain.LoginRequest) (*domain.AuthResponse, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	user, err := s.repo.GetByEmail(ctx, email)
+	if err != nil {
+		s.logger.Error("Error getting user by email", zap.Error(err))
+		return nil, fmt.Errorf("failed to get user by email: %w", err)
+	}
+
+	if user == nil || user.PasswordHash == "" {
+		if s.dummyHash != "" {
+			_, _ = s.hasher.Compare(s.dummyHash, req.Password)
+		}
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	ok, err := s.hasher.Compare(user.PasswordHash, req.Password)
+	if err != nil {
+		s.logger.Error("Error comparing password", zap.Error(err))
+		return nil, fmt.Errorf("failed to compare password: %w", err)
+	}
+	if !ok {
+		return nil, domain.ErrInvalidCredentials
+	}
+
+	// Every login starts a new refresh token family
+	tokens, err := s.issueTokens(ctx, user, uuid.NewString(), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.Info("User logged in", zap.Int("user_id", user.ID))
+	return &domain.AuthResponse{User: user, Tokens: tokens}, nil
+}
+
+func (s *authService) Refresh(ctx context.Context, refreshToken string) (*domain.TokenPair, error) {
+	current, err := s.refreshTokens.GetByHash(ctx, token.Hash(refreshToken))
+	if err != nil {
+		s.logger.Error("Error getting refresh token", zap.Error(err))
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if current == nil {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+
+	// A revoked token being presented again means it leaked, so the whole
+	// family is revoked and the legitimate holder has to log in again
+	if current.RevokedAt != nil {
+		s.revokeFamily(ctx, current)
+		return nil, domain.ErrInvalidRefreshToken
+	}
+	if time.Now().After(current.ExpiresAt) {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+
+	user, err := s.repo.GetByID(ctx, current.UserID)
+	if err != nil {
+		s.logger.Error("Error getting user by id", zap.Error(err))
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if user == nil {
+		return nil, domain.ErrInvalidRefreshToken
+	}
+
+	return s.issueTokens(ctx, user, current.FamilyID, current.ID)
+}
+
+func (s *authService) Logout(ctx context.Context, refreshToken string) error {
+	current, err := s.refreshTokens.GetByHash(ctx, token.Hash(refreshToken))
+	if err != nil {
+		s.logger.Error("Error getting refresh token", zap.Error(err))
+		return fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if current == nil {
+		return nil
+	}
+
+	if err := s.refreshTokens.RevokeFamily(ctx, current.FamilyID); err != nil {
+		s.logger.Error("Error revoking refresh token family", zap.Error(err))
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+
+	s.logger.Info("User logged out", zap.Int("user_id", current.UserID))
+	return nil
+}
+
+// issueTokens creates an access token and a refresh token in familyID.
+// A non-zero previousID rotates that refresh token instead of creating a new family.
+func (s *authService) issueTokens(ctx context.Context, user *domain.User, familyID string, previousID int) (*domain.TokenPair, error) {
+	accessToken, accessExpiresAt, err := s.tokens.Generate(user)
+	if err != nil {
+		s.logger.Error("Error generating access token", zap.Error(err))
+		return nil, fmt.Errorf("failed to generate access token: %w", err)
+	}
+
+	plain, hash, err := token.NewOpaque()
+	if err != nil {
+		s.logger.Error("Error generating refresh token", zap.Error(err))
+		return nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+
+	refresh := &domain.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+
+	if previousID == 0 {
+		err = s.refreshTokens.Create(ctx, refresh)
+	} else {
+		err = s.refreshTokens.Rotate(ctx, previousID, refresh)
+	}
+	if err != nil {
+		if errors.Is(err, domain.ErrRefreshTokenUsed) {
+			s.revokeFamily(ctx, &domain.RefreshToken{UserID: user.ID, FamilyID: familyID})
+			return nil, domain.ErrInvalidRefreshToken
+		}
+		s.logger.Error("Error storing refresh token", zap.Error(err))
+		return nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+
+	return &domain.TokenPair{
+		AccessToken:           accessToken,
+		TokenType:             "Bearer",
+		AccessTokenExpiresAt:  accessExpiresAt,
+		RefreshToken:          plain,
+		RefreshTokenExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+func (s *authService) revokeFamily(ctx context.Context, reused *domain.RefreshToken) {
+	s.logger.Warn("Refresh token reuse detected, revoking family",
+		zap.Int("user_id", reused.UserID),
+		zap.String("family_id", reused.FamilyID),
+	)
+	if err := s.refreshTokens.RevokeFamily(ctx, reused.FamilyID); err != nil {
+		s.logger.Error("Error revoking refresh token family", zap.Error(err))
+	}
+}
@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/token"
+	"github.com/DMaryanskiy/go-idk/pkg/password"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Mock Refresh Token Repository
+type MockRefreshTokenRepository struct {
+	mock.Mock
+}
+
+func (m *MockRefreshTokenRepository) Create(ctx context.Context, token *domain.RefreshToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) GetByHash(ctx context.Context, hash string) (*domain.RefreshToken, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.RefreshToken), args.Error(1)
+}
+
+func (m *MockRefreshTokenRepository) Rotate(ctx context.Context, oldID int, next *domain.RefreshToken) error {
+	args := m.Called(ctx, oldID, next)
+	return args.Error(0)
+}
+
+func (m *MockRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	args := m.Called(ctx, familyID)
+	return args.Error(0)
+}
+
+// Stub token manager
+type stubTokenManager struct{}
+
+func (stubTokenManager) Generate(user *domain.User) (string, time.Time, error) {
+	return "access-token", time.Now().Add(time.Minute), nil
+}
+
+func (stubTokenManager) Parse(token string) (*domain.AccessClaims, error) {
+	return &domain.AccessClaims{UserID: 1}, nil
+}
+
+// Mock Verification Service
+type MockVerificationService struct {
+	mock.Mock
+}
+
+func (m *MockVerificationService) SendCode(ctx context.Context, user *domain.User) error {
+	args := m.Called(ctx, user)
+	return args.Error(0)
+}
+
+func (m *MockVerificationService) Verify(ctx context.Context, req *domain.VerifyEmailRequest) error {
+	args := m.Called(ctx, req)
+	return args.Error(0)
+}
+
+func (m *MockVerificationService) Resend(ctx context.Context, req *domain.ResendVerificationRequest) {
+	m.Called(ctx, req)
+}
+
+func (m *MockVerificationService) Wait(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func newTestAuthService(repo domain.UserRepository, refreshTokens domain.RefreshTokenRepository) domain.AuthService {
+	return newTestAuthServiceWithVerification(repo, refreshTokens, new(MockVerificationService))
+}
+
+func newTestAuthServiceWithVerification(
+	repo domain.UserRepository,
+	refreshTokens domain.RefreshTokenRepository,
+	verification domain.VerificationService,
+) domain.AuthService {
+	logger, _ := zap.NewDevelopment()
+	return NewAuthService(
+		repo, refreshTokens, password.NewHasher(bcrypt.MinCost), stubTokenManager{}, verification, time.Hour, logger,
+	)
+}
+
+func TestRegister_Success(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockVerification := new(MockVerificationService)
+	service := newTestAuthServiceWithVerification(mockRepo, new(MockRefreshTokenRepository), mockVerification)
+
+	req := &domain.RegisterRequest{
+		Email:    " Test@Example.com ",
+		Name:     "Test User",
+		Password: "Secret123",
+	}
+
+	ctx := context.Background()
+	mockRepo.On("GetByEmail", ctx, "test@example.com").Return(nil, nil)
+	mockRepo.On("Create", ctx, mock.AnythingOfType("*domain.User")).
+		Run(func(args mock.Arguments) {
+			args.Get(1).(*domain.User).ID = 1
+		}).
+		Return(nil)
+	mockVerification.On("SendCode", ctx, mock.AnythingOfType("*domain.User")).Return(nil)
+
+	user, err := service.Register(ctx, req)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, user.ID)
+	assert.Equal(t, "test@example.com", user.Email)
+	assert.NotEqual(t, "Secret123", user.PasswordHash)
+	assert.NoError(t, bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte("Secret123")))
+	mockRepo.AssertExpectations(t)
+	mockVerification.AssertExpectations(t)
+}
+
+func TestRegister_DuplicateEmail(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := newTestAuthService(mockRepo, new(MockRefreshTokenRepository))
+
+	req := &domain.RegisterRequest{
+		Email:    "test@example.com",
+		Name:     "Test User",
+		Password: "Secret123",
+	}
+
+	ctx := context.Background()
+	mockRepo.On("GetByEmail", ctx, "test@example.com").Return(&domain.User{ID: 1}, nil)
+
+	user, err := service.Register(ctx, req)
+
+	assert.Error(t, err)
+	assert.Nil(t, user)
+	assert.Contains(t, err.Error(), "already exists")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogin_Success(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockTokens := new(MockRefreshTokenRepository)
+	service := newTestAuthService(mockRepo, mockTokens)
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("Secret123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	mockRepo.On("GetByEmail", ctx, "test@example.com").
+		Return(&domain.User{ID: 1, Email: "test@example.com", PasswordHash: string(hash)}, nil)
+	mockTokens.On("Create", ctx, mock.AnythingOfType("*domain.RefreshToken")).Return(nil)
+
+	response, err := service.Login(ctx, &domain.LoginRequest{Email: "test@example.com", Password: "Secret123"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, response.User.ID)
+	assert.Equal(t, "access-token", response.Tokens.AccessToken)
+	assert.NotEmpty(t, response.Tokens.RefreshToken)
+
+	stored := mockTokens.Calls[0].Arguments.Get(1).(*domain.RefreshToken)
+	assert.Equal(t, token.Hash(response.Tokens.RefreshToken), stored.TokenHash)
+	assert.NotEmpty(t, stored.FamilyID)
+	mockRepo.AssertExpectations(t)
+	mockTokens.AssertExpectations(t)
+}
+
+func TestLogin_WrongPassword(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := newTestAuthService(mockRepo, new(MockRefreshTokenRepository))
+
+	hash, err := bcrypt.GenerateFromPassword([]byte("Secret123"), bcrypt.MinCost)
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	mockRepo.On("GetByEmail", ctx, "test@example.com").
+		Return(&domain.User{ID: 1, Email: "test@example.com", PasswordHash: string(hash)}, nil)
+
+	response, err := service.Login(ctx, &domain.LoginRequest{Email: "test@example.com", Password: "Wrong1234"})
+
+	assert.Error(t, err)
+	assert.Nil(t, response)
+	assert.Contains(t, err.Error(), "invalid email or password")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestLogin_UnknownUser(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := newTestAuthService(mockRepo, new(MockRefreshTokenRepository))
+
+	ctx := context.Background()
+	mockRepo.On("GetByEmail", ctx, "missing@example.com").Return(nil, nil)
+
+	response, err := service.Login(ctx, &domain.LoginRequest{Email: "missing@example.com", Password: "Secret123"})
+
+	assert.Error(t, err)
+	assert.Nil(t, response)
+	assert.Contains(t, err.Error(), "invalid email or password")
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRefresh_Rotates(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockTokens := new(MockRefreshTokenRepository)
+	service := newTestAuthService(mockRepo, mockTokens)
+
+	ctx := context.Background()
+	current := &domain.RefreshToken{ID: 7, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour)}
+
+	mockTokens.On("GetByHash", ctx, token.Hash("old-token")).Return(current, nil)
+	mockRepo.On("GetByID", ctx, 1).Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockTokens.On("Rotate", ctx, 7, mock.MatchedBy(func(next *domain.RefreshToken) bool {
+		return next.FamilyID == "family" && next.UserID == 1
+	})).Return(nil)
+
+	tokens, err := service.Refresh(ctx, "old-token")
+
+	assert.NoError(t, err)
+	assert.NotEqual(t, "old-token", tokens.RefreshToken)
+	assert.Equal(t, "Bearer", tokens.TokenType)
+	mockRepo.AssertExpectations(t)
+	mockTokens.AssertExpectations(t)
+}
+
+func TestRefresh_ReuseRevokesFamily(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockTokens := new(MockRefreshTokenRepository)
+	service := newTestAuthService(mockRepo, mockTokens)
+
+	ctx := context.Background()
+	revokedAt := time.Now().Add(-time.Minute)
+	current := &domain.RefreshToken{
+		ID: 7, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(time.Hour), RevokedAt: &revokedAt,
+	}
+
+	mockTokens.On("GetByHash", ctx, token.Hash("stolen-token")).Return(current, nil)
+	mockTokens.On("RevokeFamily", ctx, "family").Return(nil)
+
+	tokens, err := service.Refresh(ctx, "stolen-token")
+
+	assert.Error(t, err)
+	assert.Nil(t, tokens)
+	assert.Contains(t, err.Error(), "invalid refresh token")
+	mockTokens.AssertExpectations(t)
+}
+
+func TestRefresh_Expired(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockTokens := new(MockRefreshTokenRepository)
+	service := newTestAuthService(mockRepo, mockTokens)
+
+	ctx := context.Background()
+	current := &domain.RefreshToken{ID: 7, UserID: 1, FamilyID: "family", ExpiresAt: time.Now().Add(-time.Minute)}
+
+	mockTokens.On("GetByHash", ctx, token.Hash("old-token")).Return(current, nil)
+
+	tokens, err := service.Refresh(ctx, "old-token")
+
+	assert.Error(t, err)
+	assert.Nil(t, tokens)
+	mockTokens.AssertExpectations(t)
+}
@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"go.uber.org/zap"
+)
+
+type authorizationService struct {
+	repo   domain.RoleRepository
+	logger *zap.Logger
+}
+
+func NewAuthorizationService(repo domain.RoleRepository, logger *zap.Logger) domain.AuthorizationService {
+	return &authorizationService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *authorizationService) GetActor(ctx context.Context, userID int) (*domain.Actor, error) {
+	permissions, err := s.repo.GetUserPermissions(ctx, userID)
+	if err != nil {
+		s.logger.Error("Error getting user permissions", zap.Int("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get user permissions: %w", err)
+	}
+
+	actor := &domain.Actor{
+		UserID:      userID,
+		Permissions: make(map[string]bool, len(permissions)),
+	}
+	for _, permission := range permissions {
+		actor.Permissions[permission] = true
+	}
+
+	return actor, nil
+}
+
+func (s *authorizationService) GetRoles(ctx context.Context) ([]domain.Role, error) {
+	roles, err := s.repo.GetAll(ctx)
+	if err != nil {
+		s.logger.Error("Error getting roles", zap.Error(err))
+		return nil, fmt.Errorf("failed to get roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (s *authorizationService) CreateRole(ctx context.Context, req *domain.CreateRoleRequest) (*domain.Role, error) {
+	permissions := make([]string, 0, len(req.Permissions))
+	for _, permission := range req.Permissions {
+		permissions = append(permissions, strings.TrimSpace(permission))
+	}
+	slices.Sort(permissions)
+
+	role := &domain.Role{
+		Name:        strings.ToLower(strings.TrimSpace(req.Name)),
+		Description: strings.TrimSpace(req.Description),
+		Permissions: slices.Compact(permissions),
+	}
+
+	if err := s.repo.Create(ctx, role); err != nil {
+		if errors.Is(err, domain.ErrRoleExists) || errors.Is(err, domain.ErrUnknownPermission) {
+			return nil, err
+		}
+		s.logger.Error("Error creating role", zap.Error(err))
+		return nil, fmt.Errorf("failed to create role: %w", err)
+	}
+
+	s.logger.Info("Role created", zap.Int("role_id", role.ID), zap.String("name", role.Name))
+	return role, nil
+}
+
+func (s *authorizationService) AssignRole(ctx context.Context, userID int, req *domain.AssignRoleRequest) error {
+	roleName := strings.ToLower(strings.TrimSpace(req.Role))
+
+	if err := s.repo.AssignToUser(ctx, userID, roleName); err != nil {
+		if errors.Is(err, domain.ErrRoleNotFound) || errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
+		s.logger.Error("Error assigning role", zap.Int("user_id", userID), zap.Error(err))
+		return fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	s.logger.Info("Role assigned", zap.Int("user_id", userID), zap.String("role", roleName))
+	return nil
+}
+
+func (s *authorizationService) RemoveRole(ctx context.Context, userID int, roleName string) error {
+	roleName = strings.ToLower(strings.TrimSpace(roleName))
+
+	if err := s.repo.RemoveFromUser(ctx, userID, roleName); err != nil {
+		if errors.Is(err, domain.ErrRoleAssignmentNotFound) {
+			return err
+		}
+		s.logger.Error("Error removing role", zap.Int("user_id", userID), zap.Error(err))
+		return fmt.Errorf("failed to remove role: %w", err)
+	}
+
+	s.logger.Info("Role removed", zap.Int("user_id", userID), zap.String("role", roleName))
+	return nil
+}
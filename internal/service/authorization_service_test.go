@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Mock Role Repository
+type MockRoleRepository struct {
+	mock.Mock
+}
+
+func (m *MockRoleRepository) GetUserPermissions(ctx context.Context, userID int) ([]string, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRoleRepository) GetAll(ctx context.Context) ([]domain.Role, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Role), args.Error(1)
+}
+
+func (m *MockRoleRepository) Create(ctx context.Context, role *domain.Role) error {
+	args := m.Called(ctx, role)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) AssignToUser(ctx context.Context, userID int, roleName string) error {
+	args := m.Called(ctx, userID, roleName)
+	return args.Error(0)
+}
+
+func (m *MockRoleRepository) RemoveFromUser(ctx context.Context, userID int, roleName string) error {
+	args := m.Called(ctx, userID, roleName)
+	return args.Error(0)
+}
+
+func TestGetActor_Success(t *testing.T) {
+	mockRepo := new(MockRoleRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewAuthorizationService(mockRepo, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetUserPermissions", ctx, 1).Return([]string{domain.PermissionUsersRead, domain.PermissionUsersUpdate}, nil)
+
+	actor, err := service.GetActor(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 1, actor.UserID)
+	assert.True(t, actor.Can(domain.PermissionUsersRead))
+	assert.False(t, actor.Can(domain.PermissionUsersDelete))
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetActor_RepositoryError(t *testing.T) {
+	mockRepo := new(MockRoleRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewAuthorizationService(mockRepo, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetUserPermissions", ctx, 1).Return(nil, errors.New("database error"))
+
+	actor, err := service.GetActor(ctx, 1)
+
+	assert.Error(t, err)
+	assert.Nil(t, actor)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateRole_NormalizesInput(t *testing.T) {
+	mockRepo := new(MockRoleRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewAuthorizationService(mockRepo, logger)
+
+	ctx := context.Background()
+	mockRepo.On("Create", ctx, mock.MatchedBy(func(role *domain.Role) bool {
+		return role.Name == "moderator" &&
+			assert.ObjectsAreEqual([]string{"users:list", "users:read"}, role.Permissions)
+	})).Return(nil)
+
+	role, err := service.CreateRole(ctx, &domain.CreateRoleRequest{
+		Name:        " Moderator ",
+		Permissions: []string{"users:read", " users:list", "users:read"},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "moderator", role.Name)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateRole_AlreadyExists(t *testing.T) {
+	mockRepo := new(MockRoleRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewAuthorizationService(mockRepo, logger)
+
+	ctx := context.Background()
+	mockRepo.On("Create", ctx, mock.AnythingOfType("*domain.Role")).Return(domain.ErrRoleExists)
+
+	role, err := service.CreateRole(ctx, &domain.CreateRoleRequest{Name: "admin", Permissions: []string{"users:read"}})
+
+	assert.Error(t, err)
+	assert.Nil(t, role)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAssignRole_RoleNotFound(t *testing.T) {
+	mockRepo := new(MockRoleRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewAuthorizationService(mockRepo, logger)
+
+	ctx := context.Background()
+	mockRepo.On("AssignToUser", ctx, 1, "missing").Return(domain.ErrRoleNotFound)
+
+	err := service.AssignRole(ctx, 1, &domain.AssignRoleRequest{Role: "Missing"})
+
+	assert.Error(t, err)
+	assert.ErrorIs(t, err, domain.ErrRoleNotFound)
+	mockRepo.AssertExpectations(t)
+}
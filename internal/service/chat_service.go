@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"go.uber.org/zap"
+)
+
+type ChatConfig struct {
+	// EditWindow is how long after sending a message its sender may edit it
+	EditWindow time.Duration
+}
+
+type chatService struct {
+	notifier
+	repo   domain.ChatRepository
+	users  domain.UserRepository
+	cfg    ChatConfig
+	logger *zap.Logger
+}
+
+func NewChatService(
+	repo domain.ChatRepository,
+	users domain.UserRepository,
+	publisher domain.EventPublisher,
+	cfg ChatConfig,
+	logger *zap.Logger,
+) domain.ChatService {
+	return &chatService{
+		notifier: notifier{chats: repo, publisher: publisher, logger: logger},
+		repo:     repo,
+		users:    users,
+		cfg:      cfg,
+		logger:   logger,
+	}
+}
+
+func (s *chatService) SendMessage(ctx context.Context, senderID int, req *domain.SendMessageRequest) (*domain.Message, error) {
+	switch {
+	case req.ConversationID != 0 && req.RecipientID == 0:
+		return s.sendToConversation(ctx, senderID, req)
+	case req.RecipientID != 0 && req.ConversationID == 0:
+		return s.sendDirect(ctx, senderID, req)
+	default:
+		return nil, domain.ErrMessageTarget
+	}
+}
+
+func (s *chatService) sendDirect(ctx context.Context, senderID int, req *domain.SendMessageRequest) (*domain.Message, error) {
+	if req.RecipientID == senderID {
+		return nil, domain.ErrMessageToSelf
+	}
+
+	recipient, err := s.users.GetByID(ctx, req.RecipientID)
+	if err != nil {
+		s.logger.Error("Error getting recipient", zap.Int("recipient_id", req.RecipientID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get recipient: %w", err)
+	}
+	if recipient == nil {
+		return nil, domain.ErrRecipientNotFound
+	}
+
+	msg := &domain.Message{
+		SenderID: senderID,
+		Body:     req.Body,
+	}
+	if err := s.repo.CreateDirectMessage(ctx, recipient.ID, msg); err != nil {
+		if errors.Is(err, domain.ErrRecipientNotFound) {
+			return nil, err
+		}
+		s.logger.Error("Error storing message", zap.Int("sender_id", senderID), zap.Error(err))
+		return nil, fmt.Errorf("failed to store message: %w", err)
+	}
+
+	// The message is stored at this point, clients that miss the event get it from history
+	s.publish(ctx, []int{senderID, recipient.ID}, domain.Event{Type: domain.EventMessageNew, Payload: msg})
+
+	return msg, nil
+}
+
+func (s *chatService) sendToConversation(ctx context.Context, senderID int, req *domain.SendMessageRequest) (*domain.Message, error) {
+	if _, err := s.member(ctx, req.ConversationID, senderID); err != nil {
+		return nil, err
+	}
+
+	msg := &domain.Message{
+		ConversationID: req.ConversationID,
+		SenderID:       senderID,
+		Body:           req.Body,
+	}
+	if err := s.repo.CreateMessage(ctx, msg); err != nil {
+		s.logger.Error("Error storing message", zap.Int("sender_id", senderID), zap.Error(err))
+		return nil, fmt.Errorf("failed to store message: %w", err)
+	}
+
+	s.publishToMembers(ctx, msg.ConversationID, domain.Event{Type: domain.EventMessageNew, Payload: msg})
+
+	return msg, nil
+}
+
+func (s *chatService) GetConversations(ctx context.Context, userID int) ([]domain.ConversationSummary, error) {
+	conversations, err := s.repo.GetConversations(ctx, userID)
+	if err != nil {
+		s.logger.Error("Error getting conversations", zap.Int("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get conversations: %w", err)
+	}
+
+	return conversations, nil
+}
+
+func (s *chatService) GetMessages(ctx context.Context, userID int, conversationID int64, cursor string, limit int) (*domain.MessagePage, error) {
+	if limit <= 0 || limit > domain.MaxMessagePageSize {
+		limit = domain.DefaultMessagePageSize
+	}
+
+	// The cursor is the id of the oldest message of the previous page
+	var before int64
+	if cursor != "" {
+		id, err := strconv.ParseInt(cursor, 10, 64)
+		if err != nil || id <= 0 {
+			return nil, domain.ErrInvalidCursor
+		}
+		before = id
+	}
+
+	if _, err := s.member(ctx, conversationID, userID); err != nil {
+		return nil, err
+	}
+
+	// One extra message tells whether there is an older page
+	messages, err := s.repo.GetMessages(ctx, conversationID, before, limit+1)
+	if err != nil {
+		s.logger.Error("Error getting messages", zap.Int64("conversation_id", conversationID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get messages: %w", err)
+	}
+
+	page := &domain.MessagePage{Messages: messages}
+	if len(messages) > limit {
+		page.Messages = messages[:limit]
+		page.NextCursor = strconv.FormatInt(messages[limit-1].ID, 10)
+	}
+
+	return page, nil
+}
+
+func (s *chatService) MarkRead(ctx context.Context, userID int, conversationID int64, req *domain.MarkReadRequest) error {
+	member, err := s.member(ctx, conversationID, userID)
+	if err != nil {
+		return err
+	}
+	// Receipts for already read messages change nothing and are not broadcast
+	if req.MessageID <= member.LastReadMessageID {
+		return nil
+	}
+
+	readAt := time.Now()
+	if err := s.repo.MarkRead(ctx, conversationID, userID, req.MessageID, readAt); err != nil {
+		if errors.Is(err, domain.ErrMessageNotFound) {
+			return err
+		}
+		s.logger.Error("Error marking conversation read", zap.Int64("conversation_id", conversationID), zap.Error(err))
+		return fmt.Errorf("failed to mark conversation read: %w", err)
+	}
+
+	s.publishToMembers(ctx, conversationID, domain.Event{
+		Type: domain.EventReadReceipt,
+		Payload: domain.ReadReceipt{
+			ConversationID: conversationID,
+			UserID:         userID,
+			MessageID:      req.MessageID,
+			ReadAt:         readAt,
+		},
+	})
+	return nil
+}
+
+func (s *chatService) EditMessage(ctx context.Context, userID int, messageID int64, req *domain.EditMessageRequest) (*domain.Message, error) {
+	msg, _, err := s.message(ctx, userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.SenderID != userID {
+		return nil, domain.ErrNotMessageSender
+	}
+	if msg.DeletedAt != nil {
+		return nil, domain.ErrMessageDeleted
+	}
+	if time.Since(msg.CreatedAt) > s.cfg.EditWindow {
+		return nil, domain.ErrEditWindowClosed
+	}
+	if msg.Body == req.Body {
+		return msg, nil
+	}
+
+	editedAt := time.Now()
+	msg.Body = req.Body
+	msg.EditedAt = &editedAt
+	if err := s.repo.UpdateMessage(ctx, msg); err != nil {
+		if errors.Is(err, domain.ErrMessageDeleted) || errors.Is(err, domain.ErrMessageNotFound) {
+			return nil, err
+		}
+		s.logger.Error("Error editing message", zap.Int64("message_id", messageID), zap.Error(err))
+		return nil, fmt.Errorf("failed to edit message: %w", err)
+	}
+
+	s.logger.Info("Message edited", zap.Int64("message_id", messageID), zap.Int("user_id", userID))
+	s.publishToMembers(ctx, msg.ConversationID, domain.Event{Type: domain.EventMessageEdited, Payload: msg})
+
+	return msg, nil
+}
+
+func (s *chatService) DeleteMessage(ctx context.Context, userID int, messageID int64) error {
+	msg, member, err := s.message(ctx, userID, messageID)
+	if err != nil {
+		return err
+	}
+	// Deleting twice leaves the same tombstone
+	if msg.DeletedAt != nil {
+		return nil
+	}
+	if msg.SenderID != userID && domain.RoleRank(member.Role) < domain.RoleRank(domain.RoomRoleModerator) {
+		return domain.ErrMessageDeleteDenied
+	}
+
+	deletedAt := time.Now()
+	if err := s.repo.DeleteMessage(ctx, messageID, deletedAt); err != nil {
+		if errors.Is(err, domain.ErrMessageDeleted) {
+			return nil
+		}
+		s.logger.Error("Error deleting message", zap.Int64("message_id", messageID), zap.Error(err))
+		return fmt.Errorf("failed to delete message: %w", err)
+	}
+
+	s.logger.Info("Message deleted", zap.Int64("message_id", messageID), zap.Int("user_id", userID))
+	s.publishToMembers(ctx, msg.ConversationID, domain.Event{
+		Type: domain.EventMessageDeleted,
+		Payload: domain.MessageDeletedEvent{
+			ConversationID: msg.ConversationID,
+			MessageID:      messageID,
+			DeletedAt:      deletedAt,
+		},
+	})
+	return nil
+}
+
+// GetMessageEdits returns the previous bodies of a message, oldest first.
+// The history of a deleted message is kept for auditing but not returned.
+func (s *chatService) GetMessageEdits(ctx context.Context, userID int, messageID int64) ([]domain.MessageEdit, error) {
+	msg, _, err := s.message(ctx, userID, messageID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.DeletedAt != nil {
+		return []domain.MessageEdit{}, nil
+	}
+
+	edits, err := s.repo.GetMessageEdits(ctx, messageID)
+	if err != nil {
+		s.logger.Error("Error getting message edits", zap.Int64("message_id", messageID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get message edits: %w", err)
+	}
+
+	return edits, nil
+}
+
+func (s *chatService) AddReaction(ctx context.Context, userID int, messageID int64, req *domain.ReactionRequest) error {
+	msg, _, err := s.message(ctx, userID, messageID)
+	if err != nil {
+		return err
+	}
+	if msg.DeletedAt != nil {
+		return domain.ErrMessageDeleted
+	}
+
+	reaction := &domain.Reaction{
+		MessageID:      messageID,
+		ConversationID: msg.ConversationID,
+		UserID:         userID,
+		Emoji:          req.Emoji,
+	}
+	added, err := s.repo.AddReaction(ctx, reaction)
+	if err != nil {
+		s.logger.Error("Error adding reaction", zap.Int64("message_id", messageID), zap.Error(err))
+		return fmt.Errorf("failed to add reaction: %w", err)
+	}
+	// A user reacts with an emoji at most once, repeating it changes nothing
+	if !added {
+		return nil
+	}
+
+	s.publishToMembers(ctx, msg.ConversationID, domain.Event{Type: domain.EventReactionAdded, Payload: reaction})
+	return nil
+}
+
+func (s *chatService) RemoveReaction(ctx context.Context, userID int, messageID int64, emoji string) error {
+	msg, _, err := s.message(ctx, userID, messageID)
+	if err != nil {
+		return err
+	}
+
+	reaction := &domain.Reaction{
+		MessageID:      messageID,
+		ConversationID: msg.ConversationID,
+		UserID:         userID,
+		Emoji:          emoji,
+	}
+	if err := s.repo.RemoveReaction(ctx, reaction); err != nil {
+		if errors.Is(err, domain.ErrReactionNotFound) {
+			return err
+		}
+		s.logger.Error("Error removing reaction", zap.Int64("message_id", messageID), zap.Error(err))
+		return fmt.Errorf("failed to remove reaction: %w", err)
+	}
+
+	s.publishToMembers(ctx, msg.ConversationID, domain.Event{Type: domain.EventReactionRemoved, Payload: reaction})
+	return nil
+}
+
+// message returns the message and the membership of userID in its
+// conversation, messages of other conversations are reported as missing
+func (s *chatService) message(ctx context.Context, userID int, messageID int64) (*domain.Message, *domain.ConversationMember, error) {
+	msg, err := s.repo.GetMessage(ctx, messageID)
+	if err != nil {
+		s.logger.Error("Error getting message", zap.Int64("message_id", messageID), zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to get message: %w", err)
+	}
+	if msg == nil {
+		return nil, nil, domain.ErrMessageNotFound
+	}
+
+	member, err := s.member(ctx, msg.ConversationID, userID)
+	if errors.Is(err, domain.ErrConversationNotFound) {
+		return nil, nil, domain.ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return msg, member, nil
+}
+
+// member returns the membership of userID. Conversations of other users are
+// reported as missing, not as forbidden.
+func (s *chatService) member(ctx context.Context, conversationID int64, userID int) (*domain.ConversationMember, error) {
+	member, err := s.repo.GetMember(ctx, conversationID, userID)
+	if err != nil {
+		s.logger.Error("Error getting conversation member", zap.Int64("conversation_id", conversationID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get conversation member: %w", err)
+	}
+	if member == nil {
+		return nil, domain.ErrConversationNotFound
+	}
+
+	return member, nil
+}
@@ -0,0 +1,453 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Mock Chat Repository
+type MockChatRepository struct {
+	mock.Mock
+}
+
+func (m *MockChatRepository) CreateDirectMessage(ctx context.Context, recipientID int, msg *domain.Message) error {
+	args := m.Called(ctx, recipientID, msg)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) CreateMessage(ctx context.Context, msg *domain.Message) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) GetMember(ctx context.Context, conversationID int64, userID int) (*domain.ConversationMember, error) {
+	args := m.Called(ctx, conversationID, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.ConversationMember), args.Error(1)
+}
+
+func (m *MockChatRepository) GetMembers(ctx context.Context, conversationID int64) ([]domain.ConversationMember, error) {
+	args := m.Called(ctx, conversationID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ConversationMember), args.Error(1)
+}
+
+func (m *MockChatRepository) GetContactIDs(ctx context.Context, userID int) ([]int, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func (m *MockChatRepository) GetConversations(ctx context.Context, userID int) ([]domain.ConversationSummary, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.ConversationSummary), args.Error(1)
+}
+
+func (m *MockChatRepository) GetMessages(ctx context.Context, conversationID, before int64, limit int) ([]domain.Message, error) {
+	args := m.Called(ctx, conversationID, before, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Message), args.Error(1)
+}
+
+func (m *MockChatRepository) MarkRead(ctx context.Context, conversationID int64, userID int, messageID int64, readAt time.Time) error {
+	args := m.Called(ctx, conversationID, userID, messageID, readAt)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) GetMessage(ctx context.Context, id int64) (*domain.Message, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Message), args.Error(1)
+}
+
+func (m *MockChatRepository) UpdateMessage(ctx context.Context, msg *domain.Message) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) DeleteMessage(ctx context.Context, id int64, deletedAt time.Time) error {
+	args := m.Called(ctx, id, deletedAt)
+	return args.Error(0)
+}
+
+func (m *MockChatRepository) GetMessageEdits(ctx context.Context, messageID int64) ([]domain.MessageEdit, error) {
+	args := m.Called(ctx, messageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.MessageEdit), args.Error(1)
+}
+
+func (m *MockChatRepository) AddReaction(ctx context.Context, reaction *domain.Reaction) (bool, error) {
+	args := m.Called(ctx, reaction)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockChatRepository) RemoveReaction(ctx context.Context, reaction *domain.Reaction) error {
+	args := m.Called(ctx, reaction)
+	return args.Error(0)
+}
+
+// Mock Event Publisher
+type MockEventPublisher struct {
+	mock.Mock
+}
+
+func (m *MockEventPublisher) Publish(ctx context.Context, userIDs []int, event domain.Event) error {
+	args := m.Called(ctx, userIDs, event)
+	return args.Error(0)
+}
+
+func TestSendDirectMessage_Success(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	mockUsers := new(MockUserRepository)
+	mockPublisher := new(MockEventPublisher)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, mockUsers, mockPublisher, ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockUsers.On("GetByID", ctx, 2).Return(&domain.User{ID: 2}, nil)
+	mockRepo.On("CreateDirectMessage", ctx, 2, mock.AnythingOfType("*domain.Message")).
+		Run(func(args mock.Arguments) {
+			msg := args.Get(2).(*domain.Message)
+			msg.ID = 10
+			msg.ConversationID = 5
+		}).
+		Return(nil)
+	mockPublisher.On("Publish", ctx, []int{1, 2}, mock.MatchedBy(func(event domain.Event) bool {
+		return event.Type == domain.EventMessageNew && event.Payload.(*domain.Message).ID == 10
+	})).Return(nil)
+
+	msg, err := service.SendMessage(ctx, 1, &domain.SendMessageRequest{RecipientID: 2, Body: "hello"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), msg.ConversationID)
+	assert.Equal(t, 1, msg.SenderID)
+	mockRepo.AssertExpectations(t)
+	mockUsers.AssertExpectations(t)
+	mockPublisher.AssertExpectations(t)
+}
+
+func TestSendDirectMessage_ToSelf(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(new(MockChatRepository), new(MockUserRepository), new(MockEventPublisher), ChatConfig{}, logger)
+
+	msg, err := service.SendMessage(context.Background(), 1, &domain.SendMessageRequest{RecipientID: 1, Body: "hi"})
+
+	assert.ErrorIs(t, err, domain.ErrValidation)
+	assert.Nil(t, msg)
+}
+
+func TestSendDirectMessage_UnknownRecipient(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	mockUsers := new(MockUserRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, mockUsers, new(MockEventPublisher), ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockUsers.On("GetByID", ctx, 2).Return(nil, nil)
+
+	msg, err := service.SendMessage(ctx, 1, &domain.SendMessageRequest{RecipientID: 2, Body: "hello"})
+
+	assert.ErrorIs(t, err, domain.ErrRecipientNotFound)
+	assert.Nil(t, msg)
+	mockRepo.AssertNotCalled(t, "CreateDirectMessage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSendMessage_ToConversation(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	mockPublisher := new(MockEventPublisher)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), mockPublisher, ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil)
+	mockRepo.On("CreateMessage", ctx, mock.AnythingOfType("*domain.Message")).Return(nil)
+	mockRepo.On("GetMembers", ctx, int64(7)).Return([]domain.ConversationMember{{UserID: 1}, {UserID: 2}, {UserID: 3}}, nil)
+	mockPublisher.On("Publish", ctx, []int{1, 2, 3}, mock.AnythingOfType("domain.Event")).Return(nil)
+
+	msg, err := service.SendMessage(ctx, 1, &domain.SendMessageRequest{ConversationID: 7, Body: "hello"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, int64(7), msg.ConversationID)
+	mockRepo.AssertExpectations(t)
+	mockPublisher.AssertExpectations(t)
+}
+
+func TestSendMessage_NotAMember(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), new(MockEventPublisher), ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(nil, nil)
+
+	msg, err := service.SendMessage(ctx, 1, &domain.SendMessageRequest{ConversationID: 7, Body: "hello"})
+
+	assert.ErrorIs(t, err, domain.ErrConversationNotFound)
+	assert.Nil(t, msg)
+	mockRepo.AssertNotCalled(t, "CreateMessage", mock.Anything, mock.Anything)
+}
+
+func TestSendMessage_AmbiguousTarget(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(new(MockChatRepository), new(MockUserRepository), new(MockEventPublisher), ChatConfig{}, logger)
+
+	msg, err := service.SendMessage(context.Background(), 1, &domain.SendMessageRequest{ConversationID: 7, RecipientID: 2, Body: "hi"})
+
+	assert.ErrorIs(t, err, domain.ErrMessageTarget)
+	assert.Nil(t, msg)
+}
+
+func TestGetMessages_NextPage(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), new(MockEventPublisher), ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil)
+	mockRepo.On("GetMessages", ctx, int64(7), int64(100), 3).
+		Return([]domain.Message{{ID: 99}, {ID: 98}, {ID: 97}}, nil)
+
+	page, err := service.GetMessages(ctx, 1, 7, "100", 2)
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Messages, 2)
+	assert.Equal(t, "98", page.NextCursor)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestGetMessages_LastPage(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), new(MockEventPublisher), ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil)
+	mockRepo.On("GetMessages", ctx, int64(7), int64(0), domain.DefaultMessagePageSize+1).
+		Return([]domain.Message{{ID: 2}, {ID: 1}}, nil)
+
+	page, err := service.GetMessages(ctx, 1, 7, "", 0)
+
+	assert.NoError(t, err)
+	assert.Len(t, page.Messages, 2)
+	assert.Empty(t, page.NextCursor)
+}
+
+func TestGetMessages_InvalidCursor(t *testing.T) {
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(new(MockChatRepository), new(MockUserRepository), new(MockEventPublisher), ChatConfig{}, logger)
+
+	page, err := service.GetMessages(context.Background(), 1, 7, "abc", 10)
+
+	assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+	assert.Nil(t, page)
+}
+
+func TestMarkRead_BroadcastsReceipt(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	mockPublisher := new(MockEventPublisher)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), mockPublisher, ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMember", ctx, int64(7), 1).
+		Return(&domain.ConversationMember{ConversationID: 7, UserID: 1, LastReadMessageID: 10}, nil)
+	mockRepo.On("MarkRead", ctx, int64(7), 1, int64(12), mock.AnythingOfType("time.Time")).Return(nil)
+	mockRepo.On("GetMembers", ctx, int64(7)).Return([]domain.ConversationMember{{UserID: 1}, {UserID: 2}}, nil)
+	mockPublisher.On("Publish", ctx, []int{1, 2}, mock.MatchedBy(func(event domain.Event) bool {
+		receipt, ok := event.Payload.(domain.ReadReceipt)
+		return event.Type == domain.EventReadReceipt && ok && receipt.MessageID == 12
+	})).Return(nil)
+
+	err := service.MarkRead(ctx, 1, 7, &domain.MarkReadRequest{MessageID: 12})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockPublisher.AssertExpectations(t)
+}
+
+func TestMarkRead_AlreadyRead(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	mockPublisher := new(MockEventPublisher)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), mockPublisher, ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMember", ctx, int64(7), 1).
+		Return(&domain.ConversationMember{ConversationID: 7, UserID: 1, LastReadMessageID: 10}, nil)
+
+	err := service.MarkRead(ctx, 1, 7, &domain.MarkReadRequest{MessageID: 9})
+
+	assert.NoError(t, err)
+	mockRepo.AssertNotCalled(t, "MarkRead", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	mockPublisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestEditMessage_Success(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	mockPublisher := new(MockEventPublisher)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), mockPublisher, ChatConfig{EditWindow: time.Minute}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMessage", ctx, int64(5)).
+		Return(&domain.Message{ID: 5, ConversationID: 7, SenderID: 1, Body: "helo", CreatedAt: time.Now()}, nil)
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil)
+	mockRepo.On("UpdateMessage", ctx, mock.AnythingOfType("*domain.Message")).Return(nil)
+	mockRepo.On("GetMembers", ctx, int64(7)).Return([]domain.ConversationMember{{UserID: 1}, {UserID: 2}}, nil)
+	mockPublisher.On("Publish", ctx, []int{1, 2}, mock.MatchedBy(func(event domain.Event) bool {
+		return event.Type == domain.EventMessageEdited
+	})).Return(nil)
+
+	msg, err := service.EditMessage(ctx, 1, 5, &domain.EditMessageRequest{Body: "hello"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", msg.Body)
+	assert.NotNil(t, msg.EditedAt)
+	mockRepo.AssertExpectations(t)
+	mockPublisher.AssertExpectations(t)
+}
+
+func TestEditMessage_NotSender(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), new(MockEventPublisher), ChatConfig{EditWindow: time.Minute}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMessage", ctx, int64(5)).
+		Return(&domain.Message{ID: 5, ConversationID: 7, SenderID: 2, Body: "hi", CreatedAt: time.Now()}, nil)
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil)
+
+	msg, err := service.EditMessage(ctx, 1, 5, &domain.EditMessageRequest{Body: "hello"})
+
+	assert.ErrorIs(t, err, domain.ErrNotMessageSender)
+	assert.Nil(t, msg)
+	mockRepo.AssertNotCalled(t, "UpdateMessage", mock.Anything, mock.Anything)
+}
+
+func TestEditMessage_WindowClosed(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), new(MockEventPublisher), ChatConfig{EditWindow: time.Minute}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMessage", ctx, int64(5)).
+		Return(&domain.Message{ID: 5, ConversationID: 7, SenderID: 1, Body: "hi", CreatedAt: time.Now().Add(-time.Hour)}, nil)
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil)
+
+	msg, err := service.EditMessage(ctx, 1, 5, &domain.EditMessageRequest{Body: "hello"})
+
+	assert.ErrorIs(t, err, domain.ErrEditWindowClosed)
+	assert.Nil(t, msg)
+	mockRepo.AssertNotCalled(t, "UpdateMessage", mock.Anything, mock.Anything)
+}
+
+func TestEditMessage_OtherConversation(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), new(MockEventPublisher), ChatConfig{EditWindow: time.Minute}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMessage", ctx, int64(5)).
+		Return(&domain.Message{ID: 5, ConversationID: 7, SenderID: 1, Body: "hi", CreatedAt: time.Now()}, nil)
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(nil, nil)
+
+	msg, err := service.EditMessage(ctx, 1, 5, &domain.EditMessageRequest{Body: "hello"})
+
+	assert.ErrorIs(t, err, domain.ErrMessageNotFound)
+	assert.Nil(t, msg)
+}
+
+func TestDeleteMessage_ByModerator(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	mockPublisher := new(MockEventPublisher)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), mockPublisher, ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMessage", ctx, int64(5)).Return(&domain.Message{ID: 5, ConversationID: 7, SenderID: 2}, nil)
+	mockRepo.On("GetMember", ctx, int64(7), 1).
+		Return(&domain.ConversationMember{ConversationID: 7, UserID: 1, Role: domain.RoomRoleModerator}, nil)
+	mockRepo.On("DeleteMessage", ctx, int64(5), mock.AnythingOfType("time.Time")).Return(nil)
+	mockRepo.On("GetMembers", ctx, int64(7)).Return([]domain.ConversationMember{{UserID: 1}, {UserID: 2}}, nil)
+	mockPublisher.On("Publish", ctx, []int{1, 2}, mock.MatchedBy(func(event domain.Event) bool {
+		deleted, ok := event.Payload.(domain.MessageDeletedEvent)
+		return event.Type == domain.EventMessageDeleted && ok && deleted.MessageID == 5
+	})).Return(nil)
+
+	err := service.DeleteMessage(ctx, 1, 5)
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockPublisher.AssertExpectations(t)
+}
+
+func TestDeleteMessage_Denied(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), new(MockEventPublisher), ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMessage", ctx, int64(5)).Return(&domain.Message{ID: 5, ConversationID: 7, SenderID: 2}, nil)
+	mockRepo.On("GetMember", ctx, int64(7), 1).
+		Return(&domain.ConversationMember{ConversationID: 7, UserID: 1, Role: domain.RoomRoleMember}, nil)
+
+	err := service.DeleteMessage(ctx, 1, 5)
+
+	assert.ErrorIs(t, err, domain.ErrMessageDeleteDenied)
+	mockRepo.AssertNotCalled(t, "DeleteMessage", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAddReaction_Duplicate(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	mockPublisher := new(MockEventPublisher)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), mockPublisher, ChatConfig{}, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetMessage", ctx, int64(5)).Return(&domain.Message{ID: 5, ConversationID: 7, SenderID: 2}, nil)
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil)
+	mockRepo.On("AddReaction", ctx, mock.AnythingOfType("*domain.Reaction")).Return(false, nil)
+
+	err := service.AddReaction(ctx, 1, 5, &domain.ReactionRequest{Emoji: "👍"})
+
+	assert.NoError(t, err)
+	mockPublisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAddReaction_DeletedMessage(t *testing.T) {
+	mockRepo := new(MockChatRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewChatService(mockRepo, new(MockUserRepository), new(MockEventPublisher), ChatConfig{}, logger)
+
+	ctx := context.Background()
+	deletedAt := time.Now()
+	mockRepo.On("GetMessage", ctx, int64(5)).
+		Return(&domain.Message{ID: 5, ConversationID: 7, SenderID: 2, DeletedAt: &deletedAt}, nil)
+	mockRepo.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil)
+
+	err := service.AddReaction(ctx, 1, 5, &domain.ReactionRequest{Emoji: "👍"})
+
+	assert.ErrorIs(t, err, domain.ErrMessageDeleted)
+	mockRepo.AssertNotCalled(t, "AddReaction", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"go.uber.org/zap"
+)
+
+type IdempotencyConfig struct {
+	// TTL is how long responses are replayed to retries
+	TTL time.Duration
+	// LockTimeout is how long a request may run before its key counts as
+	// abandoned, it must be longer than the server write timeout
+	LockTimeout time.Duration
+}
+
+type idempotencyService struct {
+	repo   domain.IdempotencyRepository
+	cfg    IdempotencyConfig
+	logger *zap.Logger
+}
+
+func NewIdempotencyService(repo domain.IdempotencyRepository, cfg IdempotencyConfig, logger *zap.Logger) domain.IdempotencyService {
+	return &idempotencyService{
+		repo:   repo,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, record *domain.IdempotencyRecord) (*domain.IdempotentResponse, error) {
+	held, claimed, err := s.repo.Claim(ctx, record, s.cfg.TTL, s.cfg.LockTimeout)
+	if err != nil {
+		s.logger.Error("Error claiming idempotency key", zap.Int("user_id", record.UserID), zap.Error(err))
+		return nil, fmt.Errorf("failed to claim idempotency key: %w", err)
+	}
+	if claimed {
+		return nil, nil
+	}
+
+	// A record vanishes when a request that held it failed and released it,
+	// the client may simply retry
+	if held == nil {
+		return nil, domain.ErrIdempotencyKeyInFlight
+	}
+	if held.Fingerprint != record.Fingerprint {
+		return nil, domain.ErrIdempotencyKeyReused
+	}
+	if held.Response == nil {
+		return nil, domain.ErrIdempotencyKeyInFlight
+	}
+	return held.Response, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, record *domain.IdempotencyRecord, response *domain.IdempotentResponse) error {
+	if err := s.repo.Complete(ctx, record, response); err != nil {
+		s.logger.Error("Error storing idempotent response", zap.Int("user_id", record.UserID), zap.Error(err))
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (s *idempotencyService) Release(ctx context.Context, record *domain.IdempotencyRecord) error {
+	if err := s.repo.Release(ctx, record); err != nil {
+		s.logger.Error("Error releasing idempotency key", zap.Int("user_id", record.UserID), zap.Error(err))
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *idempotencyService) Prune(ctx context.Context) error {
+	removed, err := s.repo.DeleteExpired(ctx)
+	if err != nil {
+		s.logger.Error("Error pruning idempotency keys", zap.Error(err))
+		return fmt.Errorf("failed to prune idempotency keys: %w", err)
+	}
+
+	if removed > 0 {
+		s.logger.Info("Idempotency keys pruned", zap.Int64("count", removed))
+	}
+	return nil
+}
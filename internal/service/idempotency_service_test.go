@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Mock Idempotency Repository
+type MockIdempotencyRepository struct {
+	mock.Mock
+}
+
+func (m *MockIdempotencyRepository) Claim(ctx context.Context, record *domain.IdempotencyRecord, ttl, lockTimeout time.Duration) (*domain.IdempotencyRecord, bool, error) {
+	args := m.Called(ctx, record, ttl, lockTimeout)
+	if args.Get(0) == nil {
+		return nil, args.Bool(1), args.Error(2)
+	}
+	return args.Get(0).(*domain.IdempotencyRecord), args.Bool(1), args.Error(2)
+}
+
+func (m *MockIdempotencyRepository) Complete(ctx context.Context, record *domain.IdempotencyRecord, response *domain.IdempotentResponse) error {
+	args := m.Called(ctx, record, response)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) Release(ctx context.Context, record *domain.IdempotencyRecord) error {
+	args := m.Called(ctx, record)
+	return args.Error(0)
+}
+
+func (m *MockIdempotencyRepository) DeleteExpired(ctx context.Context) (int64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func newTestIdempotencyService(repo domain.IdempotencyRepository) domain.IdempotencyService {
+	logger, _ := zap.NewDevelopment()
+	return NewIdempotencyService(repo, IdempotencyConfig{TTL: 24 * time.Hour, LockTimeout: time.Minute}, logger)
+}
+
+func TestBegin(t *testing.T) {
+	stored := &domain.IdempotentResponse{Status: 201, Body: []byte(`{"id":1}`)}
+	tests := []struct {
+		name    string
+		held    *domain.IdempotencyRecord
+		claimed bool
+		want    *domain.IdempotentResponse
+		err     error
+	}{
+		{"claimed", nil, true, nil, nil},
+		{"completed", &domain.IdempotencyRecord{Fingerprint: "a", Response: stored}, false, stored, nil},
+		{"in flight", &domain.IdempotencyRecord{Fingerprint: "a"}, false, nil, domain.ErrIdempotencyKeyInFlight},
+		{"released", nil, false, nil, domain.ErrIdempotencyKeyInFlight},
+		{"other request", &domain.IdempotencyRecord{Fingerprint: "b", Response: stored}, false, nil, domain.ErrIdempotencyKeyReused},
+		{"other request in flight", &domain.IdempotencyRecord{Fingerprint: "b"}, false, nil, domain.ErrIdempotencyKeyReused},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockIdempotencyRepository)
+			service := newTestIdempotencyService(mockRepo)
+
+			ctx := context.Background()
+			record := &domain.IdempotencyRecord{UserID: 1, Key: "key", Fingerprint: "a"}
+			mockRepo.On("Claim", ctx, record, 24*time.Hour, time.Minute).Return(tt.held, tt.claimed, nil)
+
+			response, err := service.Begin(ctx, record)
+
+			assert.ErrorIs(t, err, tt.err)
+			assert.Equal(t, tt.want, response)
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"go.uber.org/zap"
+)
+
+// notifier publishes chat events. The changes they describe are already
+// stored, so failures are only logged and clients catch up from history.
+type notifier struct {
+	chats     domain.ChatRepository
+	publisher domain.EventPublisher
+	logger    *zap.Logger
+}
+
+func (n *notifier) publish(ctx context.Context, userIDs []int, event domain.Event) {
+	if err := n.publisher.Publish(ctx, userIDs, event); err != nil {
+		n.logger.Warn("Failed to publish chat event", zap.String("type", event.Type), zap.Error(err))
+	}
+}
+
+// publishToMembers publishes to every member of the conversation and to extra users
+func (n *notifier) publishToMembers(ctx context.Context, conversationID int64, event domain.Event, extra ...int) {
+	members, err := n.chats.GetMembers(ctx, conversationID)
+	if err != nil {
+		n.logger.Warn("Failed to get conversation members", zap.Int64("conversation_id", conversationID), zap.Error(err))
+		return
+	}
+
+	n.publish(ctx, append(memberIDs(members), extra...), event)
+}
+
+// publishToOthers publishes to every member of the conversation except userID
+func (n *notifier) publishToOthers(ctx context.Context, conversationID int64, userID int, event domain.Event) {
+	members, err := n.chats.GetMembers(ctx, conversationID)
+	if err != nil {
+		n.logger.Warn("Failed to get conversation members", zap.Int64("conversation_id", conversationID), zap.Error(err))
+		return
+	}
+
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		if member.UserID != userID {
+			ids = append(ids, member.UserID)
+		}
+	}
+	if len(ids) > 0 {
+		n.publish(ctx, ids, event)
+	}
+}
+
+func memberIDs(members []domain.ConversationMember) []int {
+	ids := make([]int, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.UserID)
+	}
+	return ids
+}
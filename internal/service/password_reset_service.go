@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/token"
+	"github.com/DMaryanskiy/go-idk/pkg/mailer"
+	"go.uber.org/zap"
+)
+
+type PasswordResetConfig struct {
+	TokenTTL time.Duration
+	// URL is the frontend page the link in the email points to, the token
+	// is appended as the "token" query parameter
+	URL string
+}
+
+type passwordResetService struct {
+	users  domain.UserRepository
+	resets domain.PasswordResetRepository
+	hasher domain.PasswordHasher
+	mailer mailer.Mailer
+	cfg    PasswordResetConfig
+	logger *zap.Logger
+	// pending tracks reset emails still being sent in the background
+	pending sync.WaitGroup
+}
+
+func NewPasswordResetService(
+	users domain.UserRepository,
+	resets domain.PasswordResetRepository,
+	hasher domain.PasswordHasher,
+	mailer mailer.Mailer,
+	cfg PasswordResetConfig,
+	logger *zap.Logger,
+) domain.PasswordResetService {
+	return &passwordResetService{
+		users:  users,
+		resets: resets,
+		hasher: hasher,
+		mailer: mailer,
+		cfg:    cfg,
+		logger: logger,
+	}
+}
+
+// ForgotPassword only logs failures, the caller answers the same way for
+// known, unknown and failing addresses. The lookup, the token and the mail
+// happen off the request path, so the response time gives nothing away
+// either.
+func (s *passwordResetService) ForgotPassword(ctx context.Context, req *domain.ForgotPasswordRequest) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	s.pending.Add(1)
+	go func() {
+		defer s.pending.Done()
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+		defer cancel()
+		s.sendResetLink(ctx, email)
+	}()
+}
+
+func (s *passwordResetService) sendResetLink(ctx context.Context, email string) {
+	user, err := s.users.GetByEmail(ctx, email)
+	if err != nil {
+		s.logger.Error("Error getting user by email", zap.Error(err))
+		return
+	}
+	if user == nil {
+		return
+	}
+
+	plain, hash, err := token.NewOpaque()
+	if err != nil {
+		s.logger.Error("Error generating password reset token", zap.Error(err))
+		return
+	}
+
+	reset := &domain.PasswordResetToken{
+		UserID:    user.ID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(s.cfg.TokenTTL),
+	}
+	if err := s.resets.Create(ctx, reset); err != nil {
+		s.logger.Error("Error creating password reset token", zap.Int("user_id", user.ID), zap.Error(err))
+		return
+	}
+
+	msg := mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf(
+			"Hi %s,\n\nUse the link below to choose a new password. It expires in %s.\n\n%s\n\n"+
+				"If you did not ask for a password reset, you can ignore this email.\n",
+			user.Name, s.cfg.TokenTTL, s.resetLink(plain),
+		),
+	}
+	if err := s.mailer.Send(ctx, msg); err != nil {
+		s.logger.Error("Error sending password reset email", zap.Int("user_id", user.ID), zap.Error(err))
+		return
+	}
+
+	s.logger.Info("Password reset requested", zap.Int("user_id", user.ID))
+}
+
+func (s *passwordResetService) ResetPassword(ctx context.Context, req *domain.ResetPasswordRequest) error {
+	reset, err := s.resets.GetByHash(ctx, token.Hash(req.Token))
+	if err != nil {
+		s.logger.Error("Error getting password reset token", zap.Error(err))
+		return fmt.Errorf("failed to get password reset token: %w", err)
+	}
+	if reset == nil || reset.UsedAt != nil || time.Now().After(reset.ExpiresAt) {
+		return domain.ErrInvalidResetToken
+	}
+
+	hash, err := s.hasher.Hash(req.Password)
+	if err != nil {
+		s.logger.Error("Error hashing password", zap.Error(err))
+		return fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	if err := s.resets.ResetPassword(ctx, reset.ID, reset.UserID, hash); err != nil {
+		if errors.Is(err, domain.ErrResetTokenUsed) {
+			return domain.ErrInvalidResetToken
+		}
+		s.logger.Error("Error resetting password", zap.Int("user_id", reset.UserID), zap.Error(err))
+		return fmt.Errorf("failed to reset password: %w", err)
+	}
+
+	s.logger.Info("Password reset", zap.Int("user_id", reset.UserID))
+	return nil
+}
+
+func (s *passwordResetService) Wait(ctx context.Context) error {
+	return waitPending(ctx, &s.pending)
+}
+
+func (s *passwordResetService) resetLink(plain string) string {
+	link, err := url.Parse(s.cfg.URL)
+	if err != nil {
+		return s.cfg.URL + "?token=" + url.QueryEscape(plain)
+	}
+
+	query := link.Query()
+	query.Set("token", plain)
+	link.RawQuery = query.Encode()
+	return link.String()
+}
+
+// waitPending waits for pending until ctx ends
+func waitPending(ctx context.Context, pending *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
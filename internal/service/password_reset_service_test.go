@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/token"
+	"github.com/DMaryanskiy/go-idk/pkg/mailer"
+	"github.com/DMaryanskiy/go-idk/pkg/password"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Mock Password Reset Repository
+type MockPasswordResetRepository struct {
+	mock.Mock
+}
+
+func (m *MockPasswordResetRepository) Create(ctx context.Context, token *domain.PasswordResetToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *MockPasswordResetRepository) GetByHash(ctx context.Context, hash string) (*domain.PasswordResetToken, error) {
+	args := m.Called(ctx, hash)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.PasswordResetToken), args.Error(1)
+}
+
+func (m *MockPasswordResetRepository) ResetPassword(ctx context.Context, tokenID, userID int, passwordHash string) error {
+	args := m.Called(ctx, tokenID, userID, passwordHash)
+	return args.Error(0)
+}
+
+func newTestPasswordResetService(
+	users domain.UserRepository,
+	resets domain.PasswordResetRepository,
+	mail mailer.Mailer,
+) domain.PasswordResetService {
+	logger, _ := zap.NewDevelopment()
+	return NewPasswordResetService(
+		users,
+		resets,
+		password.NewHasher(bcrypt.MinCost),
+		mail,
+		PasswordResetConfig{TokenTTL: 30 * time.Minute, URL: "https://app.example.com/reset"},
+		logger,
+	)
+}
+
+func TestForgotPassword_SendsLink(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockResets := new(MockPasswordResetRepository)
+	mail := mailer.NewMemoryMailer()
+	service := newTestPasswordResetService(mockRepo, mockResets, mail)
+
+	mockRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockResets.On("Create", mock.Anything, mock.AnythingOfType("*domain.PasswordResetToken")).Return(nil)
+
+	service.ForgotPassword(context.Background(), &domain.ForgotPasswordRequest{Email: "test@example.com"})
+	assert.NoError(t, service.Wait(context.Background()))
+
+	messages := mail.Messages()
+	assert.Len(t, messages, 1)
+
+	link, err := url.Parse(regexp.MustCompile(`https://\S+`).FindString(messages[0].Body))
+	assert.NoError(t, err)
+	plain := link.Query().Get("token")
+	assert.NotEmpty(t, plain)
+
+	stored := mockResets.Calls[0].Arguments.Get(1).(*domain.PasswordResetToken)
+	assert.Equal(t, token.Hash(plain), stored.TokenHash)
+	mockResets.AssertExpectations(t)
+}
+
+func TestForgotPassword_UnknownEmail(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockResets := new(MockPasswordResetRepository)
+	mail := mailer.NewMemoryMailer()
+	service := newTestPasswordResetService(mockRepo, mockResets, mail)
+
+	mockRepo.On("GetByEmail", mock.Anything, "missing@example.com").Return(nil, nil)
+
+	service.ForgotPassword(context.Background(), &domain.ForgotPasswordRequest{Email: "missing@example.com"})
+	assert.NoError(t, service.Wait(context.Background()))
+
+	mockRepo.AssertExpectations(t)
+	assert.Empty(t, mail.Messages())
+	mockResets.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestForgotPassword_ReturnsBeforeLookup(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockResets := new(MockPasswordResetRepository)
+	mail := mailer.NewMemoryMailer()
+	service := newTestPasswordResetService(mockRepo, mockResets, mail)
+
+	release := make(chan time.Time)
+	mockRepo.On("GetByEmail", mock.Anything, "test@example.com").WaitUntil(release).Return(nil, nil)
+
+	// Returning while the lookup is still blocked shows the request does not
+	// wait for it
+	service.ForgotPassword(context.Background(), &domain.ForgotPasswordRequest{Email: "test@example.com"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, service.Wait(ctx), context.DeadlineExceeded)
+
+	close(release)
+	assert.NoError(t, service.Wait(context.Background()))
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestResetPassword_Success(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockResets := new(MockPasswordResetRepository)
+	service := newTestPasswordResetService(mockRepo, mockResets, mailer.NewMemoryMailer())
+
+	ctx := context.Background()
+	mockResets.On("GetByHash", ctx, token.Hash("reset-token")).Return(&domain.PasswordResetToken{
+		ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	mockResets.On("ResetPassword", ctx, 3, 1, mock.MatchedBy(func(hash string) bool {
+		return bcrypt.CompareHashAndPassword([]byte(hash), []byte("NewSecret123")) == nil
+	})).Return(nil)
+
+	err := service.ResetPassword(ctx, &domain.ResetPasswordRequest{Token: "reset-token", Password: "NewSecret123"})
+
+	assert.NoError(t, err)
+	mockResets.AssertExpectations(t)
+}
+
+func TestResetPassword_UsedToken(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockResets := new(MockPasswordResetRepository)
+	service := newTestPasswordResetService(mockRepo, mockResets, mailer.NewMemoryMailer())
+
+	ctx := context.Background()
+	usedAt := time.Now().Add(-time.Minute)
+	mockResets.On("GetByHash", ctx, token.Hash("reset-token")).Return(&domain.PasswordResetToken{
+		ID: 3, UserID: 1, ExpiresAt: time.Now().Add(time.Minute), UsedAt: &usedAt,
+	}, nil)
+
+	err := service.ResetPassword(ctx, &domain.ResetPasswordRequest{Token: "reset-token", Password: "NewSecret123"})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid or expired reset token")
+	mockResets.AssertNotCalled(t, "ResetPassword", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,327 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+type PresenceConfig struct {
+	// Timeout is how long a connection counts as alive after its last
+	// heartbeat, it must be longer than the WebSocket ping interval
+	Timeout time.Duration
+	// TypingTTL is how long a typing indicator lasts without being refreshed
+	TypingTTL time.Duration
+	// TypingInterval is the minimum time between two typing.started events
+	// of a user in a conversation
+	TypingInterval time.Duration
+}
+
+type typingKey struct {
+	userID         int
+	conversationID int64
+}
+
+type typingState struct {
+	broadcastAt time.Time
+	expiresAt   time.Time
+	timer       *time.Timer
+}
+
+type presenceService struct {
+	notifier
+	repo   domain.PresenceRepository
+	chats  domain.ChatRepository
+	cfg    PresenceConfig
+	mu     sync.Mutex
+	typing map[typingKey]*typingState
+	logger *zap.Logger
+}
+
+func NewPresenceService(
+	repo domain.PresenceRepository,
+	chats domain.ChatRepository,
+	publisher domain.EventPublisher,
+	cfg PresenceConfig,
+	logger *zap.Logger,
+) domain.PresenceService {
+	return &presenceService{
+		notifier: notifier{chats: chats, publisher: publisher, logger: logger},
+		repo:     repo,
+		chats:    chats,
+		cfg:      cfg,
+		typing:   make(map[typingKey]*typingState),
+		logger:   logger,
+	}
+}
+
+func (s *presenceService) Connect(ctx context.Context, userID int) (string, error) {
+	conn := &domain.PresenceConnection{
+		ID:         uuid.NewString(),
+		UserID:     userID,
+		Status:     domain.PresenceOnline,
+		LastSeenAt: time.Now(),
+	}
+
+	err := s.track(ctx, userID, func() error {
+		return s.repo.Create(ctx, conn)
+	})
+	if err != nil {
+		s.logger.Error("Error creating presence connection", zap.Int("user_id", userID), zap.Error(err))
+		return "", fmt.Errorf("failed to create presence connection: %w", err)
+	}
+
+	return conn.ID, nil
+}
+
+func (s *presenceService) Heartbeat(ctx context.Context, userID int, connectionID string) error {
+	now := time.Now()
+	previous, err := s.repo.Heartbeat(ctx, &domain.PresenceConnection{
+		ID:         connectionID,
+		UserID:     userID,
+		Status:     domain.PresenceOnline,
+		LastSeenAt: now,
+	})
+	if err != nil {
+		s.logger.Error("Error refreshing presence connection", zap.Int("user_id", userID), zap.Error(err))
+		return fmt.Errorf("failed to refresh presence connection: %w", err)
+	}
+
+	// The connection was swept or counted as dead, the user may have been
+	// reported offline in the meantime. Other heartbeats change nothing.
+	if previous == nil || !previous.After(now.Add(-s.cfg.Timeout)) {
+		s.announce(ctx, userID)
+	}
+	return nil
+}
+
+func (s *presenceService) SetStatus(ctx context.Context, userID int, connectionID, status string) error {
+	err := s.track(ctx, userID, func() error {
+		return s.repo.SetStatus(ctx, connectionID, status, time.Now())
+	})
+	if err != nil {
+		s.logger.Error("Error setting presence status", zap.Int("user_id", userID), zap.Error(err))
+		return fmt.Errorf("failed to set presence status: %w", err)
+	}
+
+	return nil
+}
+
+func (s *presenceService) Disconnect(ctx context.Context, userID int, connectionID string) error {
+	err := s.track(ctx, userID, func() error {
+		return s.repo.Delete(ctx, connectionID)
+	})
+	if err != nil {
+		s.logger.Error("Error deleting presence connection", zap.Int("user_id", userID), zap.Error(err))
+		return fmt.Errorf("failed to delete presence connection: %w", err)
+	}
+
+	return nil
+}
+
+func (s *presenceService) GetContacts(ctx context.Context, userID int) ([]domain.Presence, error) {
+	contacts, err := s.chats.GetContactIDs(ctx, userID)
+	if err != nil {
+		s.logger.Error("Error getting contacts", zap.Int("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get contacts: %w", err)
+	}
+
+	statuses, err := s.repo.GetStatuses(ctx, contacts, s.since())
+	if err != nil {
+		s.logger.Error("Error getting presence", zap.Int("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get presence: %w", err)
+	}
+
+	presence := make([]domain.Presence, 0, len(contacts))
+	for _, id := range contacts {
+		presence = append(presence, domain.Presence{UserID: id, Status: statusOf(statuses, id)})
+	}
+	return presence, nil
+}
+
+func (s *presenceService) Sweep(ctx context.Context) error {
+	userIDs, err := s.repo.DeleteStale(ctx, s.since())
+	if err != nil {
+		s.logger.Error("Error sweeping presence connections", zap.Error(err))
+		return fmt.Errorf("failed to sweep presence connections: %w", err)
+	}
+
+	seen := make(map[int]bool, len(userIDs))
+	for _, userID := range userIDs {
+		if seen[userID] {
+			continue
+		}
+		seen[userID] = true
+		s.announce(ctx, userID)
+	}
+	return nil
+}
+
+func (s *presenceService) StartTyping(ctx context.Context, userID int, conversationID int64) error {
+	key := typingKey{userID: userID, conversationID: conversationID}
+	now := time.Now()
+
+	// Refreshes within the interval only extend the indicator, they are not broadcast
+	s.mu.Lock()
+	state := s.typing[key]
+	if state != nil && now.Sub(state.broadcastAt) < s.cfg.TypingInterval {
+		state.expiresAt = now.Add(s.cfg.TypingTTL)
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	if state == nil {
+		if err := s.requireMember(ctx, conversationID, userID); err != nil {
+			return err
+		}
+	}
+
+	expiresAt := now.Add(s.cfg.TypingTTL)
+	s.mu.Lock()
+	state = s.typing[key]
+	if state == nil {
+		created := &typingState{}
+		created.timer = time.AfterFunc(s.cfg.TypingTTL, func() { s.expireTyping(key, created) })
+		s.typing[key] = created
+		state = created
+	}
+	state.broadcastAt = now
+	state.expiresAt = expiresAt
+	s.mu.Unlock()
+
+	s.publishToOthers(ctx, conversationID, userID, domain.Event{
+		Type:    domain.EventTypingStarted,
+		Payload: domain.TypingEvent{ConversationID: conversationID, UserID: userID, ExpiresAt: &expiresAt},
+	})
+	return nil
+}
+
+func (s *presenceService) StopTyping(ctx context.Context, userID int, conversationID int64) error {
+	key := typingKey{userID: userID, conversationID: conversationID}
+
+	s.mu.Lock()
+	state := s.typing[key]
+	if state != nil {
+		state.timer.Stop()
+		delete(s.typing, key)
+	}
+	s.mu.Unlock()
+
+	// Nothing to stop, the indicator already expired or was started elsewhere
+	if state == nil {
+		return nil
+	}
+
+	s.publishToOthers(ctx, conversationID, userID, domain.Event{
+		Type:    domain.EventTypingStopped,
+		Payload: domain.TypingEvent{ConversationID: conversationID, UserID: userID},
+	})
+	return nil
+}
+
+// expireTyping runs when the timer of state fires, refreshes move expiresAt
+// forward instead of resetting the timer, so it is rescheduled here
+func (s *presenceService) expireTyping(key typingKey, state *typingState) {
+	s.mu.Lock()
+	if s.typing[key] != state {
+		s.mu.Unlock()
+		return
+	}
+	if remaining := time.Until(state.expiresAt); remaining > 0 {
+		state.timer.Reset(remaining)
+		s.mu.Unlock()
+		return
+	}
+	delete(s.typing, key)
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s.publishToOthers(ctx, key.conversationID, key.userID, domain.Event{
+		Type:    domain.EventTypingStopped,
+		Payload: domain.TypingEvent{ConversationID: key.conversationID, UserID: key.userID},
+	})
+}
+
+// track runs change and announces the status of the user if it changed
+func (s *presenceService) track(ctx context.Context, userID int, change func() error) error {
+	before, err := s.status(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	after, err := s.status(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if after != before {
+		s.publishPresence(ctx, domain.Presence{UserID: userID, Status: after})
+	}
+	return nil
+}
+
+// announce publishes the current status of the user
+func (s *presenceService) announce(ctx context.Context, userID int) {
+	status, err := s.status(ctx, userID)
+	if err != nil {
+		s.logger.Warn("Failed to get presence", zap.Int("user_id", userID), zap.Error(err))
+		return
+	}
+
+	s.publishPresence(ctx, domain.Presence{UserID: userID, Status: status})
+}
+
+// publishPresence sends the presence to the user's contacts and their own
+// other devices, nobody else learns about it
+func (s *presenceService) publishPresence(ctx context.Context, presence domain.Presence) {
+	contacts, err := s.chats.GetContactIDs(ctx, presence.UserID)
+	if err != nil {
+		s.logger.Warn("Failed to get contacts", zap.Int("user_id", presence.UserID), zap.Error(err))
+		return
+	}
+
+	s.publish(ctx, append(contacts, presence.UserID), domain.Event{Type: domain.EventPresenceChanged, Payload: presence})
+}
+
+func (s *presenceService) status(ctx context.Context, userID int) (string, error) {
+	statuses, err := s.repo.GetStatuses(ctx, []int{userID}, s.since())
+	if err != nil {
+		return "", err
+	}
+	return statusOf(statuses, userID), nil
+}
+
+func (s *presenceService) requireMember(ctx context.Context, conversationID int64, userID int) error {
+	member, err := s.chats.GetMember(ctx, conversationID, userID)
+	if err != nil {
+		s.logger.Error("Error getting conversation member", zap.Int64("conversation_id", conversationID), zap.Error(err))
+		return fmt.Errorf("failed to get conversation member: %w", err)
+	}
+	if member == nil {
+		return domain.ErrConversationNotFound
+	}
+	return nil
+}
+
+// since is the oldest heartbeat of a connection that is still alive
+func (s *presenceService) since() time.Time {
+	return time.Now().Add(-s.cfg.Timeout)
+}
+
+func statusOf(statuses map[int]string, userID int) string {
+	if status, ok := statuses[userID]; ok {
+		return status
+	}
+	return domain.PresenceOffline
+}
@@ -0,0 +1,191 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Mock Presence Repository
+type MockPresenceRepository struct {
+	mock.Mock
+}
+
+func (m *MockPresenceRepository) Create(ctx context.Context, conn *domain.PresenceConnection) error {
+	args := m.Called(ctx, conn)
+	return args.Error(0)
+}
+
+func (m *MockPresenceRepository) Heartbeat(ctx context.Context, conn *domain.PresenceConnection) (*time.Time, error) {
+	args := m.Called(ctx, conn)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*time.Time), args.Error(1)
+}
+
+func (m *MockPresenceRepository) SetStatus(ctx context.Context, id, status string, seenAt time.Time) error {
+	args := m.Called(ctx, id, status, seenAt)
+	return args.Error(0)
+}
+
+func (m *MockPresenceRepository) Delete(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPresenceRepository) GetStatuses(ctx context.Context, userIDs []int, since time.Time) (map[int]string, error) {
+	args := m.Called(ctx, userIDs, since)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(map[int]string), args.Error(1)
+}
+
+func (m *MockPresenceRepository) DeleteStale(ctx context.Context, before time.Time) ([]int, error) {
+	args := m.Called(ctx, before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]int), args.Error(1)
+}
+
+func newTestPresenceService(cfg PresenceConfig) (domain.PresenceService, *MockPresenceRepository, *MockChatRepository, *MockEventPublisher) {
+	repo := new(MockPresenceRepository)
+	chats := new(MockChatRepository)
+	publisher := new(MockEventPublisher)
+	logger, _ := zap.NewDevelopment()
+	return NewPresenceService(repo, chats, publisher, cfg, logger), repo, chats, publisher
+}
+
+func presenceEvent(status string) any {
+	return mock.MatchedBy(func(event domain.Event) bool {
+		presence, ok := event.Payload.(domain.Presence)
+		return event.Type == domain.EventPresenceChanged && ok && presence.Status == status
+	})
+}
+
+func TestConnect_AnnouncesOnlineToContacts(t *testing.T) {
+	service, repo, chats, publisher := newTestPresenceService(PresenceConfig{Timeout: time.Minute})
+
+	ctx := context.Background()
+	repo.On("GetStatuses", ctx, []int{1}, mock.AnythingOfType("time.Time")).Return(map[int]string{}, nil).Once()
+	repo.On("Create", ctx, mock.AnythingOfType("*domain.PresenceConnection")).Return(nil)
+	repo.On("GetStatuses", ctx, []int{1}, mock.AnythingOfType("time.Time")).
+		Return(map[int]string{1: domain.PresenceOnline}, nil).Once()
+	chats.On("GetContactIDs", ctx, 1).Return([]int{2, 3}, nil)
+	publisher.On("Publish", ctx, []int{2, 3, 1}, presenceEvent(domain.PresenceOnline)).Return(nil)
+
+	id, err := service.Connect(ctx, 1)
+
+	assert.NoError(t, err)
+	assert.NotEmpty(t, id)
+	repo.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestDisconnect_OtherDeviceStillOnline(t *testing.T) {
+	service, repo, _, publisher := newTestPresenceService(PresenceConfig{Timeout: time.Minute})
+
+	ctx := context.Background()
+	repo.On("GetStatuses", ctx, []int{1}, mock.AnythingOfType("time.Time")).
+		Return(map[int]string{1: domain.PresenceOnline}, nil)
+	repo.On("Delete", ctx, "conn-1").Return(nil)
+
+	err := service.Disconnect(ctx, 1, "conn-1")
+
+	assert.NoError(t, err)
+	repo.AssertExpectations(t)
+	publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestHeartbeat_FreshConnectionIsQuiet(t *testing.T) {
+	service, repo, _, publisher := newTestPresenceService(PresenceConfig{Timeout: time.Minute})
+
+	ctx := context.Background()
+	previous := time.Now().Add(-20 * time.Second)
+	repo.On("Heartbeat", ctx, mock.AnythingOfType("*domain.PresenceConnection")).Return(&previous, nil)
+
+	err := service.Heartbeat(ctx, 1, "conn-1")
+
+	assert.NoError(t, err)
+	publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestSweep_AnnouncesOffline(t *testing.T) {
+	service, repo, chats, publisher := newTestPresenceService(PresenceConfig{Timeout: time.Minute})
+
+	ctx := context.Background()
+	repo.On("DeleteStale", ctx, mock.AnythingOfType("time.Time")).Return([]int{4, 4}, nil)
+	repo.On("GetStatuses", ctx, []int{4}, mock.AnythingOfType("time.Time")).Return(map[int]string{}, nil)
+	chats.On("GetContactIDs", ctx, 4).Return([]int{2}, nil)
+	publisher.On("Publish", ctx, []int{2, 4}, presenceEvent(domain.PresenceOffline)).Return(nil).Once()
+
+	err := service.Sweep(ctx)
+
+	assert.NoError(t, err)
+	publisher.AssertExpectations(t)
+}
+
+func TestStartTyping_RateLimited(t *testing.T) {
+	service, _, chats, publisher := newTestPresenceService(PresenceConfig{
+		TypingTTL:      time.Minute,
+		TypingInterval: time.Minute,
+	})
+
+	ctx := context.Background()
+	chats.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil).Once()
+	chats.On("GetMembers", ctx, int64(7)).Return([]domain.ConversationMember{{UserID: 1}, {UserID: 2}}, nil)
+	publisher.On("Publish", ctx, []int{2}, mock.MatchedBy(func(event domain.Event) bool {
+		return event.Type == domain.EventTypingStarted
+	})).Return(nil).Once()
+
+	assert.NoError(t, service.StartTyping(ctx, 1, 7))
+	assert.NoError(t, service.StartTyping(ctx, 1, 7))
+
+	chats.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestStartTyping_Expires(t *testing.T) {
+	service, _, chats, publisher := newTestPresenceService(PresenceConfig{
+		TypingTTL:      20 * time.Millisecond,
+		TypingInterval: time.Millisecond,
+	})
+
+	ctx := context.Background()
+	stopped := make(chan struct{})
+	chats.On("GetMember", ctx, int64(7), 1).Return(&domain.ConversationMember{ConversationID: 7, UserID: 1}, nil)
+	chats.On("GetMembers", mock.Anything, int64(7)).Return([]domain.ConversationMember{{UserID: 1}, {UserID: 2}}, nil)
+	publisher.On("Publish", ctx, []int{2}, mock.MatchedBy(func(event domain.Event) bool {
+		return event.Type == domain.EventTypingStarted
+	})).Return(nil)
+	publisher.On("Publish", mock.Anything, []int{2}, mock.MatchedBy(func(event domain.Event) bool {
+		return event.Type == domain.EventTypingStopped
+	})).Run(func(mock.Arguments) { close(stopped) }).Return(nil).Once()
+
+	assert.NoError(t, service.StartTyping(ctx, 1, 7))
+
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("typing indicator did not expire")
+	}
+}
+
+func TestStartTyping_NotAMember(t *testing.T) {
+	service, _, chats, publisher := newTestPresenceService(PresenceConfig{TypingTTL: time.Minute})
+
+	ctx := context.Background()
+	chats.On("GetMember", ctx, int64(7), 1).Return(nil, nil)
+
+	err := service.StartTyping(ctx, 1, 7)
+
+	assert.ErrorIs(t, err, domain.ErrConversationNotFound)
+	publisher.AssertNotCalled(t, "Publish", mock.Anything, mock.Anything, mock.Anything)
+}
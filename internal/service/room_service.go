@@ -0,0 +1,363 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"go.uber.org/zap"
+)
+
+type roomService struct {
+	notifier
+	rooms  domain.RoomRepository
+	chats  domain.ChatRepository
+	logger *zap.Logger
+}
+
+func NewRoomService(
+	rooms domain.RoomRepository,
+	chats domain.ChatRepository,
+	publisher domain.EventPublisher,
+	logger *zap.Logger,
+) domain.RoomService {
+	return &roomService{
+		notifier: notifier{chats: chats, publisher: publisher, logger: logger},
+		rooms:    rooms,
+		chats:    chats,
+		logger:   logger,
+	}
+}
+
+func (s *roomService) CreateRoom(ctx context.Context, userID int, req *domain.CreateRoomRequest) (*domain.Room, error) {
+	room := &domain.Room{
+		Name:       strings.TrimSpace(req.Name),
+		Topic:      strings.TrimSpace(req.Topic),
+		Visibility: req.Visibility,
+		CreatedBy:  userID,
+	}
+
+	if err := s.rooms.Create(ctx, room); err != nil {
+		s.logger.Error("Error creating room", zap.Int("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to create room: %w", err)
+	}
+
+	s.logger.Info("Room created", zap.Int64("room_id", room.ID), zap.Int("user_id", userID))
+	return room, nil
+}
+
+func (s *roomService) GetRoom(ctx context.Context, userID int, roomID int64) (*domain.Room, error) {
+	room, _, err := s.load(ctx, userID, roomID)
+	return room, err
+}
+
+func (s *roomService) GetRooms(ctx context.Context, userID int) ([]domain.Room, error) {
+	rooms, err := s.rooms.GetVisible(ctx, userID)
+	if err != nil {
+		s.logger.Error("Error getting rooms", zap.Int("user_id", userID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get rooms: %w", err)
+	}
+
+	return rooms, nil
+}
+
+func (s *roomService) GetMembers(ctx context.Context, userID int, roomID int64) ([]domain.ConversationMember, error) {
+	room, member, err := s.load(ctx, userID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if member == nil && room.Visibility == domain.RoomPrivate {
+		return nil, domain.ErrNotRoomMember
+	}
+
+	members, err := s.chats.GetMembers(ctx, roomID)
+	if err != nil {
+		s.logger.Error("Error getting room members", zap.Int64("room_id", roomID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get room members: %w", err)
+	}
+
+	return members, nil
+}
+
+func (s *roomService) Invite(ctx context.Context, actorID int, roomID int64, userID int) error {
+	room, actor, err := s.load(ctx, actorID, roomID)
+	if err != nil {
+		return err
+	}
+	if actor == nil {
+		return domain.ErrNotRoomMember
+	}
+	// Anybody may join a public room anyway, private rooms are curated by moderators
+	if room.Visibility == domain.RoomPrivate && domain.RoleRank(actor.Role) < domain.RoleRank(domain.RoomRoleModerator) {
+		return domain.ErrRoomRoleTooLow
+	}
+
+	if err := s.checkJoinable(ctx, roomID, userID); err != nil {
+		return err
+	}
+
+	if err := s.rooms.CreateInvite(ctx, roomID, userID, actorID); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
+		s.logger.Error("Error creating room invite", zap.Int64("room_id", roomID), zap.Error(err))
+		return fmt.Errorf("failed to create room invite: %w", err)
+	}
+
+	s.publish(ctx, []int{userID}, domain.Event{
+		Type:    domain.EventRoomInvited,
+		Payload: domain.RoomMemberEvent{RoomID: roomID, UserID: userID, ActorID: actorID},
+	})
+	return nil
+}
+
+// Join works for public rooms and for private rooms the user is invited to,
+// load hides other private rooms
+func (s *roomService) Join(ctx context.Context, userID int, roomID int64) error {
+	if _, _, err := s.load(ctx, userID, roomID); err != nil {
+		return err
+	}
+	if err := s.checkJoinable(ctx, roomID, userID); err != nil {
+		return err
+	}
+
+	if err := s.rooms.AddMember(ctx, roomID, userID, domain.RoomRoleMember); err != nil {
+		if errors.Is(err, domain.ErrAlreadyRoomMember) {
+			return err
+		}
+		s.logger.Error("Error adding room member", zap.Int64("room_id", roomID), zap.Error(err))
+		return fmt.Errorf("failed to join room: %w", err)
+	}
+
+	s.logger.Info("Room joined", zap.Int64("room_id", roomID), zap.Int("user_id", userID))
+	s.publishToMembers(ctx, roomID, domain.Event{
+		Type:    domain.EventRoomJoined,
+		Payload: domain.RoomMemberEvent{RoomID: roomID, UserID: userID, Role: domain.RoomRoleMember},
+	})
+	return nil
+}
+
+func (s *roomService) Leave(ctx context.Context, userID int, roomID int64) error {
+	_, member, err := s.load(ctx, userID, roomID)
+	if err != nil {
+		return err
+	}
+	if member == nil {
+		return domain.ErrNotRoomMember
+	}
+
+	if member.Role == domain.RoomRoleOwner {
+		owners, err := s.countOwners(ctx, roomID)
+		if err != nil {
+			return err
+		}
+		if owners == 1 {
+			return domain.ErrLastRoomOwner
+		}
+	}
+
+	if err := s.rooms.RemoveMember(ctx, roomID, userID); err != nil {
+		return s.membershipError("Error leaving room", roomID, err)
+	}
+
+	s.logger.Info("Room left", zap.Int64("room_id", roomID), zap.Int("user_id", userID))
+	s.publishToMembers(ctx, roomID, domain.Event{
+		Type:    domain.EventRoomLeft,
+		Payload: domain.RoomMemberEvent{RoomID: roomID, UserID: userID},
+	}, userID)
+	return nil
+}
+
+func (s *roomService) Kick(ctx context.Context, actorID int, roomID int64, userID int) error {
+	target, err := s.moderate(ctx, actorID, roomID, userID)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return domain.ErrRoomMemberNotFound
+	}
+
+	if err := s.rooms.RemoveMember(ctx, roomID, userID); err != nil {
+		return s.membershipError("Error kicking room member", roomID, err)
+	}
+
+	s.logger.Info("Room member kicked", zap.Int64("room_id", roomID), zap.Int("user_id", userID), zap.Int("actor_id", actorID))
+	s.publishToMembers(ctx, roomID, domain.Event{
+		Type:    domain.EventRoomKicked,
+		Payload: domain.RoomMemberEvent{RoomID: roomID, UserID: userID, ActorID: actorID},
+	}, userID)
+	return nil
+}
+
+// Ban also works for users who are not members yet
+func (s *roomService) Ban(ctx context.Context, actorID int, roomID int64, userID int) error {
+	if _, err := s.moderate(ctx, actorID, roomID, userID); err != nil {
+		return err
+	}
+
+	if err := s.rooms.Ban(ctx, roomID, userID, actorID); err != nil {
+		return s.membershipError("Error banning room member", roomID, err)
+	}
+
+	s.logger.Info("Room member banned", zap.Int64("room_id", roomID), zap.Int("user_id", userID), zap.Int("actor_id", actorID))
+	s.publishToMembers(ctx, roomID, domain.Event{
+		Type:    domain.EventRoomBanned,
+		Payload: domain.RoomMemberEvent{RoomID: roomID, UserID: userID, ActorID: actorID},
+	}, userID)
+	return nil
+}
+
+func (s *roomService) Unban(ctx context.Context, actorID int, roomID int64, userID int) error {
+	if _, err := s.requireRole(ctx, actorID, roomID, domain.RoomRoleModerator); err != nil {
+		return err
+	}
+
+	if err := s.rooms.Unban(ctx, roomID, userID); err != nil {
+		return s.membershipError("Error unbanning user", roomID, err)
+	}
+
+	s.logger.Info("Room ban lifted", zap.Int64("room_id", roomID), zap.Int("user_id", userID), zap.Int("actor_id", actorID))
+	return nil
+}
+
+func (s *roomService) SetRole(ctx context.Context, actorID int, roomID int64, userID int, role string) error {
+	if actorID == userID {
+		return domain.ErrOwnRoomRole
+	}
+	if _, err := s.requireRole(ctx, actorID, roomID, domain.RoomRoleOwner); err != nil {
+		return err
+	}
+
+	if err := s.rooms.SetMemberRole(ctx, roomID, userID, role); err != nil {
+		return s.membershipError("Error setting room member role", roomID, err)
+	}
+
+	s.logger.Info("Room member role changed", zap.Int64("room_id", roomID), zap.Int("user_id", userID), zap.String("role", role))
+	s.publishToMembers(ctx, roomID, domain.Event{
+		Type:    domain.EventRoomRoleChanged,
+		Payload: domain.RoomMemberEvent{RoomID: roomID, UserID: userID, ActorID: actorID, Role: role},
+	})
+	return nil
+}
+
+// load returns the room and the membership of userID, which is nil for
+// non-members. Private rooms are reported as missing to users who are neither
+// members nor invited.
+func (s *roomService) load(ctx context.Context, userID int, roomID int64) (*domain.Room, *domain.ConversationMember, error) {
+	room, err := s.rooms.GetByID(ctx, roomID)
+	if err != nil {
+		s.logger.Error("Error getting room", zap.Int64("room_id", roomID), zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to get room: %w", err)
+	}
+	if room == nil {
+		return nil, nil, domain.ErrRoomNotFound
+	}
+
+	member, err := s.chats.GetMember(ctx, roomID, userID)
+	if err != nil {
+		s.logger.Error("Error getting room member", zap.Int64("room_id", roomID), zap.Error(err))
+		return nil, nil, fmt.Errorf("failed to get room member: %w", err)
+	}
+
+	if member == nil && room.Visibility == domain.RoomPrivate {
+		invited, err := s.rooms.HasInvite(ctx, roomID, userID)
+		if err != nil {
+			s.logger.Error("Error checking room invite", zap.Int64("room_id", roomID), zap.Error(err))
+			return nil, nil, fmt.Errorf("failed to check room invite: %w", err)
+		}
+		if !invited {
+			return nil, nil, domain.ErrRoomNotFound
+		}
+	}
+
+	return room, member, nil
+}
+
+// requireRole returns the membership of the actor if their role is at least role
+func (s *roomService) requireRole(ctx context.Context, actorID int, roomID int64, role string) (*domain.ConversationMember, error) {
+	_, actor, err := s.load(ctx, actorID, roomID)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil {
+		return nil, domain.ErrNotRoomMember
+	}
+	if domain.RoleRank(actor.Role) < domain.RoleRank(role) {
+		return nil, domain.ErrRoomRoleTooLow
+	}
+	return actor, nil
+}
+
+// moderate checks that the actor may kick or ban userID, moderators can only
+// act on users ranked below them. It returns the membership of userID.
+func (s *roomService) moderate(ctx context.Context, actorID int, roomID int64, userID int) (*domain.ConversationMember, error) {
+	actor, err := s.requireRole(ctx, actorID, roomID, domain.RoomRoleModerator)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.chats.GetMember(ctx, roomID, userID)
+	if err != nil {
+		s.logger.Error("Error getting room member", zap.Int64("room_id", roomID), zap.Error(err))
+		return nil, fmt.Errorf("failed to get room member: %w", err)
+	}
+
+	targetRank := 0
+	if target != nil {
+		targetRank = domain.RoleRank(target.Role)
+	}
+	if domain.RoleRank(actor.Role) <= targetRank || actorID == userID {
+		return nil, domain.ErrRoomRoleTooLow
+	}
+
+	return target, nil
+}
+
+func (s *roomService) checkJoinable(ctx context.Context, roomID int64, userID int) error {
+	banned, err := s.rooms.IsBanned(ctx, roomID, userID)
+	if err != nil {
+		s.logger.Error("Error checking room ban", zap.Int64("room_id", roomID), zap.Error(err))
+		return fmt.Errorf("failed to check room ban: %w", err)
+	}
+	if banned {
+		return domain.ErrBannedFromRoom
+	}
+
+	member, err := s.chats.GetMember(ctx, roomID, userID)
+	if err != nil {
+		s.logger.Error("Error getting room member", zap.Int64("room_id", roomID), zap.Error(err))
+		return fmt.Errorf("failed to get room member: %w", err)
+	}
+	if member != nil {
+		return domain.ErrAlreadyRoomMember
+	}
+	return nil
+}
+
+func (s *roomService) countOwners(ctx context.Context, roomID int64) (int, error) {
+	members, err := s.chats.GetMembers(ctx, roomID)
+	if err != nil {
+		s.logger.Error("Error getting room members", zap.Int64("room_id", roomID), zap.Error(err))
+		return 0, fmt.Errorf("failed to get room members: %w", err)
+	}
+
+	owners := 0
+	for _, member := range members {
+		if member.Role == domain.RoomRoleOwner {
+			owners++
+		}
+	}
+	return owners, nil
+}
+
+// membershipError passes domain errors of membership changes through and wraps the rest
+func (s *roomService) membershipError(message string, roomID int64, err error) error {
+	var domainErr *domain.Error
+	if errors.As(err, &domainErr) {
+		return err
+	}
+	s.logger.Error(message, zap.Int64("room_id", roomID), zap.Error(err))
+	return fmt.Errorf("failed to change room membership: %w", err)
+}
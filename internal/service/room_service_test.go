@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Mock Room Repository
+type MockRoomRepository struct {
+	mock.Mock
+}
+
+func (m *MockRoomRepository) Create(ctx context.Context, room *domain.Room) error {
+	args := m.Called(ctx, room)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) GetByID(ctx context.Context, id int64) (*domain.Room, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.Room), args.Error(1)
+}
+
+func (m *MockRoomRepository) GetVisible(ctx context.Context, userID int) ([]domain.Room, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]domain.Room), args.Error(1)
+}
+
+func (m *MockRoomRepository) AddMember(ctx context.Context, roomID int64, userID int, role string) error {
+	args := m.Called(ctx, roomID, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) RemoveMember(ctx context.Context, roomID int64, userID int) error {
+	args := m.Called(ctx, roomID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) SetMemberRole(ctx context.Context, roomID int64, userID int, role string) error {
+	args := m.Called(ctx, roomID, userID, role)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) CreateInvite(ctx context.Context, roomID int64, userID, invitedBy int) error {
+	args := m.Called(ctx, roomID, userID, invitedBy)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) HasInvite(ctx context.Context, roomID int64, userID int) (bool, error) {
+	args := m.Called(ctx, roomID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRoomRepository) Ban(ctx context.Context, roomID int64, userID, bannedBy int) error {
+	args := m.Called(ctx, roomID, userID, bannedBy)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) Unban(ctx context.Context, roomID int64, userID int) error {
+	args := m.Called(ctx, roomID, userID)
+	return args.Error(0)
+}
+
+func (m *MockRoomRepository) IsBanned(ctx context.Context, roomID int64, userID int) (bool, error) {
+	args := m.Called(ctx, roomID, userID)
+	return args.Bool(0), args.Error(1)
+}
+
+func newTestRoomService() (domain.RoomService, *MockRoomRepository, *MockChatRepository, *MockEventPublisher) {
+	rooms := new(MockRoomRepository)
+	chats := new(MockChatRepository)
+	publisher := new(MockEventPublisher)
+	logger, _ := zap.NewDevelopment()
+	return NewRoomService(rooms, chats, publisher, logger), rooms, chats, publisher
+}
+
+func TestJoinRoom_Public(t *testing.T) {
+	service, rooms, chats, publisher := newTestRoomService()
+
+	ctx := context.Background()
+	rooms.On("GetByID", ctx, int64(1)).Return(&domain.Room{ID: 1, Visibility: domain.RoomPublic}, nil)
+	chats.On("GetMember", ctx, int64(1), 2).Return(nil, nil)
+	rooms.On("IsBanned", ctx, int64(1), 2).Return(false, nil)
+	rooms.On("AddMember", ctx, int64(1), 2, domain.RoomRoleMember).Return(nil)
+	chats.On("GetMembers", ctx, int64(1)).Return([]domain.ConversationMember{{UserID: 1}, {UserID: 2}}, nil)
+	publisher.On("Publish", ctx, []int{1, 2}, mock.MatchedBy(func(event domain.Event) bool {
+		return event.Type == domain.EventRoomJoined
+	})).Return(nil)
+
+	err := service.Join(ctx, 2, 1)
+
+	assert.NoError(t, err)
+	rooms.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestJoinRoom_PrivateWithoutInvite(t *testing.T) {
+	service, rooms, chats, _ := newTestRoomService()
+
+	ctx := context.Background()
+	rooms.On("GetByID", ctx, int64(1)).Return(&domain.Room{ID: 1, Visibility: domain.RoomPrivate}, nil)
+	chats.On("GetMember", ctx, int64(1), 2).Return(nil, nil)
+	rooms.On("HasInvite", ctx, int64(1), 2).Return(false, nil)
+
+	err := service.Join(ctx, 2, 1)
+
+	assert.ErrorIs(t, err, domain.ErrRoomNotFound)
+	rooms.AssertNotCalled(t, "AddMember", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestJoinRoom_Banned(t *testing.T) {
+	service, rooms, chats, _ := newTestRoomService()
+
+	ctx := context.Background()
+	rooms.On("GetByID", ctx, int64(1)).Return(&domain.Room{ID: 1, Visibility: domain.RoomPublic}, nil)
+	chats.On("GetMember", ctx, int64(1), 2).Return(nil, nil)
+	rooms.On("IsBanned", ctx, int64(1), 2).Return(true, nil)
+
+	err := service.Join(ctx, 2, 1)
+
+	assert.ErrorIs(t, err, domain.ErrBannedFromRoom)
+	rooms.AssertNotCalled(t, "AddMember", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestLeaveRoom_LastOwner(t *testing.T) {
+	service, rooms, chats, _ := newTestRoomService()
+
+	ctx := context.Background()
+	rooms.On("GetByID", ctx, int64(1)).Return(&domain.Room{ID: 1, Visibility: domain.RoomPublic}, nil)
+	chats.On("GetMember", ctx, int64(1), 1).Return(&domain.ConversationMember{UserID: 1, Role: domain.RoomRoleOwner}, nil)
+	chats.On("GetMembers", ctx, int64(1)).Return([]domain.ConversationMember{
+		{UserID: 1, Role: domain.RoomRoleOwner},
+		{UserID: 2, Role: domain.RoomRoleModerator},
+	}, nil)
+
+	err := service.Leave(ctx, 1, 1)
+
+	assert.ErrorIs(t, err, domain.ErrLastRoomOwner)
+	rooms.AssertNotCalled(t, "RemoveMember", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestKickRoomMember_ModeratorCannotKickModerator(t *testing.T) {
+	service, rooms, chats, _ := newTestRoomService()
+
+	ctx := context.Background()
+	rooms.On("GetByID", ctx, int64(1)).Return(&domain.Room{ID: 1, Visibility: domain.RoomPublic}, nil)
+	chats.On("GetMember", ctx, int64(1), 2).Return(&domain.ConversationMember{UserID: 2, Role: domain.RoomRoleModerator}, nil)
+	chats.On("GetMember", ctx, int64(1), 3).Return(&domain.ConversationMember{UserID: 3, Role: domain.RoomRoleModerator}, nil)
+
+	err := service.Kick(ctx, 2, 1, 3)
+
+	assert.ErrorIs(t, err, domain.ErrRoomRoleTooLow)
+	rooms.AssertNotCalled(t, "RemoveMember", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestKickRoomMember_MemberCannotKick(t *testing.T) {
+	service, rooms, chats, _ := newTestRoomService()
+
+	ctx := context.Background()
+	rooms.On("GetByID", ctx, int64(1)).Return(&domain.Room{ID: 1, Visibility: domain.RoomPublic}, nil)
+	chats.On("GetMember", ctx, int64(1), 2).Return(&domain.ConversationMember{UserID: 2, Role: domain.RoomRoleMember}, nil)
+
+	err := service.Kick(ctx, 2, 1, 3)
+
+	assert.ErrorIs(t, err, domain.ErrRoomRoleTooLow)
+}
+
+func TestBanRoomMember_Success(t *testing.T) {
+	service, rooms, chats, publisher := newTestRoomService()
+
+	ctx := context.Background()
+	rooms.On("GetByID", ctx, int64(1)).Return(&domain.Room{ID: 1, Visibility: domain.RoomPrivate}, nil)
+	chats.On("GetMember", ctx, int64(1), 1).Return(&domain.ConversationMember{UserID: 1, Role: domain.RoomRoleOwner}, nil)
+	chats.On("GetMember", ctx, int64(1), 3).Return(&domain.ConversationMember{UserID: 3, Role: domain.RoomRoleMember}, nil)
+	rooms.On("Ban", ctx, int64(1), 3, 1).Return(nil)
+	chats.On("GetMembers", ctx, int64(1)).Return([]domain.ConversationMember{{UserID: 1}}, nil)
+	// The banned user is no longer a member but still learns about the ban
+	publisher.On("Publish", ctx, []int{1, 3}, mock.MatchedBy(func(event domain.Event) bool {
+		return event.Type == domain.EventRoomBanned
+	})).Return(nil)
+
+	err := service.Ban(ctx, 1, 1, 3)
+
+	assert.NoError(t, err)
+	rooms.AssertExpectations(t)
+	publisher.AssertExpectations(t)
+}
+
+func TestInviteToPrivateRoom_RequiresModerator(t *testing.T) {
+	service, rooms, chats, _ := newTestRoomService()
+
+	ctx := context.Background()
+	rooms.On("GetByID", ctx, int64(1)).Return(&domain.Room{ID: 1, Visibility: domain.RoomPrivate}, nil)
+	chats.On("GetMember", ctx, int64(1), 2).Return(&domain.ConversationMember{UserID: 2, Role: domain.RoomRoleMember}, nil)
+
+	err := service.Invite(ctx, 2, 1, 3)
+
+	assert.ErrorIs(t, err, domain.ErrRoomRoleTooLow)
+	rooms.AssertNotCalled(t, "CreateInvite", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
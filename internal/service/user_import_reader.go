@@ -0,0 +1,156 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+)
+
+// maxImportLine bounds the memory a single NDJSON line may take
+const maxImportLine = 64 * 1024
+
+// importRow is a row read from an import file. Problem is set when the row
+// could not be decoded into a request.
+type importRow struct {
+	line    int
+	req     domain.CreateUserRequest
+	problem *domain.FieldError
+}
+
+// importReader reads an import file row by row, it returns io.EOF after the
+// last row. Errors other than io.EOF mean the rest of the file is unreadable.
+type importReader interface {
+	next() (*importRow, error)
+}
+
+func newImportReader(format string, r io.Reader) (importReader, error) {
+	switch format {
+	case domain.ImportFormatCSV:
+		return newCSVImportReader(r)
+	case domain.ImportFormatNDJSON:
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(nil, maxImportLine)
+		return &ndjsonImportReader{scanner: scanner}, nil
+	default:
+		return nil, fmt.Errorf("unknown import format %q", format)
+	}
+}
+
+// csvImportReader reads CSV with a header row naming the email and name
+// columns in any order, other columns are ignored
+type csvImportReader struct {
+	reader  *csv.Reader
+	email   int
+	name    int
+	columns int
+}
+
+func newCSVImportReader(r io.Reader) (*csvImportReader, error) {
+	reader := csv.NewReader(r)
+	// Rows with a different number of fields are reported as invalid rows
+	// instead of stopping the reader
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, domain.ErrImportHeader
+	}
+	if err != nil {
+		return nil, malformedCSV(err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		// Spreadsheets often start UTF-8 files with a byte order mark
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		column = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := columns[column]; ok {
+			return nil, domain.ErrImportHeader
+		}
+		columns[column] = i
+	}
+
+	email, hasEmail := columns["email"]
+	name, hasName := columns["name"]
+	if !hasEmail || !hasName {
+		return nil, domain.ErrImportHeader
+	}
+	return &csvImportReader{reader: reader, email: email, name: name, columns: len(header)}, nil
+}
+
+func (r *csvImportReader) next() (*importRow, error) {
+	record, err := r.reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, malformedCSV(err)
+	}
+
+	line, _ := r.reader.FieldPos(0)
+	row := &importRow{line: line}
+	if len(record) != r.columns {
+		row.problem = &domain.FieldError{
+			Rule:    "format",
+			Message: fmt.Sprintf("has %d fields, the header has %d", len(record), r.columns),
+		}
+		return row, nil
+	}
+
+	row.req = domain.CreateUserRequest{Email: record[r.email], Name: record[r.name]}
+	return row, nil
+}
+
+// malformedCSV reports where the CSV broke, like an unterminated quote
+func malformedCSV(err error) error {
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return domain.NewError(domain.ErrValidation, fmt.Sprintf("malformed csv on line %d", parseErr.Line))
+	}
+	return fmt.Errorf("error reading csv: %w", err)
+}
+
+// ndjsonImportReader reads one JSON object per line, blank lines are skipped
+// and members other than email and name ignored
+type ndjsonImportReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (r *ndjsonImportReader) next() (*importRow, error) {
+	for r.scanner.Scan() {
+		r.line++
+		data := bytes.TrimSpace(r.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		row := &importRow{line: r.line}
+		var typeErr *json.UnmarshalTypeError
+		err := json.Unmarshal(data, &row.req)
+		switch {
+		case errors.As(err, &typeErr) && typeErr.Field != "":
+			row.problem = &domain.FieldError{Field: typeErr.Field, Rule: "type", Message: "must be a " + typeErr.Type.String()}
+		case err != nil || data[0] != '{':
+			row.problem = &domain.FieldError{Rule: "format", Message: "must be a JSON object"}
+		}
+		return row, nil
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, domain.ErrImportLineTooLong
+		}
+		return nil, fmt.Errorf("error reading ndjson: %w", err)
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"go.uber.org/zap"
+)
+
+type UserImportConfig struct {
+	// BatchSize is how many users jobs insert per statement, imports answered
+	// right away insert all users in one
+	BatchSize int
+	// AsyncSize is the body size in bytes above which imports run as jobs,
+	// larger bodies are stored in a temporary file for the job
+	AsyncSize int
+	// MaxSize is the largest body in bytes an import may have
+	MaxSize int
+	// StaleAfter is how long a job may go without storing progress before it
+	// counts as interrupted, progress is stored after every batch
+	StaleAfter time.Duration
+	// Retention is how long finished jobs can be polled
+	Retention time.Duration
+}
+
+type userImportService struct {
+	users     domain.UserRepository
+	jobs      domain.UserImportRepository
+	validator domain.Validator
+	cfg       UserImportConfig
+	logger    *zap.Logger
+}
+
+func NewUserImportService(
+	users domain.UserRepository,
+	jobs domain.UserImportRepository,
+	validator domain.Validator,
+	cfg UserImportConfig,
+	logger *zap.Logger,
+) domain.UserImportService {
+	return &userImportService{
+		users:     users,
+		jobs:      jobs,
+		validator: validator,
+		cfg:       cfg,
+		logger:    logger,
+	}
+}
+
+func (s *userImportService) ImportUsers(ctx context.Context, actorID int, req *domain.UserImportRequest, body io.Reader) (*domain.UserImportJob, error) {
+	limited := &io.LimitedReader{R: body, N: int64(s.cfg.MaxSize) + 1}
+
+	// Only bodies that can run right away are held in memory
+	head, err := io.ReadAll(io.LimitReader(limited, int64(s.cfg.AsyncSize)+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read import: %w", err)
+	}
+	if limited.N == 0 {
+		return nil, domain.ErrImportTooLarge
+	}
+
+	if !req.Async && len(head) <= s.cfg.AsyncSize {
+		// Created in one batch, a failure creates nobody and the same file can
+		// be imported again
+		result, err := s.importUsers(ctx, req, bytes.NewReader(head), 0, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		return &domain.UserImportJob{
+			UserID:     actorID,
+			Format:     req.Format,
+			DryRun:     req.DryRun,
+			Status:     domain.ImportJobSucceeded,
+			Processed:  result.Total,
+			Result:     result,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+			FinishedAt: &now,
+		}, nil
+	}
+
+	// The job outlives the request, it reads the body from a file
+	file, err := s.spool(io.MultiReader(bytes.NewReader(head), limited), limited)
+	if err != nil {
+		return nil, err
+	}
+
+	job := &domain.UserImportJob{
+		UserID: actorID,
+		Format: req.Format,
+		DryRun: req.DryRun,
+		Status: domain.ImportJobRunning,
+	}
+	if err := s.jobs.Create(ctx, job); err != nil {
+		s.removeSpool(file)
+		s.logger.Error("Error creating import job", zap.Int("user_id", actorID), zap.Error(err))
+		return nil, fmt.Errorf("failed to create import job: %w", err)
+	}
+
+	// The job gets its own copy, the caller may change the one returned
+	running := *job
+	go s.run(&running, req, file)
+
+	s.logger.Info("Import job started", zap.Int("job_id", job.ID), zap.Int("user_id", actorID))
+	return job, nil
+}
+
+func (s *userImportService) GetImportJob(ctx context.Context, actorID, id int) (*domain.UserImportJob, error) {
+	job, err := s.jobs.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Error getting import job", zap.Int("job_id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to get import job: %w", err)
+	}
+	// Jobs of other users are not revealed
+	if job == nil || job.UserID != actorID {
+		return nil, domain.ErrImportJobNotFound
+	}
+
+	return job, nil
+}
+
+func (s *userImportService) Sweep(ctx context.Context) error {
+	failed, err := s.jobs.FailStale(ctx, s.cfg.StaleAfter)
+	if err != nil {
+		s.logger.Error("Error failing stale import jobs", zap.Error(err))
+		return fmt.Errorf("failed to fail stale import jobs: %w", err)
+	}
+	if failed > 0 {
+		s.logger.Warn("Interrupted import jobs failed", zap.Int64("count", failed))
+	}
+
+	removed, err := s.jobs.DeleteFinished(ctx, time.Now().Add(-s.cfg.Retention))
+	if err != nil {
+		s.logger.Error("Error deleting import jobs", zap.Error(err))
+		return fmt.Errorf("failed to delete import jobs: %w", err)
+	}
+	if removed > 0 {
+		s.logger.Info("Import jobs deleted", zap.Int64("count", removed))
+	}
+	return nil
+}
+
+// run imports in the background and stores the progress and the outcome
+// of the job, it removes the file once done
+func (s *userImportService) run(job *domain.UserImportJob, req *domain.UserImportRequest, file *os.File) {
+	defer s.removeSpool(file)
+	ctx := context.Background()
+
+	result, err := s.importUsers(ctx, req, file, s.cfg.BatchSize, func(processed int) {
+		job.Processed = processed
+		if err := s.jobs.Update(ctx, job); err != nil {
+			s.logger.Error("Error storing import progress", zap.Int("job_id", job.ID), zap.Error(err))
+		}
+	})
+
+	if err != nil {
+		job.Status = domain.ImportJobFailed
+		job.Error = "import failed"
+		var domainErr *domain.Error
+		if errors.As(err, &domainErr) {
+			job.Error = domainErr.Message
+		}
+	} else {
+		job.Status = domain.ImportJobSucceeded
+		job.Processed = result.Total
+		job.Result = result
+	}
+
+	if err := s.jobs.Update(ctx, job); err != nil {
+		s.logger.Error("Error finishing import job", zap.Int("job_id", job.ID), zap.Error(err))
+		return
+	}
+	s.logger.Info("Import job finished", zap.Int("job_id", job.ID), zap.String("status", job.Status))
+}
+
+// spool copies body to a temporary file and rewinds it, limited is the
+// reader bounding body to MaxSize
+func (s *userImportService) spool(body io.Reader, limited *io.LimitedReader) (*os.File, error) {
+	file, err := os.CreateTemp("", "user-import-*")
+	if err != nil {
+		s.logger.Error("Error creating import file", zap.Error(err))
+		return nil, fmt.Errorf("failed to create import file: %w", err)
+	}
+
+	if _, err := io.Copy(file, body); err != nil {
+		s.removeSpool(file)
+		return nil, fmt.Errorf("failed to read import: %w", err)
+	}
+	if limited.N == 0 {
+		s.removeSpool(file)
+		return nil, domain.ErrImportTooLarge
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		s.removeSpool(file)
+		s.logger.Error("Error rewinding import file", zap.Error(err))
+		return nil, fmt.Errorf("failed to rewind import file: %w", err)
+	}
+	return file, nil
+}
+
+func (s *userImportService) removeSpool(file *os.File) {
+	if err := file.Close(); err != nil {
+		s.logger.Warn("Error closing import file", zap.String("file", file.Name()), zap.Error(err))
+	}
+	if err := os.Remove(file.Name()); err != nil {
+		s.logger.Warn("Error removing import file", zap.String("file", file.Name()), zap.Error(err))
+	}
+}
+
+// importUsers reads, validates and creates the users in batches of batchSize,
+// or all in one batch when it is 0. Every row is validated and normalized
+// like a request to CreateUser, rows repeating an email of an earlier row are
+// duplicates. progress, when set, is called with the number of rows read
+// after every batch.
+func (s *userImportService) importUsers(ctx context.Context, req *domain.UserImportRequest, r io.Reader, batchSize int, progress func(int)) (*domain.UserImportResult, error) {
+	reader, err := newImportReader(req.Format, r)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.UserImportResult{DryRun: req.DryRun, Rows: []domain.UserImportRow{}}
+	seen := make(map[string]bool)
+	var batch []int
+	var users []*domain.User
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.createBatch(ctx, req.DryRun, users, batch, result.Rows); err != nil {
+			return err
+		}
+		batch, users = batch[:0], users[:0]
+		if progress != nil {
+			progress(len(result.Rows))
+		}
+		return nil
+	}
+
+	for {
+		row, err := reader.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		entry := domain.UserImportRow{Line: row.line, Status: domain.ImportRowInvalid}
+		if row.problem != nil {
+			entry.Errors = []domain.FieldError{*row.problem}
+			result.Rows = append(result.Rows, entry)
+			continue
+		}
+
+		user := newUser(&row.req)
+		entry.Email = user.Email
+		if err := s.validator.Validate(&row.req); err != nil {
+			var validationErr *domain.ValidationError
+			if !errors.As(err, &validationErr) {
+				return nil, err
+			}
+			entry.Errors = validationErr.Fields
+			result.Rows = append(result.Rows, entry)
+			continue
+		}
+
+		if seen[user.Email] {
+			entry.Status = domain.ImportRowDuplicate
+			result.Rows = append(result.Rows, entry)
+			continue
+		}
+		seen[user.Email] = true
+
+		result.Rows = append(result.Rows, entry)
+		batch = append(batch, len(result.Rows)-1)
+		users = append(users, user)
+		if batchSize > 0 && len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	for _, row := range result.Rows {
+		switch row.Status {
+		case domain.ImportRowCreated:
+			result.Created++
+		case domain.ImportRowValid:
+			result.Valid++
+		case domain.ImportRowDuplicate:
+			result.Duplicates++
+		case domain.ImportRowInvalid:
+			result.Invalid++
+		}
+	}
+	result.Total = len(result.Rows)
+
+	s.logger.Info("Users imported",
+		zap.Bool("dry_run", result.DryRun),
+		zap.Int("total", result.Total),
+		zap.Int("created", result.Created),
+		zap.Int("duplicates", result.Duplicates),
+		zap.Int("invalid", result.Invalid),
+	)
+	return result, nil
+}
+
+// createBatch creates users, or checks their emails on dry runs, and sets
+// the status of their rows, batch holds the index of the row of each user
+func (s *userImportService) createBatch(ctx context.Context, dryRun bool, users []*domain.User, batch []int, rows []domain.UserImportRow) error {
+	if dryRun {
+		emails := make([]string, len(users))
+		for i, user := range users {
+			emails[i] = user.Email
+		}
+		existing, err := s.users.ExistingEmails(ctx, emails)
+		if err != nil {
+			s.logger.Error("Error checking existing emails", zap.Error(err))
+			return fmt.Errorf("failed to check existing emails: %w", err)
+		}
+
+		for i, user := range users {
+			rows[batch[i]].Status = domain.ImportRowValid
+			if existing[user.Email] {
+				rows[batch[i]].Status = domain.ImportRowDuplicate
+			}
+		}
+		return nil
+	}
+
+	if err := s.users.CreateBatch(ctx, users); err != nil {
+		s.logger.Error("Error creating users", zap.Int("count", len(users)), zap.Error(err))
+		return fmt.Errorf("failed to create users: %w", err)
+	}
+
+	// Users whose email was already taken were skipped
+	for i, user := range users {
+		rows[batch[i]].Status = domain.ImportRowDuplicate
+		if user.ID != 0 {
+			rows[batch[i]].Status = domain.ImportRowCreated
+			rows[batch[i]].UserID = user.ID
+		}
+	}
+	return nil
+}
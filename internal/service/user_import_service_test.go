@@ -0,0 +1,292 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/internal/validator"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// Mock User Import Repository
+type MockUserImportRepository struct {
+	mock.Mock
+}
+
+func (m *MockUserImportRepository) Create(ctx context.Context, job *domain.UserImportJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockUserImportRepository) GetByID(ctx context.Context, id int) (*domain.UserImportJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.UserImportJob), args.Error(1)
+}
+
+func (m *MockUserImportRepository) Update(ctx context.Context, job *domain.UserImportJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+func (m *MockUserImportRepository) FailStale(ctx context.Context, staleAfter time.Duration) (int64, error) {
+	args := m.Called(ctx, staleAfter)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserImportRepository) DeleteFinished(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func newTestUserImportService(users domain.UserRepository, jobs domain.UserImportRepository) domain.UserImportService {
+	logger, _ := zap.NewDevelopment()
+	return NewUserImportService(users, jobs, validator.New(), UserImportConfig{
+		BatchSize:  2,
+		AsyncSize:  1024,
+		MaxSize:    4096,
+		StaleAfter: 5 * time.Minute,
+		Retention:  24 * time.Hour,
+	}, logger)
+}
+
+// createUsers assigns ids to the users of CreateBatch, except those with a
+// taken email
+func createUsers(taken ...string) func(mock.Arguments) {
+	return func(args mock.Arguments) {
+		for _, user := range args.Get(1).([]*domain.User) {
+			if !slices.Contains(taken, user.Email) {
+				user.ID = len(user.Email)
+			}
+		}
+	}
+}
+
+func statuses(rows []domain.UserImportRow) []string {
+	result := make([]string, len(rows))
+	for i, row := range rows {
+		result[i] = row.Status
+	}
+	return result
+}
+
+func TestImportUsers_CSV(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := newTestUserImportService(mockRepo, new(MockUserImportRepository))
+
+	ctx := context.Background()
+	mockRepo.On("CreateBatch", ctx, mock.Anything).Run(createUsers("taken@example.com")).Return(nil)
+
+	data := "\ufeffName,Email,Team\n" +
+		"Ann,Ann@Example.com,a\n" +
+		"Bob,taken@example.com,b\n" +
+		"Ann Again,ANN@example.com,a\n" +
+		"X,not-an-email,c\n" +
+		"Carol,carol@example.com\n" +
+		"Dave,dave@example.com,d\n"
+	job, err := service.ImportUsers(ctx, 1, &domain.UserImportRequest{Format: domain.ImportFormatCSV}, strings.NewReader(data))
+
+	require.NoError(t, err)
+	assert.Equal(t, domain.ImportJobSucceeded, job.Status)
+	result := job.Result
+	assert.Equal(t, []string{
+		domain.ImportRowCreated,
+		domain.ImportRowDuplicate,
+		domain.ImportRowDuplicate,
+		domain.ImportRowInvalid,
+		domain.ImportRowInvalid,
+		domain.ImportRowCreated,
+	}, statuses(result.Rows))
+	assert.Equal(t, "ann@example.com", result.Rows[0].Email)
+	assert.NotZero(t, result.Rows[0].UserID)
+	assert.Equal(t, 5, result.Rows[3].Line)
+	assert.Equal(t, "email", result.Rows[3].Errors[0].Field)
+	assert.Equal(t, "format", result.Rows[4].Errors[0].Rule)
+	assert.Equal(t, 6, result.Total)
+	assert.Equal(t, 2, result.Created)
+	assert.Equal(t, 2, result.Duplicates)
+	assert.Equal(t, 2, result.Invalid)
+	// Imports answered right away are created in one batch
+	mockRepo.AssertNumberOfCalls(t, "CreateBatch", 1)
+}
+
+func TestImportUsers_SyncFailureCreatesNobody(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := newTestUserImportService(mockRepo, new(MockUserImportRepository))
+
+	ctx := context.Background()
+	mockRepo.On("CreateBatch", ctx, mock.MatchedBy(func(users []*domain.User) bool {
+		return len(users) == 3
+	})).Return(errors.New("connection reset"))
+
+	data := "email,name\nann@example.com,Ann\nbob@example.com,Bob\ncarol@example.com,Carol\n"
+	job, err := service.ImportUsers(ctx, 1, &domain.UserImportRequest{Format: domain.ImportFormatCSV}, strings.NewReader(data))
+
+	assert.Error(t, err)
+	assert.Nil(t, job)
+	// More rows than BatchSize still went in one statement, so none of them
+	// were created
+	mockRepo.AssertNumberOfCalls(t, "CreateBatch", 1)
+}
+
+func TestImportUsers_NDJSONDryRun(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	service := newTestUserImportService(mockRepo, new(MockUserImportRepository))
+
+	ctx := context.Background()
+	mockRepo.On("ExistingEmails", ctx, []string{"ann@example.com", "bob@example.com"}).
+		Return(map[string]bool{"bob@example.com": true}, nil)
+
+	data := `{"email":"ann@example.com","name":"Ann","role":"admin"}` + "\n" +
+		"\n" +
+		`{"email":"bob@example.com","name":"Bob"}` + "\n" +
+		`{"email":"carol@example.com","name":7}` + "\n" +
+		`["dave@example.com","Dave"]`
+	job, err := service.ImportUsers(ctx, 1, &domain.UserImportRequest{Format: domain.ImportFormatNDJSON, DryRun: true}, strings.NewReader(data))
+
+	require.NoError(t, err)
+	result := job.Result
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []string{
+		domain.ImportRowValid,
+		domain.ImportRowDuplicate,
+		domain.ImportRowInvalid,
+		domain.ImportRowInvalid,
+	}, statuses(result.Rows))
+	assert.Equal(t, 3, result.Rows[1].Line)
+	assert.Equal(t, domain.FieldError{Field: "name", Rule: "type", Message: "must be a string"}, result.Rows[2].Errors[0])
+	assert.Equal(t, 1, result.Valid)
+	assert.Zero(t, result.Created)
+	mockRepo.AssertNotCalled(t, "CreateBatch", mock.Anything, mock.Anything)
+}
+
+func TestImportUsers_MalformedFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		data   string
+		err    string
+	}{
+		{"empty csv", domain.ImportFormatCSV, "", domain.ErrImportHeader.Message},
+		{"missing column", domain.ImportFormatCSV, "email\nann@example.com\n", domain.ErrImportHeader.Message},
+		{"repeated column", domain.ImportFormatCSV, "email,name,email\n", domain.ErrImportHeader.Message},
+		{"bare quote", domain.ImportFormatCSV, "email,name\nann@example.com,Ann\n\"bob,Bob\n", "malformed csv on line 3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockUserRepository)
+			service := newTestUserImportService(mockRepo, new(MockUserImportRepository))
+			mockRepo.On("CreateBatch", mock.Anything, mock.Anything).Return(nil)
+
+			_, err := service.ImportUsers(context.Background(), 1, &domain.UserImportRequest{Format: tt.format}, strings.NewReader(tt.data))
+
+			assert.ErrorIs(t, err, domain.ErrValidation)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestImportReader_LongLine(t *testing.T) {
+	reader, err := newImportReader(domain.ImportFormatNDJSON, strings.NewReader(strings.Repeat(" ", maxImportLine+1)))
+	require.NoError(t, err)
+
+	_, err = reader.next()
+	assert.ErrorIs(t, err, domain.ErrImportLineTooLong)
+}
+
+func TestImportUsers_Async(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockJobs := new(MockUserImportRepository)
+	service := newTestUserImportService(mockRepo, mockJobs)
+
+	ctx := context.Background()
+	mockJobs.On("Create", ctx, mock.AnythingOfType("*domain.UserImportJob")).Run(func(args mock.Arguments) {
+		args.Get(1).(*domain.UserImportJob).ID = 7
+	}).Return(nil)
+	finished := make(chan *domain.UserImportJob, 1)
+	mockJobs.On("Update", mock.Anything, mock.AnythingOfType("*domain.UserImportJob")).Run(func(args mock.Arguments) {
+		if job := args.Get(1).(*domain.UserImportJob); job.Status != domain.ImportJobRunning {
+			finished <- job
+		}
+	}).Return(nil)
+	mockRepo.On("CreateBatch", mock.Anything, mock.Anything).Run(createUsers()).Return(nil)
+
+	data := []byte("email,name\nann@example.com,Ann\n")
+	job, err := service.ImportUsers(ctx, 1, &domain.UserImportRequest{Format: domain.ImportFormatCSV, Async: true}, bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 7, job.ID)
+	assert.Equal(t, domain.ImportJobRunning, job.Status)
+
+	// The job must not depend on the request body
+	copy(data, "xxxxx")
+
+	select {
+	case done := <-finished:
+		assert.Equal(t, domain.ImportJobSucceeded, done.Status)
+		assert.Equal(t, 1, done.Result.Created)
+		assert.Equal(t, 1, done.Processed)
+	case <-time.After(5 * time.Second):
+		t.Fatal("import job did not finish")
+	}
+}
+
+func TestImportUsers_AsyncFailure(t *testing.T) {
+	mockJobs := new(MockUserImportRepository)
+	service := newTestUserImportService(new(MockUserRepository), mockJobs)
+
+	ctx := context.Background()
+	mockJobs.On("Create", ctx, mock.AnythingOfType("*domain.UserImportJob")).Return(nil)
+	finished := make(chan *domain.UserImportJob, 1)
+	mockJobs.On("Update", mock.Anything, mock.AnythingOfType("*domain.UserImportJob")).Run(func(args mock.Arguments) {
+		finished <- args.Get(1).(*domain.UserImportJob)
+	}).Return(nil)
+
+	// Bodies above AsyncSize run as jobs without asking
+	data := []byte("email\n" + strings.Repeat("ann@example.com\n", 100))
+	_, err := service.ImportUsers(ctx, 1, &domain.UserImportRequest{Format: domain.ImportFormatCSV}, bytes.NewReader(data))
+	require.NoError(t, err)
+
+	select {
+	case done := <-finished:
+		assert.Equal(t, domain.ImportJobFailed, done.Status)
+		assert.Equal(t, domain.ErrImportHeader.Message, done.Error)
+	case <-time.After(5 * time.Second):
+		t.Fatal("import job did not finish")
+	}
+}
+
+func TestImportUsers_TooLarge(t *testing.T) {
+	mockJobs := new(MockUserImportRepository)
+	service := newTestUserImportService(new(MockUserRepository), mockJobs)
+
+	data := "email,name\n" + strings.Repeat("ann@example.com,Ann\n", 300)
+	_, err := service.ImportUsers(context.Background(), 1, &domain.UserImportRequest{Format: domain.ImportFormatCSV}, strings.NewReader(data))
+
+	assert.ErrorIs(t, err, domain.ErrImportTooLarge)
+	mockJobs.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+}
+
+func TestGetImportJob_OtherUser(t *testing.T) {
+	mockJobs := new(MockUserImportRepository)
+	service := newTestUserImportService(new(MockUserRepository), mockJobs)
+
+	ctx := context.Background()
+	mockJobs.On("GetByID", ctx, 7).Return(&domain.UserImportJob{ID: 7, UserID: 2}, nil)
+
+	job, err := service.GetImportJob(ctx, 1, 7)
+
+	assert.Nil(t, job)
+	assert.ErrorIs(t, err, domain.ErrImportJobNotFound)
+}
@@ -2,43 +2,56 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/DMaryanskiy/go-idk/internal/domain"
 	"go.uber.org/zap"
 )
 
+// userCursor is the position a user page cursor points at: the sort values
+// of the user the next page starts after or, for backward cursors, the
+// previous page ends before. Sort is the sort the cursor was issued for.
+type userCursor struct {
+	Sort     string   `json:"s"`
+	Values   []string `json:"v"`
+	Backward bool     `json:"b,omitempty"`
+}
+
 type userService struct {
-	repo   domain.UserRepository
-	logger *zap.Logger
+	repo    domain.UserRepository
+	cursors domain.CursorCodec
+	logger  *zap.Logger
 }
 
-func NewUserService(repo domain.UserRepository, logger *zap.Logger) domain.UserService {
+func NewUserService(repo domain.UserRepository, cursors domain.CursorCodec, logger *zap.Logger) domain.UserService {
 	return &userService{
-		repo:   repo,
-		logger: logger,
+		repo:    repo,
+		cursors: cursors,
+		logger:  logger,
 	}
 }
 
 func (s *userService) CreateUser(ctx context.Context, req *domain.CreateUserRequest) (*domain.User, error) {
-	email := strings.ToLower(strings.TrimSpace(req.Email))
+	user := newUser(req)
 
-	existing, err := s.repo.GetByEmail(ctx, email)
+	existing, err := s.repo.GetByEmail(ctx, user.Email)
 	if err != nil {
 		s.logger.Error("Error checking existing user", zap.Error(err))
 		return nil, fmt.Errorf("failed to check existing user: %w", err)
 	}
 	if existing != nil {
-		return nil, fmt.Errorf("user with email already exists")
-	}
-
-	user := &domain.User{
-		Email: email,
-		Name:  strings.TrimSpace(req.Name),
+		return nil, domain.ErrUserExists
 	}
 
 	if err := s.repo.Create(ctx, user); err != nil {
+		if errors.Is(err, domain.ErrEmailInUse) {
+			return nil, domain.ErrUserExists
+		}
 		s.logger.Error("Error creating user", zap.Error(err))
 		return nil, fmt.Errorf("failed to create a user: %w", err)
 	}
@@ -47,6 +60,15 @@ func (s *userService) CreateUser(ctx context.Context, req *domain.CreateUserRequ
 	return user, nil
 }
 
+// newUser normalizes a validated create request into a user, imports go
+// through it as well
+func newUser(req *domain.CreateUserRequest) *domain.User {
+	return &domain.User{
+		Email: strings.ToLower(strings.TrimSpace(req.Email)),
+		Name:  strings.TrimSpace(req.Name),
+	}
+}
+
 func (s *userService) GetUser(ctx context.Context, id int) (*domain.User, error) {
 	user, err := s.repo.GetByID(ctx, id)
 	if err != nil {
@@ -54,13 +76,13 @@ func (s *userService) GetUser(ctx context.Context, id int) (*domain.User, error)
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 	if user == nil {
-		return nil, fmt.Errorf("user not found")
+		return nil, domain.ErrUserNotFound
 	}
 
 	return user, nil
 }
 
-func (s *userService) GetUsers(ctx context.Context, limit, offset int) (*domain.PaginationResponse, error) {
+func (s *userService) GetUsers(ctx context.Context, req *domain.UserListRequest, limit, offset int) (*domain.PaginationResponse, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 10
 	}
@@ -68,7 +90,15 @@ func (s *userService) GetUsers(ctx context.Context, limit, offset int) (*domain.
 		offset = 0
 	}
 
-	users, total, err := s.repo.GetAll(ctx, limit, offset)
+	if err := authorizeListing(ctx, &req.Filter); err != nil {
+		return nil, err
+	}
+	sort, err := parseUserSort(req.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	users, total, err := s.repo.GetAll(ctx, &domain.UserQuery{Filter: req.Filter, Sort: sort}, limit, offset)
 	if err != nil {
 		s.logger.Error("Error getting all users", zap.Error(err))
 		return nil, fmt.Errorf("failed to get all users: %w", err)
@@ -85,36 +115,155 @@ func (s *userService) GetUsers(ctx context.Context, limit, offset int) (*domain.
 	}, nil
 }
 
-func (s *userService) UpdateUser(ctx context.Context, id int, req *domain.UpdateUserRequest) (*domain.User, error) {
-	existing, err := s.repo.GetByID(ctx, id)
+func (s *userService) GetUsersPage(ctx context.Context, req *domain.UserPageRequest) (*domain.UserPage, error) {
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+
+	if err := authorizeListing(ctx, &req.Filter); err != nil {
+		return nil, err
+	}
+	sort, err := parseUserSort(req.Sort)
 	if err != nil {
-		s.logger.Error("Error getting user by id", zap.Error(err))
-		return nil, fmt.Errorf("failed to get user by id: %w", err)
+		return nil, err
 	}
-	if existing == nil {
-		return nil, fmt.Errorf("user not found")
+
+	// One extra user tells whether there are more in the paging direction
+	query := &domain.UserPageQuery{
+		UserQuery: domain.UserQuery{Filter: req.Filter, Sort: sort},
+		Limit:     limit + 1,
+	}
+	var position *userCursor
+	if req.Cursor != "" {
+		position = &userCursor{}
+		if err := s.cursors.Decode(req.Cursor, position); err != nil {
+			return nil, err
+		}
+		if position.Sort != formatSort(sort) || len(position.Values) != len(sort) {
+			return nil, domain.ErrInvalidCursor
+		}
+		query.After = position.Values
+		query.Backward = position.Backward
 	}
 
-	if req.Email != "" {
-		email := strings.ToLower(strings.TrimSpace(req.Email))
+	users, err := s.repo.GetPage(ctx, query)
+	if err != nil {
+		s.logger.Error("Error getting users page", zap.Error(err))
+		return nil, fmt.Errorf("failed to get users page: %w", err)
+	}
 
-		if email != existing.Email {
-			emailExists, err := s.repo.GetByEmail(ctx, email)
-			if err != nil {
-				s.logger.Error("Error checking email availability", zap.Error(err))
-				return nil, fmt.Errorf("failed to check email availability: %w", err)
+	page := &domain.UserPage{Users: users, Limit: limit}
+	if len(users) > limit {
+		page.HasMore = true
+		if query.Backward {
+			page.Users = users[1:]
+		} else {
+			page.Users = users[:limit]
+		}
+	}
+
+	// A page reached from a cursor has users on the side it came from
+	if len(page.Users) > 0 {
+		hasNext := query.Backward || page.HasMore
+		hasPrev := query.Backward && page.HasMore || !query.Backward && position != nil
+		if hasNext {
+			if page.Next, err = s.encodeCursor(sort, &page.Users[len(page.Users)-1], false); err != nil {
+				return nil, err
 			}
-			if emailExists != nil {
-				return nil, fmt.Errorf("email already in use")
+		}
+		if hasPrev {
+			if page.Prev, err = s.encodeCursor(sort, &page.Users[0], true); err != nil {
+				return nil, err
 			}
-			existing.Email = email
 		}
 	}
-	if req.Name != "" {
-		existing.Name = strings.TrimSpace(req.Name)
+
+	if req.IncludeTotal {
+		total, err := s.repo.Count(ctx, &req.Filter)
+		if err != nil {
+			s.logger.Error("Error counting users", zap.Error(err))
+			return nil, fmt.Errorf("failed to count users: %w", err)
+		}
+		page.Total = &total
+	}
+
+	return page, nil
+}
+
+func (s *userService) SearchUsers(ctx context.Context, req *domain.UserSearchRequest) (*domain.UserSearchResponse, error) {
+	limit := req.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 10
+	}
+	offset := max(req.Offset, 0)
+
+	results, err := s.repo.Search(ctx, req.Query, limit, offset)
+	if err != nil {
+		s.logger.Error("Error searching users", zap.Error(err))
+		return nil, fmt.Errorf("failed to search users: %w", err)
+	}
+
+	return &domain.UserSearchResponse{Results: results, Limit: limit, Offset: offset}, nil
+}
+
+func (s *userService) encodeCursor(sort []domain.SortField, user *domain.User, backward bool) (string, error) {
+	values := make([]string, len(sort))
+	for i, field := range sort {
+		values[i] = userSortValue(user, field.Field)
+	}
+
+	cursor, err := s.cursors.Encode(userCursor{Sort: formatSort(sort), Values: values, Backward: backward})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	return cursor, nil
+}
+
+func (s *userService) UpdateUser(ctx context.Context, id int, version int, req *domain.UpdateUserRequest) (*domain.User, error) {
+	if err := authorizeOwner(ctx, id); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		s.logger.Error("Error getting user by id", zap.Error(err))
+		return nil, fmt.Errorf("failed to get user by id: %w", err)
+	}
+	if existing == nil {
+		return nil, domain.ErrUserNotFound
+	}
+	if version != 0 && existing.Version != version {
+		return nil, domain.ErrUserVersionMismatch
 	}
 
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if email != existing.Email {
+		emailExists, err := s.repo.GetByEmail(ctx, email)
+		if err != nil {
+			s.logger.Error("Error checking email availability", zap.Error(err))
+			return nil, fmt.Errorf("failed to check email availability: %w", err)
+		}
+		if emailExists != nil {
+			return nil, domain.ErrEmailInUse
+		}
+		existing.Email = email
+	}
+	existing.Name = strings.TrimSpace(req.Name)
+
+	// The write only goes through if nobody changed the user since it was read
 	if err := s.repo.Update(ctx, id, existing); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) || errors.Is(err, domain.ErrEmailInUse) {
+			return nil, err
+		}
+		if errors.Is(err, domain.ErrUserVersionMismatch) {
+			if version != 0 {
+				return nil, err
+			}
+			// The client did not ask for a version, the change it based its
+			// request on may still have been harmless
+			return nil, domain.ErrUserModified
+		}
 		s.logger.Error("Error updating user", zap.Int("user_id", id), zap.Error(err))
 		return nil, fmt.Errorf("failed to update user with id %d: %w", id, err)
 	}
@@ -124,7 +273,14 @@ func (s *userService) UpdateUser(ctx context.Context, id int, req *domain.Update
 }
 
 func (s *userService) DeleteUser(ctx context.Context, id int) error {
+	if err := authorizeOwner(ctx, id); err != nil {
+		return err
+	}
+
 	if err := s.repo.Delete(ctx, id); err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) {
+			return err
+		}
 		s.logger.Error("Error deleting user", zap.Int("user_id", id), zap.Error(err))
 		return fmt.Errorf("failed to delete user with id %d: %w", id, err)
 	}
@@ -132,3 +288,114 @@ func (s *userService) DeleteUser(ctx context.Context, id int) error {
 	s.logger.Info("User deleted", zap.Int("user_id", id))
 	return nil
 }
+
+func (s *userService) RestoreUser(ctx context.Context, id int) (*domain.User, error) {
+	user, err := s.repo.Restore(ctx, id)
+	if err != nil {
+		if errors.Is(err, domain.ErrUserNotFound) || errors.Is(err, domain.ErrEmailInUse) {
+			return nil, err
+		}
+		s.logger.Error("Error restoring user", zap.Int("user_id", id), zap.Error(err))
+		return nil, fmt.Errorf("failed to restore user with id %d: %w", id, err)
+	}
+
+	s.logger.Info("User restored", zap.Int("user_id", id))
+	return user, nil
+}
+
+func (s *userService) PurgeDeleted(ctx context.Context, retention time.Duration) error {
+	purged, err := s.repo.Purge(ctx, time.Now().UTC().Add(-retention))
+	if err != nil {
+		s.logger.Error("Error purging deleted users", zap.Error(err))
+		return fmt.Errorf("failed to purge deleted users: %w", err)
+	}
+
+	if purged > 0 {
+		s.logger.Info("Deleted users purged", zap.Int64("count", purged))
+	}
+	return nil
+}
+
+// authorizeListing lets only users who may manage users see deleted ones.
+// Like authorizeOwner it refuses calls without an actor, internal callers
+// pass domain.SystemActor.
+func authorizeListing(ctx context.Context, filter *domain.UserFilter) error {
+	if !filter.IncludeDeleted {
+		return nil
+	}
+	actor := domain.ActorFromContext(ctx)
+	if actor != nil && actor.Can(domain.PermissionUsersManage) {
+		return nil
+	}
+	return domain.ErrDeletedUsersHidden
+}
+
+// authorizeOwner lets a user change only their own record unless they may
+// manage users. Calls without an actor are refused, internal callers pass
+// domain.SystemActor.
+func authorizeOwner(ctx context.Context, id int) error {
+	actor := domain.ActorFromContext(ctx)
+	if actor == nil {
+		return domain.ErrUserChangeForbidden
+	}
+	if actor.UserID == id || actor.Can(domain.PermissionUsersManage) {
+		return nil
+	}
+	return domain.ErrUserChangeForbidden
+}
+
+// parseUserSort parses a sort parameter such as "-created_at,name". The
+// result always ends with id, so users with equal values keep their order.
+func parseUserSort(raw string) ([]domain.SortField, error) {
+	sort := []domain.SortField{}
+	seen := make(map[string]bool)
+	for term := range strings.SplitSeq(raw, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		field := domain.SortField{Field: strings.TrimPrefix(term, "-"), Desc: strings.HasPrefix(term, "-")}
+		if !slices.Contains(domain.UserSortFields, field.Field) || seen[field.Field] {
+			return nil, &domain.ValidationError{Fields: []domain.FieldError{{
+				Field:   "sort",
+				Rule:    "oneof",
+				Param:   strings.Join(domain.UserSortFields, " "),
+				Message: fmt.Sprintf("must list each of %s at most once", strings.Join(domain.UserSortFields, ", ")),
+			}}}
+		}
+		seen[field.Field] = true
+		sort = append(sort, field)
+	}
+
+	if !seen["id"] {
+		sort = append(sort, domain.SortField{Field: "id"})
+	}
+	return sort, nil
+}
+
+func formatSort(sort []domain.SortField) string {
+	terms := make([]string, len(sort))
+	for i, field := range sort {
+		terms[i] = field.Field
+		if field.Desc {
+			terms[i] = "-" + field.Field
+		}
+	}
+	return strings.Join(terms, ",")
+}
+
+func userSortValue(user *domain.User, field string) string {
+	switch field {
+	case "name":
+		return user.Name
+	case "email":
+		return user.Email
+	case "created_at":
+		return user.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return user.UpdatedAt.Format(time.RFC3339Nano)
+	default:
+		return strconv.Itoa(user.ID)
+	}
+}
@@ -9,6 +9,7 @@ import (
     "github.com/stretchr/testify/assert"
     "github.com/stretchr/testify/mock"
     "github.com/DMaryanskiy/go-idk/internal/domain"
+    "github.com/DMaryanskiy/go-idk/internal/token"
     "go.uber.org/zap"
 )
 
@@ -22,6 +23,19 @@ func (m *MockUserRepository) Create(ctx context.Context, user *domain.User) erro
     return args.Error(0)
 }
 
+func (m *MockUserRepository) CreateBatch(ctx context.Context, users []*domain.User) error {
+    args := m.Called(ctx, users)
+    return args.Error(0)
+}
+
+func (m *MockUserRepository) ExistingEmails(ctx context.Context, emails []string) (map[string]bool, error) {
+    args := m.Called(ctx, emails)
+    if args.Get(0) == nil {
+        return nil, args.Error(1)
+    }
+    return args.Get(0).(map[string]bool), args.Error(1)
+}
+
 func (m *MockUserRepository) GetByID(ctx context.Context, id int) (*domain.User, error) {
     args := m.Called(ctx, id)
     if args.Get(0) == nil {
@@ -38,11 +52,39 @@ func (m *MockUserRepository) GetByEmail(ctx context.Context, email string) (*dom
     return args.Get(0).(*domain.User), args.Error(1)
 }
 
-func (m *MockUserRepository) GetAll(ctx context.Context, limit, offset int) ([]domain.User, int, error) {
-    args := m.Called(ctx, limit, offset)
+func (m *MockUserRepository) GetAll(ctx context.Context, query *domain.UserQuery, limit, offset int) ([]domain.User, int, error) {
+    args := m.Called(ctx, query, limit, offset)
     return args.Get(0).([]domain.User), args.Int(1), args.Error(2)
 }
 
+func (m *MockUserRepository) GetPage(ctx context.Context, query *domain.UserPageQuery) ([]domain.User, error) {
+    args := m.Called(ctx, query)
+    return args.Get(0).([]domain.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Count(ctx context.Context, filter *domain.UserFilter) (int, error) {
+    args := m.Called(ctx, filter)
+    return args.Int(0), args.Error(1)
+}
+
+func (m *MockUserRepository) Restore(ctx context.Context, id int) (*domain.User, error) {
+    args := m.Called(ctx, id)
+    if args.Get(0) == nil {
+        return nil, args.Error(1)
+    }
+    return args.Get(0).(*domain.User), args.Error(1)
+}
+
+func (m *MockUserRepository) Purge(ctx context.Context, before time.Time) (int64, error) {
+    args := m.Called(ctx, before)
+    return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockUserRepository) Search(ctx context.Context, query string, limit, offset int) ([]domain.UserSearchResult, error) {
+    args := m.Called(ctx, query, limit, offset)
+    return args.Get(0).([]domain.UserSearchResult), args.Error(1)
+}
+
 func (m *MockUserRepository) Update(ctx context.Context, id int, user *domain.User) error {
     args := m.Called(ctx, id, user)
     return args.Error(0)
@@ -53,10 +95,21 @@ func (m *MockUserRepository) Delete(ctx context.Context, id int) error {
     return args.Error(0)
 }
 
+// idSort is the order of user listings without a sort parameter
+var idSort = []domain.SortField{{Field: "id"}}
+
+func newTestCursorCodec() domain.CursorCodec {
+    codec, err := token.NewCursorCodec("test-cursor-secret-of-32-characters")
+    if err != nil {
+        panic(err)
+    }
+    return codec
+}
+
 func TestCreateUser_Success(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     req := &domain.CreateUserRequest{
         Email: "test@example.com",
@@ -88,7 +141,7 @@ func TestCreateUser_Success(t *testing.T) {
 func TestCreateUser_DuplicateEmail(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     req := &domain.CreateUserRequest{
         Email: "test@example.com",
@@ -108,10 +161,27 @@ func TestCreateUser_DuplicateEmail(t *testing.T) {
     mockRepo.AssertExpectations(t)
 }
 
+func TestCreateUser_EmailTakenConcurrently(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := context.Background()
+    // The email was free when checked and taken when inserted
+    mockRepo.On("GetByEmail", ctx, "test@example.com").Return(nil, nil)
+    mockRepo.On("Create", ctx, mock.AnythingOfType("*domain.User")).Return(domain.ErrEmailInUse)
+
+    user, err := service.CreateUser(ctx, &domain.CreateUserRequest{Email: "test@example.com", Name: "Test User"})
+
+    assert.ErrorIs(t, err, domain.ErrUserExists)
+    assert.Nil(t, user)
+    mockRepo.AssertExpectations(t)
+}
+
 func TestCreateUser_RepositoryError(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     req := &domain.CreateUserRequest{
         Email: "test@example.com",
@@ -135,7 +205,7 @@ func TestCreateUser_RepositoryError(t *testing.T) {
 func TestGetUser_Success(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     expectedUser := &domain.User{
         ID:        1,
@@ -160,7 +230,7 @@ func TestGetUser_Success(t *testing.T) {
 func TestGetUser_NotFound(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     ctx := context.Background()
     mockRepo.On("GetByID", ctx, 999).Return(nil, nil)
@@ -170,13 +240,14 @@ func TestGetUser_NotFound(t *testing.T) {
     assert.Error(t, err)
     assert.Nil(t, user)
     assert.Contains(t, err.Error(), "not found")
+    assert.ErrorIs(t, err, domain.ErrUserNotFound)
     mockRepo.AssertExpectations(t)
 }
 
 func TestGetUser_RepositoryError(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     ctx := context.Background()
     mockRepo.On("GetByID", ctx, 1).Return(nil, errors.New("database error"))
@@ -191,7 +262,7 @@ func TestGetUser_RepositoryError(t *testing.T) {
 func TestGetUsers_Success(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     expectedUsers := []domain.User{
         {ID: 1, Email: "test1@example.com", Name: "User 1"},
@@ -199,9 +270,9 @@ func TestGetUsers_Success(t *testing.T) {
     }
 
     ctx := context.Background()
-    mockRepo.On("GetAll", ctx, 10, 0).Return(expectedUsers, 2, nil)
+    mockRepo.On("GetAll", ctx, &domain.UserQuery{Sort: idSort}, 10, 0).Return(expectedUsers, 2, nil)
 
-    response, err := service.GetUsers(ctx, 10, 0)
+    response, err := service.GetUsers(ctx, &domain.UserListRequest{}, 10, 0)
     
     assert.NoError(t, err)
     assert.NotNil(t, response)
@@ -216,16 +287,16 @@ func TestGetUsers_Success(t *testing.T) {
 func TestGetUsers_WithPagination(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     expectedUsers := []domain.User{
         {ID: 11, Email: "test11@example.com", Name: "User 11"},
     }
 
     ctx := context.Background()
-    mockRepo.On("GetAll", ctx, 10, 10).Return(expectedUsers, 25, nil)
+    mockRepo.On("GetAll", ctx, &domain.UserQuery{Sort: idSort}, 10, 10).Return(expectedUsers, 25, nil)
 
-    response, err := service.GetUsers(ctx, 10, 10)
+    response, err := service.GetUsers(ctx, &domain.UserListRequest{}, 10, 10)
     
     assert.NoError(t, err)
     assert.NotNil(t, response)
@@ -237,13 +308,13 @@ func TestGetUsers_WithPagination(t *testing.T) {
 func TestGetUsers_InvalidLimit(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     ctx := context.Background()
     // Should default to limit=10
-    mockRepo.On("GetAll", ctx, 10, 0).Return([]domain.User{}, 0, nil)
+    mockRepo.On("GetAll", ctx, &domain.UserQuery{Sort: idSort}, 10, 0).Return([]domain.User{}, 0, nil)
 
-    response, err := service.GetUsers(ctx, 0, 0)
+    response, err := service.GetUsers(ctx, &domain.UserListRequest{}, 0, 0)
     
     assert.NoError(t, err)
     assert.Equal(t, 10, response.Limit)
@@ -253,7 +324,7 @@ func TestGetUsers_InvalidLimit(t *testing.T) {
 func TestUpdateUser_Success(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     existingUser := &domain.User{
         ID:    1,
@@ -266,12 +337,12 @@ func TestUpdateUser_Success(t *testing.T) {
         Name:  "New Name",
     }
 
-    ctx := context.Background()
+    ctx := domain.WithActor(context.Background(), domain.SystemActor)
     mockRepo.On("GetByID", ctx, 1).Return(existingUser, nil)
     mockRepo.On("GetByEmail", ctx, "new@example.com").Return(nil, nil)
     mockRepo.On("Update", ctx, 1, mock.AnythingOfType("*domain.User")).Return(nil)
 
-    user, err := service.UpdateUser(ctx, 1, req)
+    user, err := service.UpdateUser(ctx, 1, 0, req)
     
     assert.NoError(t, err)
     assert.NotNil(t, user)
@@ -283,16 +354,16 @@ func TestUpdateUser_Success(t *testing.T) {
 func TestUpdateUser_NotFound(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     req := &domain.UpdateUserRequest{
         Name: "New Name",
     }
 
-    ctx := context.Background()
+    ctx := domain.WithActor(context.Background(), domain.SystemActor)
     mockRepo.On("GetByID", ctx, 999).Return(nil, nil)
 
-    user, err := service.UpdateUser(ctx, 999, req)
+    user, err := service.UpdateUser(ctx, 999, 0, req)
     
     assert.Error(t, err)
     assert.Nil(t, user)
@@ -303,7 +374,7 @@ func TestUpdateUser_NotFound(t *testing.T) {
 func TestUpdateUser_EmailAlreadyInUse(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     existingUser := &domain.User{
         ID:    1,
@@ -321,11 +392,11 @@ func TestUpdateUser_EmailAlreadyInUse(t *testing.T) {
         Email: "taken@example.com",
     }
 
-    ctx := context.Background()
+    ctx := domain.WithActor(context.Background(), domain.SystemActor)
     mockRepo.On("GetByID", ctx, 1).Return(existingUser, nil)
     mockRepo.On("GetByEmail", ctx, "taken@example.com").Return(anotherUser, nil)
 
-    user, err := service.UpdateUser(ctx, 1, req)
+    user, err := service.UpdateUser(ctx, 1, 0, req)
     
     assert.Error(t, err)
     assert.Nil(t, user)
@@ -333,10 +404,10 @@ func TestUpdateUser_EmailAlreadyInUse(t *testing.T) {
     mockRepo.AssertExpectations(t)
 }
 
-func TestUpdateUser_PartialUpdate(t *testing.T) {
+func TestUpdateUser_SameEmail(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     existingUser := &domain.User{
         ID:    1,
@@ -345,28 +416,30 @@ func TestUpdateUser_PartialUpdate(t *testing.T) {
     }
 
     req := &domain.UpdateUserRequest{
-        Name: "New Name", // Only updating name
+        Email: " Old@Example.com ", // Same email, differently spelled
+        Name:  "New Name",
     }
 
-    ctx := context.Background()
+    ctx := domain.WithActor(context.Background(), domain.SystemActor)
     mockRepo.On("GetByID", ctx, 1).Return(existingUser, nil)
     mockRepo.On("Update", ctx, 1, mock.AnythingOfType("*domain.User")).Return(nil)
 
-    user, err := service.UpdateUser(ctx, 1, req)
+    user, err := service.UpdateUser(ctx, 1, 0, req)
     
     assert.NoError(t, err)
     assert.NotNil(t, user)
-    assert.Equal(t, "old@example.com", user.Email) // Email unchanged
-    assert.Equal(t, "New Name", user.Name)          // Name updated
+    assert.Equal(t, "old@example.com", user.Email)
+    assert.Equal(t, "New Name", user.Name)
     mockRepo.AssertExpectations(t)
+    mockRepo.AssertNotCalled(t, "GetByEmail", mock.Anything, mock.Anything)
 }
 
 func TestDeleteUser_Success(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
-    ctx := context.Background()
+    ctx := domain.WithActor(context.Background(), domain.SystemActor)
     mockRepo.On("Delete", ctx, 1).Return(nil)
 
     err := service.DeleteUser(ctx, 1)
@@ -378,22 +451,146 @@ func TestDeleteUser_Success(t *testing.T) {
 func TestDeleteUser_NotFound(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
-    ctx := context.Background()
-    mockRepo.On("Delete", ctx, 999).Return(errors.New("user not found"))
+    ctx := domain.WithActor(context.Background(), domain.SystemActor)
+    mockRepo.On("Delete", ctx, 999).Return(domain.ErrUserNotFound)
 
     err := service.DeleteUser(ctx, 999)
     
     assert.Error(t, err)
-    assert.Contains(t, err.Error(), "failed to delete user")
+    assert.ErrorIs(t, err, domain.ErrNotFound)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateUser_OtherUserForbidden(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    actor := &domain.Actor{UserID: 2, Permissions: map[string]bool{domain.PermissionUsersUpdate: true}}
+    ctx := domain.WithActor(context.Background(), actor)
+
+    user, err := service.UpdateUser(ctx, 1, 0, &domain.UpdateUserRequest{Name: "New Name"})
+
+    assert.Error(t, err)
+    assert.Nil(t, user)
+    assert.ErrorIs(t, err, domain.ErrForbidden)
+    mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateUser_WithoutActorForbidden(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    user, err := service.UpdateUser(context.Background(), 1, 0, &domain.UpdateUserRequest{Name: "New Name"})
+
+    assert.ErrorIs(t, err, domain.ErrUserChangeForbidden)
+    assert.Nil(t, user)
+    mockRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+}
+
+func TestUpdateUser_Owner(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    actor := &domain.Actor{UserID: 1, Permissions: map[string]bool{domain.PermissionUsersUpdate: true}}
+    ctx := domain.WithActor(context.Background(), actor)
+    mockRepo.On("GetByID", ctx, 1).Return(&domain.User{ID: 1, Email: "test@example.com", Name: "Old Name"}, nil)
+    mockRepo.On("Update", ctx, 1, mock.AnythingOfType("*domain.User")).Return(nil)
+
+    user, err := service.UpdateUser(ctx, 1, 0, &domain.UpdateUserRequest{Email: "test@example.com", Name: "New Name"})
+
+    assert.NoError(t, err)
+    assert.Equal(t, "New Name", user.Name)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestUpdateUser_StaleVersion(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := domain.WithActor(context.Background(), domain.SystemActor)
+    mockRepo.On("GetByID", ctx, 1).Return(&domain.User{ID: 1, Name: "Old Name", Version: 3}, nil)
+
+    user, err := service.UpdateUser(ctx, 1, 2, &domain.UpdateUserRequest{Name: "New Name"})
+
+    assert.ErrorIs(t, err, domain.ErrUserVersionMismatch)
+    assert.ErrorIs(t, err, domain.ErrPreconditionFailed)
+    assert.Nil(t, user)
+    mockRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestUpdateUser_ConcurrentChange(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := domain.WithActor(context.Background(), domain.SystemActor)
+    mockRepo.On("GetByID", ctx, 1).Return(&domain.User{ID: 1, Name: "Old Name", Version: 3}, nil)
+    mockRepo.On("Update", ctx, 1, mock.MatchedBy(func(u *domain.User) bool { return u.Version == 3 })).
+        Return(domain.ErrUserVersionMismatch)
+
+    // Without If-Match the client gets a conflict rather than a failed precondition
+    _, err := service.UpdateUser(ctx, 1, 0, &domain.UpdateUserRequest{Name: "New Name"})
+    assert.ErrorIs(t, err, domain.ErrUserModified)
+
+    _, err = service.UpdateUser(ctx, 1, 3, &domain.UpdateUserRequest{Name: "New Name"})
+    assert.ErrorIs(t, err, domain.ErrUserVersionMismatch)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestDeleteUser_OtherUserForbidden(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    actor := &domain.Actor{UserID: 2, Permissions: map[string]bool{domain.PermissionUsersDelete: true}}
+    ctx := domain.WithActor(context.Background(), actor)
+
+    err := service.DeleteUser(ctx, 1)
+
+    assert.Error(t, err)
+    assert.ErrorIs(t, err, domain.ErrForbidden)
+    mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestDeleteUser_WithoutActorForbidden(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    err := service.DeleteUser(context.Background(), 1)
+
+    assert.ErrorIs(t, err, domain.ErrUserChangeForbidden)
+    mockRepo.AssertNotCalled(t, "Delete", mock.Anything, mock.Anything)
+}
+
+func TestDeleteUser_Manager(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    actor := &domain.Actor{UserID: 2, Permissions: map[string]bool{
+        domain.PermissionUsersDelete: true,
+        domain.PermissionUsersManage: true,
+    }}
+    ctx := domain.WithActor(context.Background(), actor)
+    mockRepo.On("Delete", ctx, 1).Return(nil)
+
+    err := service.DeleteUser(ctx, 1)
+
+    assert.NoError(t, err)
     mockRepo.AssertExpectations(t)
 }
 
 func TestServiceWithContextCancellation(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     ctx, cancel := context.WithCancel(context.Background())
     cancel() // Cancel immediately
@@ -409,7 +606,7 @@ func TestServiceWithContextCancellation(t *testing.T) {
 func TestServiceWithContextTimeout(t *testing.T) {
     mockRepo := new(MockUserRepository)
     logger, _ := zap.NewDevelopment()
-    service := NewUserService(mockRepo, logger)
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
 
     ctx, cancel := context.WithTimeout(context.Background(), 1*time.Nanosecond)
     defer cancel()
@@ -423,3 +620,222 @@ func TestServiceWithContextTimeout(t *testing.T) {
     assert.Error(t, err)
     assert.Nil(t, user)
 }
+
+func TestGetUsers_SortAndFilter(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := context.Background()
+    filter := domain.UserFilter{EmailDomain: "example.com", Query: "ann"}
+    query := &domain.UserQuery{
+        Filter: filter,
+        Sort:   []domain.SortField{{Field: "created_at", Desc: true}, {Field: "name"}, {Field: "id"}},
+    }
+    mockRepo.On("GetAll", ctx, query, 10, 0).Return([]domain.User{}, 0, nil)
+
+    _, err := service.GetUsers(ctx, &domain.UserListRequest{Filter: filter, Sort: "-created_at, name"}, 10, 0)
+
+    assert.NoError(t, err)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestGetUsers_UnknownSortField(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    response, err := service.GetUsers(context.Background(), &domain.UserListRequest{Sort: "password_hash"}, 10, 0)
+
+    var validationErr *domain.ValidationError
+    assert.ErrorAs(t, err, &validationErr)
+    assert.Equal(t, "sort", validationErr.Fields[0].Field)
+    assert.Nil(t, response)
+    mockRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetUsers_IncludeDeletedForbidden(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := domain.WithActor(context.Background(), &domain.Actor{UserID: 1, Permissions: map[string]bool{domain.PermissionUsersList: true}})
+    req := &domain.UserListRequest{Filter: domain.UserFilter{IncludeDeleted: true}}
+
+    response, err := service.GetUsers(ctx, req, 10, 0)
+
+    assert.ErrorIs(t, err, domain.ErrDeletedUsersHidden)
+    assert.Nil(t, response)
+    mockRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetUsers_IncludeDeletedWithoutActorForbidden(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    req := &domain.UserListRequest{Filter: domain.UserFilter{IncludeDeleted: true}}
+
+    response, err := service.GetUsers(context.Background(), req, 10, 0)
+
+    assert.ErrorIs(t, err, domain.ErrDeletedUsersHidden)
+    assert.Nil(t, response)
+    mockRepo.AssertNotCalled(t, "GetAll", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetUsers_IncludeDeletedSystemActor(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := domain.WithActor(context.Background(), domain.SystemActor)
+    filter := domain.UserFilter{IncludeDeleted: true}
+    mockRepo.On("GetAll", ctx, &domain.UserQuery{Filter: filter, Sort: []domain.SortField{{Field: "id"}}}, 10, 0).Return([]domain.User{}, 0, nil)
+
+    _, err := service.GetUsers(ctx, &domain.UserListRequest{Filter: filter}, 10, 0)
+
+    assert.NoError(t, err)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestRestoreUser_EmailInUse(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := context.Background()
+    mockRepo.On("Restore", ctx, 1).Return(nil, domain.ErrEmailInUse)
+
+    user, err := service.RestoreUser(ctx, 1)
+
+    assert.ErrorIs(t, err, domain.ErrEmailInUse)
+    assert.Nil(t, user)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestPurgeDeleted(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := context.Background()
+    retention := 30 * 24 * time.Hour
+    cutoff := mock.MatchedBy(func(before time.Time) bool {
+        return time.Since(before) >= retention && time.Since(before) < retention+time.Minute
+    })
+    mockRepo.On("Purge", ctx, cutoff).Return(int64(2), nil)
+
+    err := service.PurgeDeleted(ctx, retention)
+
+    assert.NoError(t, err)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestSearchUsers_Success(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := context.Background()
+    results := []domain.UserSearchResult{
+        {User: domain.User{ID: 1, Name: "Ann Lee"}, Rank: 0.2, Highlight: "<mark>Ann</mark> Lee"},
+    }
+    mockRepo.On("Search", ctx, `"ann lee" -bob`, 10, 0).Return(results, nil)
+
+    response, err := service.SearchUsers(ctx, &domain.UserSearchRequest{Query: `"ann lee" -bob`, Limit: 500, Offset: -1})
+
+    assert.NoError(t, err)
+    assert.Equal(t, results, response.Results)
+    assert.Equal(t, 10, response.Limit)
+    assert.Equal(t, 0, response.Offset)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestGetUsersPage_FirstPage(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := context.Background()
+    mockRepo.On("GetPage", ctx, &domain.UserPageQuery{UserQuery: domain.UserQuery{Sort: idSort}, Limit: 3}).
+        Return([]domain.User{{ID: 1}, {ID: 2}, {ID: 3}}, nil)
+
+    page, err := service.GetUsersPage(ctx, &domain.UserPageRequest{Limit: 2})
+
+    assert.NoError(t, err)
+    assert.Len(t, page.Users, 2)
+    assert.True(t, page.HasMore)
+    assert.NotEmpty(t, page.Next)
+    assert.Empty(t, page.Prev)
+    assert.Nil(t, page.Total)
+    mockRepo.AssertNotCalled(t, "Count", mock.Anything, mock.Anything)
+}
+
+func TestGetUsersPage_FollowsCursors(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := context.Background()
+    byName := domain.UserQuery{Sort: []domain.SortField{{Field: "name"}, {Field: "id"}}}
+    mockRepo.On("GetPage", ctx, &domain.UserPageQuery{UserQuery: byName, Limit: 3}).
+        Return([]domain.User{{ID: 3, Name: "Ann"}, {ID: 1, Name: "Bob"}, {ID: 2, Name: "Cid"}}, nil)
+    mockRepo.On("GetPage", ctx, &domain.UserPageQuery{UserQuery: byName, After: []string{"Bob", "1"}, Limit: 3}).
+        Return([]domain.User{{ID: 2, Name: "Cid"}}, nil)
+    mockRepo.On("GetPage", ctx, &domain.UserPageQuery{UserQuery: byName, After: []string{"Cid", "2"}, Backward: true, Limit: 3}).
+        Return([]domain.User{{ID: 3, Name: "Ann"}, {ID: 1, Name: "Bob"}}, nil)
+    mockRepo.On("Count", ctx, &domain.UserFilter{}).Return(3, nil)
+
+    list := domain.UserListRequest{Sort: "name"}
+    first, err := service.GetUsersPage(ctx, &domain.UserPageRequest{UserListRequest: list, Limit: 2})
+    assert.NoError(t, err)
+
+    second, err := service.GetUsersPage(ctx, &domain.UserPageRequest{UserListRequest: list, Cursor: first.Next, Limit: 2, IncludeTotal: true})
+    assert.NoError(t, err)
+    assert.Equal(t, []domain.User{{ID: 2, Name: "Cid"}}, second.Users)
+    assert.False(t, second.HasMore)
+    assert.Empty(t, second.Next)
+    assert.NotEmpty(t, second.Prev)
+    assert.Equal(t, 3, *second.Total)
+
+    back, err := service.GetUsersPage(ctx, &domain.UserPageRequest{UserListRequest: list, Cursor: second.Prev, Limit: 2})
+    assert.NoError(t, err)
+    assert.Len(t, back.Users, 2)
+    assert.False(t, back.HasMore)
+    assert.Empty(t, back.Prev)
+    assert.NotEmpty(t, back.Next)
+    mockRepo.AssertExpectations(t)
+}
+
+func TestGetUsersPage_CursorOfOtherSort(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    ctx := context.Background()
+    mockRepo.On("GetPage", ctx, &domain.UserPageQuery{UserQuery: domain.UserQuery{Sort: idSort}, Limit: 2}).
+        Return([]domain.User{{ID: 1}, {ID: 2}}, nil)
+
+    first, err := service.GetUsersPage(ctx, &domain.UserPageRequest{Limit: 1})
+    assert.NoError(t, err)
+
+    page, err := service.GetUsersPage(ctx, &domain.UserPageRequest{
+        UserListRequest: domain.UserListRequest{Sort: "-name"},
+        Cursor:          first.Next,
+    })
+
+    assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+    assert.Nil(t, page)
+}
+
+func TestGetUsersPage_InvalidCursor(t *testing.T) {
+    mockRepo := new(MockUserRepository)
+    logger, _ := zap.NewDevelopment()
+    service := NewUserService(mockRepo, newTestCursorCodec(), logger)
+
+    page, err := service.GetUsersPage(context.Background(), &domain.UserPageRequest{Cursor: "eyJpZCI6MX0.forged"})
+
+    assert.ErrorIs(t, err, domain.ErrInvalidCursor)
+    assert.Nil(t, page)
+    mockRepo.AssertNotCalled(t, "GetPage", mock.Anything, mock.Anything)
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/DMaryanskiy/go-idk/pkg/mailer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"go.uber.org/zap"
+)
+
+// Mock Verification Code Repository
+type MockVerificationCodeRepository struct {
+	mock.Mock
+}
+
+func (m *MockVerificationCodeRepository) Create(ctx context.Context, code *domain.VerificationCode, interval time.Duration) (bool, error) {
+	args := m.Called(ctx, code, interval)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockVerificationCodeRepository) GetLatest(ctx context.Context, userID int) (*domain.VerificationCode, error) {
+	args := m.Called(ctx, userID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*domain.VerificationCode), args.Error(1)
+}
+
+func (m *MockVerificationCodeRepository) ReserveAttempt(ctx context.Context, id, maxAttempts int) (bool, error) {
+	args := m.Called(ctx, id, maxAttempts)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockVerificationCodeRepository) Consume(ctx context.Context, id, userID int) error {
+	args := m.Called(ctx, id, userID)
+	return args.Error(0)
+}
+
+var testVerificationConfig = VerificationConfig{
+	CodeTTL:        15 * time.Minute,
+	ResendInterval: time.Minute,
+	MaxAttempts:    3,
+}
+
+func TestSendCode_MailsCodeAndStoresHash(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockCodes := new(MockVerificationCodeRepository)
+	mail := mailer.NewMemoryMailer()
+	logger, _ := zap.NewDevelopment()
+	service := NewVerificationService(mockRepo, mockCodes, mail, testVerificationConfig, logger)
+
+	ctx := context.Background()
+	user := &domain.User{ID: 1, Email: "test@example.com", Name: "Test User"}
+	mockCodes.On("Create", ctx, mock.AnythingOfType("*domain.VerificationCode"), time.Duration(0)).Return(true, nil)
+
+	err := service.SendCode(ctx, user)
+
+	assert.NoError(t, err)
+	messages := mail.Messages()
+	assert.Len(t, messages, 1)
+	assert.Equal(t, "test@example.com", messages[0].To)
+
+	code := regexp.MustCompile(`\d{6}`).FindString(messages[0].Body)
+	stored := mockCodes.Calls[0].Arguments.Get(1).(*domain.VerificationCode)
+	assert.Equal(t, hashCode(1, code), stored.CodeHash)
+	assert.NotContains(t, stored.CodeHash, code)
+	mockCodes.AssertExpectations(t)
+}
+
+func TestVerify_Success(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockCodes := new(MockVerificationCodeRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewVerificationService(mockRepo, mockCodes, mailer.NewMemoryMailer(), testVerificationConfig, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetByEmail", ctx, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockCodes.On("GetLatest", ctx, 1).Return(&domain.VerificationCode{
+		ID: 5, UserID: 1, CodeHash: hashCode(1, "123456"), ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	mockCodes.On("ReserveAttempt", ctx, 5, 3).Return(true, nil)
+	mockCodes.On("Consume", ctx, 5, 1).Return(nil)
+
+	err := service.Verify(ctx, &domain.VerifyEmailRequest{Email: "Test@Example.com", Code: "123456"})
+
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockCodes.AssertExpectations(t)
+}
+
+func TestVerify_WrongCodeCountsAttempt(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockCodes := new(MockVerificationCodeRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewVerificationService(mockRepo, mockCodes, mailer.NewMemoryMailer(), testVerificationConfig, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetByEmail", ctx, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockCodes.On("GetLatest", ctx, 1).Return(&domain.VerificationCode{
+		ID: 5, UserID: 1, CodeHash: hashCode(1, "123456"), ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	mockCodes.On("ReserveAttempt", ctx, 5, 3).Return(true, nil)
+
+	err := service.Verify(ctx, &domain.VerifyEmailRequest{Email: "test@example.com", Code: "654321"})
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid or expired")
+	mockCodes.AssertExpectations(t)
+}
+
+func TestVerify_TooManyAttempts(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockCodes := new(MockVerificationCodeRepository)
+	logger, _ := zap.NewDevelopment()
+	service := NewVerificationService(mockRepo, mockCodes, mailer.NewMemoryMailer(), testVerificationConfig, logger)
+
+	ctx := context.Background()
+	mockRepo.On("GetByEmail", ctx, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockCodes.On("GetLatest", ctx, 1).Return(&domain.VerificationCode{
+		ID: 5, UserID: 1, CodeHash: hashCode(1, "123456"), Attempts: 2, ExpiresAt: time.Now().Add(time.Minute),
+	}, nil)
+	// A parallel guess took the last attempt after the code was read
+	mockCodes.On("ReserveAttempt", ctx, 5, 3).Return(false, nil)
+
+	err := service.Verify(ctx, &domain.VerifyEmailRequest{Email: "test@example.com", Code: "123456"})
+
+	assert.ErrorIs(t, err, domain.ErrInvalidVerificationCode)
+	mockCodes.AssertExpectations(t)
+	mockCodes.AssertNotCalled(t, "Consume", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestResend_SendsCode(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockCodes := new(MockVerificationCodeRepository)
+	mail := mailer.NewMemoryMailer()
+	logger, _ := zap.NewDevelopment()
+	service := NewVerificationService(mockRepo, mockCodes, mail, testVerificationConfig, logger)
+
+	mockRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	mockCodes.On("Create", mock.Anything, mock.AnythingOfType("*domain.VerificationCode"), time.Minute).Return(true, nil)
+
+	service.Resend(context.Background(), &domain.ResendVerificationRequest{Email: " Test@Example.com"})
+	assert.NoError(t, service.Wait(context.Background()))
+
+	mockRepo.AssertExpectations(t)
+	mockCodes.AssertExpectations(t)
+	assert.Len(t, mail.Messages(), 1)
+}
+
+func TestResend_ThrottledIsSilent(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockCodes := new(MockVerificationCodeRepository)
+	mail := mailer.NewMemoryMailer()
+	logger, _ := zap.NewDevelopment()
+	service := NewVerificationService(mockRepo, mockCodes, mail, testVerificationConfig, logger)
+
+	mockRepo.On("GetByEmail", mock.Anything, "test@example.com").Return(&domain.User{ID: 1, Email: "test@example.com"}, nil)
+	// The repository refuses codes within the interval
+	mockCodes.On("Create", mock.Anything, mock.AnythingOfType("*domain.VerificationCode"), time.Minute).Return(false, nil)
+
+	service.Resend(context.Background(), &domain.ResendVerificationRequest{Email: "test@example.com"})
+	assert.NoError(t, service.Wait(context.Background()))
+
+	mockCodes.AssertExpectations(t)
+	assert.Empty(t, mail.Messages())
+}
+
+func TestResend_UnknownEmailIsSilent(t *testing.T) {
+	mockRepo := new(MockUserRepository)
+	mockCodes := new(MockVerificationCodeRepository)
+	mail := mailer.NewMemoryMailer()
+	logger, _ := zap.NewDevelopment()
+	service := NewVerificationService(mockRepo, mockCodes, mail, testVerificationConfig, logger)
+
+	mockRepo.On("GetByEmail", mock.Anything, "missing@example.com").Return(nil, nil)
+
+	service.Resend(context.Background(), &domain.ResendVerificationRequest{Email: "missing@example.com"})
+	assert.NoError(t, service.Wait(context.Background()))
+
+	mockRepo.AssertExpectations(t)
+	assert.Empty(t, mail.Messages())
+}
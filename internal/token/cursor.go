@@ -0,0 +1,61 @@
+package token
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+)
+
+type cursorCodec struct {
+	key []byte
+}
+
+// NewCursorCodec signs cursors with HMAC-SHA256. Cursors stay valid across
+// restarts and instances as long as the secret does not change.
+func NewCursorCodec(secret string) (domain.CursorCodec, error) {
+	if len(secret) < minSecretLength {
+		return nil, fmt.Errorf("cursor secret must be at least %d characters long", minSecretLength)
+	}
+	return &cursorCodec{key: []byte(secret)}, nil
+}
+
+func (c *cursorCodec) Encode(position any) (string, error) {
+	payload, err := json.Marshal(position)
+	if err != nil {
+		return "", fmt.Errorf("error encoding cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(c.sign(payload)), nil
+}
+
+func (c *cursorCodec) Decode(cursor string, position any) error {
+	encodedPayload, encodedSignature, found := strings.Cut(cursor, ".")
+	if !found {
+		return domain.ErrInvalidCursor
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+	if err != nil {
+		return domain.ErrInvalidCursor
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || !hmac.Equal(signature, c.sign(payload)) {
+		return domain.ErrInvalidCursor
+	}
+
+	if err := json.Unmarshal(payload, position); err != nil {
+		return domain.ErrInvalidCursor
+	}
+	return nil
+}
+
+func (c *cursorCodec) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
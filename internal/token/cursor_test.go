@@ -0,0 +1,46 @@
+package token
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type position struct {
+	ID int `json:"id"`
+}
+
+func TestCursorCodec_RoundTrip(t *testing.T) {
+	codec, err := NewCursorCodec(strings.Repeat("s", 32))
+	require.NoError(t, err)
+
+	cursor, err := codec.Encode(position{ID: 42})
+	require.NoError(t, err)
+
+	var decoded position
+	assert.NoError(t, codec.Decode(cursor, &decoded))
+	assert.Equal(t, 42, decoded.ID)
+}
+
+func TestCursorCodec_RejectsTamperedCursor(t *testing.T) {
+	codec, err := NewCursorCodec(strings.Repeat("s", 32))
+	require.NoError(t, err)
+	other, err := NewCursorCodec(strings.Repeat("o", 32))
+	require.NoError(t, err)
+
+	forged, err := other.Encode(position{ID: 42})
+	require.NoError(t, err)
+
+	var decoded position
+	assert.ErrorIs(t, codec.Decode(forged, &decoded), domain.ErrInvalidCursor)
+	assert.ErrorIs(t, codec.Decode("not-a-cursor", &decoded), domain.ErrInvalidCursor)
+}
+
+func TestCursorCodec_ShortSecret(t *testing.T) {
+	_, err := NewCursorCodec("short")
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,133 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	AlgorithmHS256 = "HS256"
+	AlgorithmEdDSA = "EdDSA"
+
+	minSecretLength = 32
+)
+
+type Config struct {
+	Algorithm string
+	// Secret is the HMAC key used with HS256
+	Secret string
+	// PrivateKey is a base64 encoded Ed25519 seed or private key used with EdDSA
+	PrivateKey string
+	Issuer     string
+	TTL        time.Duration
+}
+
+type claims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email"`
+}
+
+type jwtManager struct {
+	method    jwt.SigningMethod
+	signKey   any
+	verifyKey any
+	issuer    string
+	ttl       time.Duration
+}
+
+func NewJWTManager(cfg Config) (domain.TokenManager, error) {
+	m := &jwtManager{issuer: cfg.Issuer, ttl: cfg.TTL}
+
+	switch cfg.Algorithm {
+	case AlgorithmHS256:
+		if len(cfg.Secret) < minSecretLength {
+			return nil, fmt.Errorf("jwt secret must be at least %d characters long", minSecretLength)
+		}
+		m.method = jwt.SigningMethodHS256
+		m.signKey = []byte(cfg.Secret)
+		m.verifyKey = []byte(cfg.Secret)
+	case AlgorithmEdDSA:
+		key, err := parseEd25519Key(cfg.PrivateKey)
+		if err != nil {
+			return nil, err
+		}
+		m.method = jwt.SigningMethodEdDSA
+		m.signKey = key
+		m.verifyKey = key.Public()
+	default:
+		return nil, fmt.Errorf("unsupported jwt algorithm %q", cfg.Algorithm)
+	}
+
+	return m, nil
+}
+
+func parseEd25519Key(encoded string) (ed25519.PrivateKey, error) {
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("error decoding ed25519 key: %w", err)
+	}
+
+	switch len(raw) {
+	case ed25519.SeedSize:
+		return ed25519.NewKeyFromSeed(raw), nil
+	case ed25519.PrivateKeySize:
+		return ed25519.PrivateKey(raw), nil
+	default:
+		return nil, fmt.Errorf("ed25519 key must be %d or %d bytes, got %d", ed25519.SeedSize, ed25519.PrivateKeySize, len(raw))
+	}
+}
+
+func (m *jwtManager) Generate(user *domain.User) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(m.ttl)
+
+	token := jwt.NewWithClaims(m.method, claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   strconv.Itoa(user.ID),
+			Issuer:    m.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		Email: user.Email,
+	})
+
+	signed, err := token.SignedString(m.signKey)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error signing access token: %w", err)
+	}
+
+	return signed, expiresAt, nil
+}
+
+func (m *jwtManager) Parse(tokenString string) (*domain.AccessClaims, error) {
+	parsed := &claims{}
+	_, err := jwt.ParseWithClaims(tokenString, parsed, func(*jwt.Token) (any, error) {
+		return m.verifyKey, nil
+	},
+		jwt.WithValidMethods([]string{m.method.Alg()}),
+		jwt.WithIssuer(m.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access token: %w", err)
+	}
+
+	userID, err := strconv.Atoi(parsed.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access token subject: %w", err)
+	}
+
+	return &domain.AccessClaims{
+		UserID:    userID,
+		Email:     parsed.Email,
+		ExpiresAt: parsed.ExpiresAt.Time,
+	}, nil
+}
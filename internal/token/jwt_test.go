@@ -0,0 +1,89 @@
+package token
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJWTManager_HS256(t *testing.T) {
+	manager, err := NewJWTManager(Config{
+		Algorithm: AlgorithmHS256,
+		Secret:    strings.Repeat("s", 32),
+		Issuer:    "test",
+		TTL:       time.Minute,
+	})
+	assert.NoError(t, err)
+
+	signed, expiresAt, err := manager.Generate(&domain.User{ID: 42, Email: "test@example.com"})
+	assert.NoError(t, err)
+	assert.WithinDuration(t, time.Now().Add(time.Minute), expiresAt, time.Second)
+
+	claims, err := manager.Parse(signed)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 42, claims.UserID)
+	assert.Equal(t, "test@example.com", claims.Email)
+}
+
+func TestJWTManager_EdDSA(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+
+	manager, err := NewJWTManager(Config{
+		Algorithm:  AlgorithmEdDSA,
+		PrivateKey: base64.StdEncoding.EncodeToString(key.Seed()),
+		Issuer:     "test",
+		TTL:        time.Minute,
+	})
+	assert.NoError(t, err)
+
+	signed, _, err := manager.Generate(&domain.User{ID: 42})
+	assert.NoError(t, err)
+
+	claims, err := manager.Parse(signed)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 42, claims.UserID)
+}
+
+func TestJWTManager_RejectsForeignTokens(t *testing.T) {
+	issuer, err := NewJWTManager(Config{
+		Algorithm: AlgorithmHS256, Secret: strings.Repeat("a", 32), Issuer: "test", TTL: time.Minute,
+	})
+	assert.NoError(t, err)
+	verifier, err := NewJWTManager(Config{
+		Algorithm: AlgorithmHS256, Secret: strings.Repeat("b", 32), Issuer: "test", TTL: time.Minute,
+	})
+	assert.NoError(t, err)
+
+	signed, _, err := issuer.Generate(&domain.User{ID: 1})
+	assert.NoError(t, err)
+
+	_, err = verifier.Parse(signed)
+	assert.Error(t, err)
+}
+
+func TestJWTManager_RejectsExpiredTokens(t *testing.T) {
+	manager, err := NewJWTManager(Config{
+		Algorithm: AlgorithmHS256, Secret: strings.Repeat("s", 32), Issuer: "test", TTL: -time.Minute,
+	})
+	assert.NoError(t, err)
+
+	signed, _, err := manager.Generate(&domain.User{ID: 1})
+	assert.NoError(t, err)
+
+	_, err = manager.Parse(signed)
+	assert.Error(t, err)
+}
+
+func TestNewJWTManager_ShortSecret(t *testing.T) {
+	_, err := NewJWTManager(Config{Algorithm: AlgorithmHS256, Secret: "short"})
+
+	assert.Error(t, err)
+}
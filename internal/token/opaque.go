@@ -0,0 +1,27 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+// NewOpaque returns a random URL-safe token and its hash. Only the hash
+// should ever be stored.
+func NewOpaque() (plain, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("error generating token: %w", err)
+	}
+
+	plain = base64.RawURLEncoding.EncodeToString(buf)
+	return plain, Hash(plain), nil
+}
+
+// Hash returns the hex encoded SHA-256 of a token
+func Hash(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
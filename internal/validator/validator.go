@@ -1,9 +1,13 @@
 package validator
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"strings"
+	"unicode"
 
+	"github.com/DMaryanskiy/go-idk/internal/domain"
 	"github.com/go-playground/validator/v10"
 )
 
@@ -11,37 +15,122 @@ type Validator struct {
 	validate *validator.Validate
 }
 
+const (
+	passwordMinLength = 8
+	// bcrypt ignores everything after 72 bytes
+	passwordMaxBytes = 72
+)
+
 func New() *Validator {
+	validate := validator.New()
+	if err := validate.RegisterValidation("password", validatePassword); err != nil {
+		panic(err)
+	}
+	// Report fields under the names clients send them with
+	validate.RegisterTagNameFunc(jsonFieldName)
+
 	return &Validator{
-		validate: validator.New(),
+		validate: validate,
 	}
 }
 
+// Validate returns a *domain.ValidationError listing every invalid field
 func (v *Validator) Validate(data any) error {
-	if err := v.validate.Struct(data); err != nil {
-		if validationErrors, ok := err.(validator.ValidationErrors); ok {
-			var messages []string
-			for _, e := range validationErrors {
-				messages = append(messages, fmt.Sprintf("%s: %s", e.Field(), validationErrorMessage(e)))
-			}
-			return fmt.Errorf("%s", strings.Join(messages, ", "))
-		}
+	err := v.validate.Struct(data)
+	if err == nil {
+		return nil
+	}
+
+	var validationErrors validator.ValidationErrors
+	if !errors.As(err, &validationErrors) {
 		return err
 	}
-	return nil
+
+	fields := make([]domain.FieldError, 0, len(validationErrors))
+	for _, e := range validationErrors {
+		fields = append(fields, domain.FieldError{
+			Field:   fieldPath(e),
+			Rule:    e.Tag(),
+			Param:   e.Param(),
+			Message: validationErrorMessage(e),
+		})
+	}
+	return &domain.ValidationError{Fields: fields}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// fieldPath drops the struct name from the namespace, so nested fields
+// read like "permissions[0]"
+func fieldPath(e validator.FieldError) string {
+	_, path, found := strings.Cut(e.Namespace(), ".")
+	if !found {
+		return e.Field()
+	}
+	return path
 }
 
 func validationErrorMessage(e validator.FieldError) string {
+	unit := "characters"
+	switch e.Kind() {
+	case reflect.Slice, reflect.Array, reflect.Map:
+		unit = "items"
+	}
+
 	switch e.Tag() {
 	case "required":
 		return "is required"
 	case "email":
 		return "must be valid email"
 	case "min":
-		return fmt.Sprintf("must be at least %s characters", e.Param())
+		return fmt.Sprintf("must be at least %s %s", e.Param(), unit)
 	case "max":
-		return fmt.Sprintf("must not exceed %s characters", e.Param())
+		return fmt.Sprintf("must not exceed %s %s", e.Param(), unit)
+	case "len":
+		return fmt.Sprintf("must be exactly %s %s", e.Param(), unit)
+	case "gt":
+		return fmt.Sprintf("must be greater than %s", e.Param())
+	case "numeric":
+		return "must contain only digits"
+	case "oneof":
+		return fmt.Sprintf("must be one of: %s", strings.ReplaceAll(e.Param(), " ", ", "))
+	case "password":
+		return fmt.Sprintf(
+			"must be %d to %d characters long and contain an upper case letter, a lower case letter and a digit",
+			passwordMinLength, passwordMaxBytes,
+		)
 	default:
-		return "unhandled error"
+		return fmt.Sprintf("failed %q validation", e.Tag())
+	}
+}
+
+// validatePassword enforces password strength rules
+func validatePassword(fl validator.FieldLevel) bool {
+	password := fl.Field().String()
+	if len([]rune(password)) < passwordMinLength || len(password) > passwordMaxBytes {
+		return false
+	}
+
+	var hasUpper, hasLower, hasDigit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsUpper(r):
+			hasUpper = true
+		case unicode.IsLower(r):
+			hasLower = true
+		case unicode.IsDigit(r):
+			hasDigit = true
+		}
 	}
+
+	return hasUpper && hasLower && hasDigit
 }
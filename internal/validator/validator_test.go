@@ -0,0 +1,48 @@
+package validator
+
+import (
+	"testing"
+
+	"github.com/DMaryanskiy/go-idk/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate_FieldErrorsUseJSONNames(t *testing.T) {
+	v := New()
+
+	err := v.Validate(&domain.RegisterRequest{Email: "not-an-email", Name: "A", Password: "weak"})
+
+	var validationErr *domain.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	assert.ErrorIs(t, err, domain.ErrValidation)
+	assert.Equal(t, []domain.FieldError{
+		{Field: "email", Rule: "email", Message: "must be valid email"},
+		{Field: "name", Rule: "min", Param: "2", Message: "must be at least 2 characters"},
+		{
+			Field:   "password",
+			Rule:    "password",
+			Message: "must be 8 to 72 characters long and contain an upper case letter, a lower case letter and a digit",
+		},
+	}, validationErr.Fields)
+}
+
+func TestValidate_NestedFields(t *testing.T) {
+	v := New()
+
+	err := v.Validate(&domain.CreateRoleRequest{Name: "moderator", Permissions: []string{"users:read", ""}})
+
+	var validationErr *domain.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+	require.Len(t, validationErr.Fields, 1)
+	assert.Equal(t, "permissions[1]", validationErr.Fields[0].Field)
+	assert.Equal(t, "required", validationErr.Fields[0].Rule)
+}
+
+func TestValidate_Valid(t *testing.T) {
+	v := New()
+
+	err := v.Validate(&domain.RegisterRequest{Email: "test@example.com", Name: "Test User", Password: "Secret123"})
+
+	assert.NoError(t, err)
+}
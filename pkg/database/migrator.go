@@ -0,0 +1,467 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// migrationLockID is the key of the Postgres advisory lock that serializes
+// migration runs between replicas starting at the same time.
+const migrationLockID int64 = 7_120_426_335_190_011
+
+var migrationFileRe = regexp.MustCompile(`^(\d+)_([a-zA-Z0-9_]+)\.(up|down)\.sql$`)
+
+var (
+	// ErrChecksumMismatch means an already applied migration file was edited
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	// ErrSchemaBehind means there are migrations that were not applied yet
+	ErrSchemaBehind = errors.New("database schema is behind")
+)
+
+// Migration is a single versioned schema change loaded from SQL files
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// MigrationStatus describes a known migration and whether it was applied.
+// Modified is set when the applied checksum differs from the embedded file.
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	Modified  bool
+	AppliedAt *time.Time
+}
+
+// conn is the subset of *sql.Conn used by the migrator, so that every
+// statement of a run goes through the session holding the advisory lock
+type conn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+}
+
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+type Migrator struct {
+	db         *DB
+	migrations []Migration
+}
+
+func NewMigrator(db *DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+// LoadMigrations reads <version>_<name>.up.sql / .down.sql pairs from fsys
+// and returns them sorted by version.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("error reading migrations: %w", err)
+	}
+
+	byVersion := map[int64]*Migration{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := migrationFileRe.FindStringSubmatch(entry.Name())
+		if match == nil {
+			continue
+		}
+
+		version, err := strconv.ParseInt(match[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %q: %w", entry.Name(), err)
+		}
+
+		content, err := fs.ReadFile(fsys, entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error reading migration %q: %w", entry.Name(), err)
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %d has conflicting names %q and %q", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up file", m.Version, m.Name)
+		}
+		m.Checksum = checksum(m.Up)
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+func checksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// Migrations returns all known migrations sorted by version
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest returns the highest known migration version, or 0 if there are none
+func (m *Migrator) Latest() int64 {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Up applies every pending migration in order and returns the applied ones
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.Goto(ctx, m.Latest())
+}
+
+// Down rolls back the last n applied migrations and returns them
+func (m *Migrator) Down(ctx context.Context, n int) (rolledBack []Migration, err error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("number of migrations to roll back must be positive")
+	}
+
+	err = m.withLock(ctx, func(c conn) error {
+		applied, err := m.verified(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		for i := len(applied) - 1; i >= 0 && len(rolledBack) < n; i-- {
+			migration, err := m.find(applied[i].Version)
+			if err != nil {
+				return err
+			}
+			if err := m.apply(ctx, c, migration, false); err != nil {
+				return err
+			}
+			rolledBack = append(rolledBack, migration)
+		}
+		return nil
+	})
+
+	return rolledBack, err
+}
+
+// Goto migrates the schema up or down until version is the latest applied one.
+// Version 0 rolls back every migration.
+func (m *Migrator) Goto(ctx context.Context, version int64) (changed []Migration, err error) {
+	if version != 0 {
+		if _, err := m.find(version); err != nil {
+			return nil, err
+		}
+	}
+
+	err = m.withLock(ctx, func(c conn) error {
+		applied, err := m.verified(ctx, c)
+		if err != nil {
+			return err
+		}
+
+		isApplied := make(map[int64]bool, len(applied))
+		for _, a := range applied {
+			isApplied[a.Version] = true
+		}
+
+		// Roll back everything above the target, newest first
+		for i := len(applied) - 1; i >= 0; i-- {
+			if applied[i].Version <= version {
+				continue
+			}
+			migration, err := m.find(applied[i].Version)
+			if err != nil {
+				return err
+			}
+			if err := m.apply(ctx, c, migration, false); err != nil {
+				return err
+			}
+			changed = append(changed, migration)
+		}
+
+		// Apply everything pending up to the target, oldest first
+		for _, migration := range m.migrations {
+			if migration.Version > version || isApplied[migration.Version] {
+				continue
+			}
+			if err := m.apply(ctx, c, migration, true); err != nil {
+				return err
+			}
+			changed = append(changed, migration)
+		}
+		return nil
+	})
+
+	return changed, err
+}
+
+// Status lists every known migration together with its applied state. Like
+// Check it only reads, so it works with a read-only role and does not wait
+// for a running migration.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	applied, err := m.recorded(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int64]appliedMigration, len(applied))
+	for _, a := range applied {
+		byVersion[a.Version] = a
+	}
+
+	statuses := make([]MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if a, ok := byVersion[migration.Version]; ok {
+			status.Applied = true
+			status.Modified = a.Checksum != migration.Checksum
+			status.AppliedAt = &a.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// Check verifies that no applied migration was modified and that every
+// known migration is applied. It never changes the schema: it takes no lock
+// and a database without schema_migrations is reported as behind.
+func (m *Migrator) Check(ctx context.Context) error {
+	applied, err := m.recorded(ctx)
+	if err != nil {
+		return err
+	}
+	if err := m.verify(applied); err != nil {
+		return err
+	}
+
+	isApplied := make(map[int64]bool, len(applied))
+	for _, a := range applied {
+		isApplied[a.Version] = true
+	}
+
+	var pending []string
+	for _, migration := range m.migrations {
+		if !isApplied[migration.Version] {
+			pending = append(pending, fmt.Sprintf("%d_%s", migration.Version, migration.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: pending migrations %s", ErrSchemaBehind, strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+// recorded returns applied migrations without the lock, a missing
+// schema_migrations table means none were applied
+func (m *Migrator) recorded(ctx context.Context) ([]appliedMigration, error) {
+	var exists bool
+	err := m.db.QueryRowContext(ctx, "SELECT to_regclass('schema_migrations') IS NOT NULL;").Scan(&exists)
+	if err != nil {
+		return nil, fmt.Errorf("error looking up schema_migrations table: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+	return m.applied(ctx, m.db)
+}
+
+// withLock runs fn on a single connection holding the migration advisory
+// lock. Concurrent callers on other replicas block until it is released.
+func (m *Migrator) withLock(ctx context.Context, fn func(c conn) error) (err error) {
+	c, err := m.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("error acquiring connection: %w", err)
+	}
+	defer func() {
+		errConn := c.Close()
+		if errConn != nil && err == nil {
+			err = errConn
+		}
+	}()
+
+	if _, err = c.ExecContext(ctx, "SELECT pg_advisory_lock($1);", migrationLockID); err != nil {
+		return fmt.Errorf("error acquiring migration lock: %w", err)
+	}
+	defer func() {
+		// Use a fresh context so the lock is released even if ctx expired
+		unlockCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, errUnlock := c.ExecContext(unlockCtx, "SELECT pg_advisory_unlock($1);", migrationLockID)
+		if errUnlock != nil && err == nil {
+			err = fmt.Errorf("error releasing migration lock: %w", errUnlock)
+		}
+	}()
+
+	if err = m.ensureTable(ctx, c); err != nil {
+		return err
+	}
+
+	return fn(c)
+}
+
+// verified returns applied migrations after making sure none of them was
+// edited since it ran
+func (m *Migrator) verified(ctx context.Context, c conn) ([]appliedMigration, error) {
+	applied, err := m.applied(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.verify(applied); err != nil {
+		return nil, err
+	}
+	return applied, nil
+}
+
+func (m *Migrator) verify(applied []appliedMigration) error {
+	for _, a := range applied {
+		migration, err := m.find(a.Version)
+		if err != nil {
+			// Applied by a newer binary, nothing to compare with
+			continue
+		}
+		if migration.Checksum != a.Checksum {
+			return fmt.Errorf("%w: %d_%s has checksum %s, database recorded %s",
+				ErrChecksumMismatch, a.Version, a.Name, migration.Checksum, a.Checksum)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) find(version int64) (Migration, error) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, nil
+		}
+	}
+	return Migration{}, fmt.Errorf("migration %d not found", version)
+}
+
+func (m *Migrator) ensureTable(ctx context.Context, c conn) error {
+	query := `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version BIGINT PRIMARY KEY,
+		name VARCHAR(255) NOT NULL,
+		checksum CHAR(64) NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	);`
+
+	if _, err := c.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("error creating schema_migrations table: %w", err)
+	}
+	return nil
+}
+
+func (m *Migrator) applied(ctx context.Context, c conn) (migrations []appliedMigration, err error) {
+	query := "SELECT version, name, checksum, applied_at FROM schema_migrations ORDER BY version;"
+
+	rows, err := c.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("error getting applied migrations: %w", err)
+	}
+	defer func() {
+		errRows := rows.Close()
+		if errRows != nil {
+			err = errRows
+		}
+	}()
+
+	for rows.Next() {
+		var a appliedMigration
+		if err = rows.Scan(&a.Version, &a.Name, &a.Checksum, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("error scanning applied migration: %w", err)
+		}
+		migrations = append(migrations, a)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating applied migrations: %w", err)
+	}
+
+	return migrations, nil
+}
+
+// apply runs a single migration in its own transaction together with the
+// bookkeeping row, so a failed migration leaves no trace.
+func (m *Migrator) apply(ctx context.Context, c conn, migration Migration, up bool) (err error) {
+	if !up && migration.Down == "" {
+		return fmt.Errorf("migration %d_%s has no down file", migration.Version, migration.Name)
+	}
+
+	tx, err := c.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("error starting migration transaction: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			if errTx := tx.Rollback(); errTx != nil && errTx != sql.ErrTxDone {
+				err = fmt.Errorf("%w (rollback failed: %v)", err, errTx)
+			}
+		}
+	}()
+
+	if up {
+		if _, err = tx.ExecContext(ctx, migration.Up); err != nil {
+			return fmt.Errorf("error applying migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		_, err = tx.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, checksum) VALUES ($1, $2, $3);",
+			migration.Version, migration.Name, migration.Checksum,
+		)
+	} else {
+		if _, err = tx.ExecContext(ctx, migration.Down); err != nil {
+			return fmt.Errorf("error rolling back migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		_, err = tx.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = $1;", migration.Version)
+	}
+	if err != nil {
+		return fmt.Errorf("error recording migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("error committing migration %d_%s: %w", migration.Version, migration.Name, err)
+	}
+	return nil
+}
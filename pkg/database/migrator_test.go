@@ -0,0 +1,265 @@
+package database
+
+import (
+	"context"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func testMigrationsFS() fstest.MapFS {
+	return fstest.MapFS{
+		"000002_add_index.up.sql":      {Data: []byte("CREATE INDEX idx ON users(name);")},
+		"000002_add_index.down.sql":    {Data: []byte("DROP INDEX idx;")},
+		"000001_create_users.up.sql":   {Data: []byte("CREATE TABLE users (id SERIAL);")},
+		"000001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+		"README.md":                    {Data: []byte("ignored")},
+	}
+}
+
+func expectLock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("SELECT pg_advisory_lock").
+		WithArgs(migrationLockID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func expectTable(mock sqlmock.Sqlmock, exists bool) {
+	mock.ExpectQuery("SELECT to_regclass").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(exists))
+}
+
+func expectUnlock(mock sqlmock.Sqlmock) {
+	mock.ExpectExec("SELECT pg_advisory_unlock").
+		WithArgs(migrationLockID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migrations, err := LoadMigrations(testMigrationsFS())
+
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, int64(1), migrations[0].Version)
+	assert.Equal(t, "create_users", migrations[0].Name)
+	assert.Equal(t, "DROP TABLE users;", migrations[0].Down)
+	assert.Equal(t, int64(2), migrations[1].Version)
+	assert.Len(t, migrations[1].Checksum, 64)
+}
+
+func TestLoadMigrations_MissingUp(t *testing.T) {
+	fsys := fstest.MapFS{
+		"000001_create_users.down.sql": {Data: []byte("DROP TABLE users;")},
+	}
+
+	_, err := LoadMigrations(fsys)
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "no up file")
+}
+
+func TestMigratorUp(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	migrator, err := NewMigrator(&DB{DB: db}, testMigrationsFS())
+	assert.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery("SELECT (.+) FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+			AddRow(1, "create_users", migrator.Migrations()[0].Checksum, time.Now()))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE INDEX idx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations").
+		WithArgs(int64(2), "add_index", migrator.Migrations()[1].Checksum).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	applied, err := migrator.Up(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, applied, 1)
+	assert.Equal(t, int64(2), applied[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorUp_RollsBackOnFailure(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	migrator, err := NewMigrator(&DB{DB: db}, testMigrationsFS())
+	assert.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery("SELECT (.+) FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE users").WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+	expectUnlock(mock)
+
+	applied, err := migrator.Up(context.Background())
+
+	assert.Error(t, err)
+	assert.Empty(t, applied)
+	assert.Contains(t, err.Error(), "error applying migration 1_create_users")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorDown(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	migrator, err := NewMigrator(&DB{DB: db}, testMigrationsFS())
+	assert.NoError(t, err)
+
+	now := time.Now()
+	expectLock(mock)
+	mock.ExpectQuery("SELECT (.+) FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+			AddRow(1, "create_users", migrator.Migrations()[0].Checksum, now).
+			AddRow(2, "add_index", migrator.Migrations()[1].Checksum, now))
+
+	mock.ExpectBegin()
+	mock.ExpectExec("DROP INDEX idx").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM schema_migrations").
+		WithArgs(int64(2)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUnlock(mock)
+
+	rolledBack, err := migrator.Down(context.Background(), 1)
+
+	assert.NoError(t, err)
+	assert.Len(t, rolledBack, 1)
+	assert.Equal(t, int64(2), rolledBack[0].Version)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorUp_ChecksumMismatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	migrator, err := NewMigrator(&DB{DB: db}, testMigrationsFS())
+	assert.NoError(t, err)
+
+	expectLock(mock)
+	mock.ExpectQuery("SELECT (.+) FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+			AddRow(1, "create_users", "edited", time.Now()))
+	expectUnlock(mock)
+
+	applied, err := migrator.Up(context.Background())
+
+	assert.ErrorIs(t, err, ErrChecksumMismatch)
+	assert.Empty(t, applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorCheck_SchemaBehind(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	migrator, err := NewMigrator(&DB{DB: db}, testMigrationsFS())
+	assert.NoError(t, err)
+
+	expectTable(mock, true)
+	mock.ExpectQuery("SELECT (.+) FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+			AddRow(1, "create_users", migrator.Migrations()[0].Checksum, time.Now()))
+
+	err = migrator.Check(context.Background())
+
+	assert.ErrorIs(t, err, ErrSchemaBehind)
+	assert.Contains(t, err.Error(), "2_add_index")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorCheck_WithoutTable(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	migrator, err := NewMigrator(&DB{DB: db}, testMigrationsFS())
+	assert.NoError(t, err)
+
+	// No lock and no CREATE TABLE, any other statement fails the test
+	expectTable(mock, false)
+
+	err = migrator.Check(context.Background())
+
+	assert.ErrorIs(t, err, ErrSchemaBehind)
+	assert.Contains(t, err.Error(), "1_create_users, 2_add_index")
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMigratorStatus(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer func() {
+		errDB := db.Close()
+		if errDB != nil {
+			err = errDB
+		}
+	}()
+
+	migrator, err := NewMigrator(&DB{DB: db}, testMigrationsFS())
+	assert.NoError(t, err)
+
+	expectTable(mock, true)
+	mock.ExpectQuery("SELECT (.+) FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "name", "checksum", "applied_at"}).
+			AddRow(1, "create_users", "edited", time.Now()))
+
+	statuses, err := migrator.Status(context.Background())
+
+	assert.NoError(t, err)
+	assert.Len(t, statuses, 2)
+	assert.True(t, statuses[0].Applied)
+	assert.True(t, statuses[0].Modified)
+	assert.False(t, statuses[1].Applied)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
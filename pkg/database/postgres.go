@@ -3,14 +3,16 @@ package database
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 type DB struct {
 	*sql.DB
+	connStr string
 }
 
 func New(connStr string, maxOpen, maxIdle int, maxLifetime time.Duration) (*DB, error) {
@@ -30,26 +32,14 @@ func New(connStr string, maxOpen, maxIdle int, maxLifetime time.Duration) (*DB,
 		return nil, fmt.Errorf("error connecting to database: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, connStr: connStr}, nil
 }
 
-// TODO: add migration tool later
-func (db *DB) Migrate() error {
-	ctx, cancel := context.WithTimeout(context.Background(), 30 * time.Second)
-	defer cancel()
-
-	query := `
-    CREATE TABLE IF NOT EXISTS users (
-        id SERIAL PRIMARY KEY,
-        email VARCHAR(255) UNIQUE NOT NULL,
-        name VARCHAR(255) NOT NULL,
-        created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-        updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
-    );
-    CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
-    CREATE INDEX IF NOT EXISTS idx_users_created_at ON users(created_at);
-    `
-
-	_, err := db.ExecContext(ctx, query)
-	return err
+// NewListener opens a dedicated connection for LISTEN, notifications are
+// bound to a session and cannot go through the pool. It reconnects on its own.
+func (db *DB) NewListener(minReconnect, maxReconnect time.Duration, callback pq.EventCallbackType) (*pq.Listener, error) {
+	if db.connStr == "" {
+		return nil, errors.New("database was not opened with a connection string")
+	}
+	return pq.NewListener(db.connStr, minReconnect, maxReconnect, callback), nil
 }
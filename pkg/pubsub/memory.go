@@ -0,0 +1,52 @@
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is an in-process PubSub for tests and single instance setups.
+// Publish calls the handlers synchronously.
+type Memory struct {
+	mu       sync.Mutex
+	handlers map[string][]Handler
+	closed   bool
+}
+
+func NewMemory() *Memory {
+	return &Memory{handlers: make(map[string][]Handler)}
+}
+
+func (m *Memory) Publish(ctx context.Context, channel string, payload []byte) error {
+	// Holding the lock while calling handlers keeps them serialized like on Postgres
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	for _, handler := range m.handlers[channel] {
+		handler(payload)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(channel string, handler Handler) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.closed {
+		return ErrClosed
+	}
+	m.handlers[channel] = append(m.handlers[channel], handler)
+	return nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closed = true
+	m.handlers = nil
+	return nil
+}
@@ -0,0 +1,31 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryPublish(t *testing.T) {
+	bus := NewMemory()
+
+	var first, second, other []string
+	assert.NoError(t, bus.Subscribe("events", func(payload []byte) { first = append(first, string(payload)) }))
+	assert.NoError(t, bus.Subscribe("events", func(payload []byte) { second = append(second, string(payload)) }))
+	assert.NoError(t, bus.Subscribe("other", func(payload []byte) { other = append(other, string(payload)) }))
+
+	assert.NoError(t, bus.Publish(context.Background(), "events", []byte("hello")))
+
+	assert.Equal(t, []string{"hello"}, first)
+	assert.Equal(t, []string{"hello"}, second)
+	assert.Empty(t, other)
+}
+
+func TestMemoryClosed(t *testing.T) {
+	bus := NewMemory()
+	assert.NoError(t, bus.Close())
+
+	assert.ErrorIs(t, bus.Publish(context.Background(), "events", []byte("hello")), ErrClosed)
+	assert.ErrorIs(t, bus.Subscribe("events", func([]byte) {}), ErrClosed)
+}
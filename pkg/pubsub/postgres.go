@@ -0,0 +1,157 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/lib/pq"
+	"go.uber.org/zap"
+)
+
+// MaxPayloadSize is the largest payload NOTIFY accepts
+const MaxPayloadSize = 7999
+
+// ErrPayloadTooLarge is returned by Postgres.Publish for payloads above MaxPayloadSize
+var ErrPayloadTooLarge = errors.New("pubsub payload is too large")
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+	// pingInterval checks the LISTEN connection when no notification arrived,
+	// a dead connection is only noticed on use
+	pingInterval = 90 * time.Second
+)
+
+// Postgres is a PubSub on top of LISTEN/NOTIFY. Messages are published
+// through the connection pool and received on a dedicated connection, so
+// every instance connected to the same database gets them, including the
+// publisher itself. Notifications sent while the listener reconnects are lost.
+type Postgres struct {
+	db       *database.DB
+	listener *pq.Listener
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+	done     chan struct{}
+	wg       sync.WaitGroup
+	logger   *zap.Logger
+}
+
+func NewPostgres(db *database.DB, logger *zap.Logger) (*Postgres, error) {
+	p := &Postgres{
+		db:       db,
+		handlers: make(map[string][]Handler),
+		done:     make(chan struct{}),
+		logger:   logger,
+	}
+
+	listener, err := db.NewListener(minReconnectInterval, maxReconnectInterval, p.onListenerEvent)
+	if err != nil {
+		return nil, fmt.Errorf("error creating listener: %w", err)
+	}
+	p.listener = listener
+
+	p.wg.Add(1)
+	go p.run()
+
+	return p, nil
+}
+
+func (p *Postgres) Publish(ctx context.Context, channel string, payload []byte) error {
+	if len(payload) > MaxPayloadSize {
+		return ErrPayloadTooLarge
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := p.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", channel, string(payload)); err != nil {
+		return fmt.Errorf("error publishing to %s: %w", channel, err)
+	}
+	return nil
+}
+
+func (p *Postgres) Subscribe(channel string, handler Handler) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	select {
+	case <-p.done:
+		return ErrClosed
+	default:
+	}
+
+	if len(p.handlers[channel]) == 0 {
+		if err := p.listener.Listen(channel); err != nil {
+			return fmt.Errorf("error listening on %s: %w", channel, err)
+		}
+	}
+	p.handlers[channel] = append(p.handlers[channel], handler)
+	return nil
+}
+
+func (p *Postgres) Close() error {
+	p.mu.Lock()
+	select {
+	case <-p.done:
+		p.mu.Unlock()
+		return nil
+	default:
+		close(p.done)
+	}
+	p.mu.Unlock()
+
+	err := p.listener.Close()
+	p.wg.Wait()
+	return err
+}
+
+func (p *Postgres) run() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case n, ok := <-p.listener.Notify:
+			if !ok {
+				return
+			}
+			// A nil notification is sent after the listener reconnected
+			if n == nil {
+				continue
+			}
+			p.dispatch(n.Channel, []byte(n.Extra))
+		case <-ticker.C:
+			if err := p.listener.Ping(); err != nil {
+				p.logger.Warn("Pubsub listener ping failed", zap.Error(err))
+			}
+		}
+	}
+}
+
+func (p *Postgres) dispatch(channel string, payload []byte) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	for _, handler := range p.handlers[channel] {
+		handler(payload)
+	}
+}
+
+func (p *Postgres) onListenerEvent(event pq.ListenerEventType, err error) {
+	switch event {
+	case pq.ListenerEventDisconnected:
+		p.logger.Warn("Pubsub listener disconnected", zap.Error(err))
+	case pq.ListenerEventReconnected:
+		p.logger.Info("Pubsub listener reconnected")
+	case pq.ListenerEventConnectionAttemptFailed:
+		p.logger.Warn("Pubsub listener connection attempt failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,36 @@
+package pubsub
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/DMaryanskiy/go-idk/pkg/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresPublish(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	assert.NoError(t, err)
+	defer db.Close()
+
+	p := &Postgres{db: &database.DB{DB: db}}
+
+	mock.ExpectExec("SELECT pg_notify").
+		WithArgs("events", `{"id":1}`).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = p.Publish(context.Background(), "events", []byte(`{"id":1}`))
+
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresPublish_TooLarge(t *testing.T) {
+	p := &Postgres{}
+
+	err := p.Publish(context.Background(), "events", []byte(strings.Repeat("x", MaxPayloadSize+1)))
+
+	assert.ErrorIs(t, err, ErrPayloadTooLarge)
+}
@@ -0,0 +1,23 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrClosed is returned by Publish and Subscribe after Close
+var ErrClosed = errors.New("pubsub is closed")
+
+// Handler receives the payload of a message published on a channel
+type Handler func(payload []byte)
+
+// PubSub broadcasts messages to every subscriber of a channel, on all
+// instances sharing the backend. Delivery is at most once, subscribers that
+// are disconnected when a message is published miss it.
+type PubSub interface {
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe registers handler for channel until Close. Handlers of one
+	// backend are called one at a time and must not block.
+	Subscribe(channel string, handler Handler) error
+	Close() error
+}